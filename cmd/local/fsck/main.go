@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/path"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// main checks a sql mempool db for input and output rows no tx refers to,
+// left behind by older versions which wrote rows of a tx before rejecting it.
+// exits with status 1 when any is found, unless -repair removed them
+func main() {
+	dbPath := flag.String("db", path.LocalDBPath, "sqlite db of sql or memory backend to check")
+	repair := flag.Bool("repair", false, "delete orphaned rows")
+	flag.Parse()
+
+	logger := logrus.New()
+	logger.Formatter = &logrus.TextFormatter{
+		DisableColors: false,
+		ForceColors:   true,
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		logger.Fatal(err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{
+		Logger: gormLogger.Default.LogMode(gormLogger.Silent),
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	c, err := mempool.CheckConsistency(db)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	for _, in := range c.OrphanInputs {
+		logger.Warnf("orphaned input %d spending %s:%d by missing tx %s", in.ID, in.FundingTxHash, in.FundingIndex, in.SpendingTxHash)
+	}
+	for _, out := range c.OrphanOutputs {
+		logger.Warnf("orphaned output %d %s:%d", out.ID, out.FundingTxHash, out.FundingTxPos)
+	}
+	logger.Infof("%d orphaned inputs %d orphaned outputs", len(c.OrphanInputs), len(c.OrphanOutputs))
+
+	if c.Ok() {
+		return
+	}
+
+	if !*repair {
+		os.Exit(1)
+	}
+
+	if err := mempool.RemoveOrphans(db, c); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Info("orphaned rows removed")
+}
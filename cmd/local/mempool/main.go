@@ -1,16 +1,22 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	config "sob-miner"
-	"sob-miner/internal/ierrors"
+	"sob-miner/internal/ingest"
 	"sob-miner/internal/mempool"
 	"sob-miner/internal/path"
+	"sob-miner/internal/report"
+	"sob-miner/pkg/chaincfg"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -35,16 +41,30 @@ func (p *ProgressBar) Play(cur int) {
 // main is the entry point of the program.
 //
 // It removes the database file, initializes the mempool, and loads transactions from JSON files.
-// It handles panics and logs errors.
 // It also handles rejected transactions and calculates the elapsed time for loading transactions.
 func main() {
+	flag.StringVar(&config.Network, "network", config.Network, "network params to use: mainnet | testnet3 | signet | regtest")
+	backend := flag.String("backend", string(mempool.BackendSQL), "mempool backend: sql | memory [persisted to sqlite db] | bolt")
+	persist := flag.Bool("persistmempool", false, "save loaded mempool to mempool.dat for local miner")
+	readers := flag.Int("readers", ingest.DefaultReaders, "workers reading tx files")
+	validators := flag.Int("validators", runtime.NumCPU(), "workers parsing and validating txs")
+	batch := flag.Int("batch", ingest.DefaultBatchSize, "txs admitted to mempool per write")
+	limitAncestors := flag.Int("limitancestorcount", mempool.DefaultLimits.Ancestors, "max in mempool ancestors of a tx, itself included")
+	limitAncestorSize := flag.Uint64("limitancestorsize", mempool.DefaultLimits.AncestorSize/1000, "max size of a tx with its in mempool ancestors in kvB")
+	limitDescendants := flag.Int("limitdescendantcount", mempool.DefaultLimits.Descendants, "max in mempool descendants of a tx, itself included")
+	limitDescendantSize := flag.Uint64("limitdescendantsize", mempool.DefaultLimits.DescendantSize/1000, "max size of a tx with its in mempool descendants in kvB")
+	maxOrphans := flag.Int("maxorphantx", ingest.DefaultMaxOrphans, "max txs kept waiting for parent files to load")
+	verifyScripts := flag.Bool("verifyscripts", false, "verify input scripts while loading txs instead of only when mining")
+	trace := flag.String("trace", "", "txid of a tx to trace through every validation stage")
+	traceFormat := flag.String("traceformat", "text", "format of printed trace: text | json")
+	flag.Parse()
+
+	params, err := chaincfg.ParamsByName(config.Network)
+	if err != nil {
+		panic(err)
+	}
 	os.Remove(path.DBPath)
-
-	defer func() {
-		if err := recover(); err != nil {
-			logrus.Error(err)
-		}
-	}()
+	os.Remove(path.BoltDBPath)
 
 	logger := logrus.New()
 	// logger.SetLevel(logrus.InfoLevel)
@@ -59,6 +79,17 @@ func main() {
 		Logger:         logger,
 
 		Dust: uint64(config.Dust),
+
+		Params:  params,
+		Backend: mempool.Backend(*backend),
+		Path:    path.BoltDBPath,
+
+		Limits: mempool.Limits{
+			Ancestors:      *limitAncestors,
+			AncestorSize:   *limitAncestorSize * 1000,
+			Descendants:    *limitDescendants,
+			DescendantSize: *limitDescendantSize * 1000,
+		},
 	}
 
 	// init mempool
@@ -81,100 +112,84 @@ func main() {
 		panic(err)
 	}
 
-	totalFiles := len(files)
-	logger.Info("loading ", totalFiles, " files")
+	paths := []string{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			logger.Info("skipping ", file.Name())
+			continue
+		}
+		paths = append(paths, filepath.Join(path.MempoolDataPath, file.Name()))
+	}
+
+	logger.Info("loading ", len(paths), " files")
 	pb := &ProgressBar{
-		Total:   totalFiles,
+		Total:   len(paths),
 		Current: 0,
 
 		rate: "#",
 	}
 
-	wg := new(sync.WaitGroup)
-	doneChan := make(chan struct{})
-
-	rejectedTxFile := "rejected.txt"
-	if _, err := os.Stat(path.Root + "/" + rejectedTxFile); err == nil {
-		os.Remove(path.Root + "/" + rejectedTxFile)
-	}
-
-	rejTxFile, err := os.OpenFile(path.Root+"/"+rejectedTxFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	rejections, err := report.CreateRejections(path.RejectionsPath)
 	if err != nil {
 		panic(err)
 	}
-	defer rejTxFile.Close()
-
-	acceptableErrs := []string{
-		ierrors.ErrAsmAndScriptMismatch.Error(),
-		ierrors.ErrFeeTooLow.Error(),
-	}
-
-	start := time.Now()
-	// TODO: spawing 8k go routines is bad and should be improved
-	// REASON: will spend more time context switching and GC rather than work
-	// SOLUTION: use a channel to distribute files to set of go routines
-	for i, file := range files {
-
-		if file.IsDir() && strings.Split(file.Name(), ".")[1] != "json" {
-			logger.Info("skipping ", file.Name())
-			continue
-		}
-
-		wg.Add(1)
-		go func(i int, file fs.DirEntry) {
-			defer func() {
-				doneChan <- struct{}{}
-				wg.Done()
-			}()
-
-			txData, err := os.ReadFile(path.MempoolDataPath + "/" + file.Name())
+	defer rejections.Close()
+
+	pipeline := ingest.New(pool, ingest.Opts{
+		Logger:        logger,
+		Readers:       *readers,
+		Validators:    *validators,
+		BatchSize:     *batch,
+		Orphans:       mempool.OrphanOpts{MaxOrphans: *maxOrphans},
+		VerifyScripts: *verifyScripts,
+		Rejections:    rejections,
+		Trace:         *trace,
+		OnTrace:       func(t *mempool.Trace) { printTrace(t, *traceFormat) },
+		OnResult: func(file string, err error) {
+			pb.Current++
+			pb.Play(pb.Current)
+
+			// malformed and invalid txs are only rejected, each with its reason in rejections report
 			if err != nil {
-				panic(err)
-			}
-
-			var tx mempool.Transaction
-			if err := json.Unmarshal(txData, &tx); err != nil {
-				panic(err)
-			}
-
-			if err := pool.PutTx(tx); err != nil {
-				logger.Info("processing ", file.Name())
-				rejTxFile.WriteString(file.Name() + " Reason: " + err.Error() + "\n")
-
-				if Contains(err.Error(), acceptableErrs) {
-					// logger.Info("press enter to continue")
-					// reader := bufio.NewReader(os.Stdin)
-					// _, _ = reader.ReadString('\n')
-					return
-				}
-				panic(err)
+				logger.Debug("rejected ", file, " ", err)
 			}
-			// pb.Current++
-			// pb.Play(pb.Current)
+		},
+	})
 
-		}(i, file)
-	}
+	// interrupt stops ingestion, txs loaded so far are still saved once pipeline is done writing
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	for i := 0; i < totalFiles; i++ {
-		<-doneChan
-		pb.Current++
-		pb.Play(pb.Current)
+	metrics, err := pipeline.Run(ctx, paths)
+	fmt.Println("")
+	if errors.Is(err, context.Canceled) {
+		logger.Warn("interrupted after loading ", metrics.Accepted, " transactions")
+	} else if err != nil {
+		panic(err)
 	}
 
-	fmt.Println("")
+	logger.Info("loaded ", metrics.Accepted, " transactions into Mempool", " in ", metrics.Elapsed.Seconds(), " seconds")
+	logger.Info(metrics)
 
-	wg.Wait()
-	elapsed := time.Since(start)
-	logger.Info("loaded ", len(files), " transactions into Mempool", " in ", elapsed.Seconds(), " seconds")
+	if *persist {
+		mempool.SaveFile(pool, path.MempoolDatPath, logger)
+	}
 
 	// init miner and run
 }
 
-func Contains(target string, array []string) bool {
-	for _, element := range array {
-		if element == target {
-			return true
-		}
+// printTrace prints trace below progress bar
+func printTrace(t *mempool.Trace, format string) {
+	fmt.Println("")
+
+	var err error
+	switch format {
+	case "json":
+		err = t.WriteJSON(os.Stdout)
+	default:
+		err = t.WriteText(os.Stdout)
+	}
+	if err != nil {
+		logrus.Error("unable to print trace ", err)
 	}
-	return false
 }
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
 	config "sob-miner"
 	"sob-miner/internal/mempool"
 	"sob-miner/internal/miner"
 	"sob-miner/internal/path"
+	"sob-miner/internal/report"
+	"sob-miner/pkg/chaincfg"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,8 +23,19 @@ import (
 // main is the entry point of the Go program.
 //
 // It initializes the logger, mempool, and miner, and then starts the mining process.
-// assumes transactions are already loaded into the mempool. performs a cleaning reset on tables.
+// assumes transactions are already loaded into the mempool. performs a cleaning reset on tables,
+// or with -persistmempool starts from mempool.dat and saves what is left of it on shutdown.
 func main() {
+	flag.StringVar(&config.Network, "network", config.Network, "network params to use: mainnet | testnet3 | signet | regtest")
+	backend := flag.String("backend", string(mempool.BackendSQL), "mempool backend: sql | memory [persisted to sqlite db] | bolt")
+	optimize := flag.Bool("optimize", false, "fill tail of block with knapsack optimizer instead of greedy picking")
+	persist := flag.Bool("persistmempool", false, "load mempool.dat instead of resetting db and save mempool to it on shutdown")
+	flag.Parse()
+
+	params, err := chaincfg.ParamsByName(config.Network)
+	if err != nil {
+		panic(err)
+	}
 
 	defer func() {
 		if err := recover(); err != nil {
@@ -38,6 +56,15 @@ func main() {
 		Logger:         logger,
 
 		Dust: uint64(config.Dust),
+
+		Params:  params,
+		Backend: mempool.Backend(*backend),
+		Path:    path.BoltDBPath,
+	}
+
+	if *persist {
+		os.Remove(path.LocalDBPath)
+		os.Remove(path.BoltDBPath)
 	}
 
 	// init mempool
@@ -51,33 +78,54 @@ func main() {
 		panic(err)
 	}
 
-	if err := pool.ResetTables(); err != nil {
+	rejections, err := report.AppendRejections(path.RejectionsPath)
+	if err != nil {
 		panic(err)
 	}
+	defer rejections.Close()
 
-	logger.Info("mempool initialized")
-	logger.Info("starting miner")
-
-	miner, err := miner.New(pool, miner.Opts{
+	minerOpts := miner.Opts{
 		Logger:       logger,
 		MaxBlockSize: uint(config.MAX_BLOCK_SIZE),
-	})
+		Params:       params,
+		Optimize:     *optimize,
+		Rejections:   rejections,
+	}
 
+	// mainnet difficulty can't be mined locally, assignment target is used instead
+	if params.Name != chaincfg.MainNetParams.Name {
+		minerOpts.Chain = params.NewChain()
+	}
+
+	// payouts are checked against network before loading mempool
+	miner, err := miner.New(pool, minerOpts)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := miner.Mine(); err != nil {
+	// interrupt is held until miner is done writing, mempool is then dumped by deferred save
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *persist {
+		if err := mempool.RestoreFile(pool, path.MempoolDatPath, params, logger); err != nil {
+			panic(err)
+		}
+		defer mempool.SaveFile(pool, path.MempoolDatPath, logger)
+	} else if err := pool.ResetTables(); err != nil {
 		panic(err)
 	}
 
-}
+	logger.Info("mempool initialized")
+	if ctx.Err() != nil {
+		logger.Warn("interrupted before mining")
+		return
+	}
 
-func Contains(target string, array []string) bool {
-	for _, element := range array {
-		if element == target {
-			return true
-		}
+	logger.Info("starting miner")
+
+	if err := miner.Mine(); err != nil {
+		panic(err)
 	}
-	return false
+
 }
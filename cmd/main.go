@@ -1,18 +1,24 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"runtime/pprof"
 	config "sob-miner"
-	"sob-miner/internal/ierrors"
+	"sob-miner/internal/ingest"
 	"sob-miner/internal/mempool"
 	"sob-miner/internal/miner"
 	"sob-miner/internal/path"
+	"sob-miner/internal/report"
+	"sob-miner/pkg/chaincfg"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -36,9 +42,29 @@ func (p *ProgressBar) Play(cur int) {
 
 // main is the entry point of the program.
 // It removes the database file, initializes the mempool, and loads transactions from JSON files.
-// It handles panics and logs errors.
 // It also handles rejected transactions and calculates the elapsed time for loading transactions.
 func main() {
+	flag.StringVar(&config.Network, "network", config.Network, "network params to use: mainnet | testnet3 | signet | regtest")
+	backend := flag.String("backend", string(mempool.BackendSQL), "mempool backend: sql | memory [persisted to sqlite db] | bolt")
+	optimize := flag.Bool("optimize", false, "fill tail of block with knapsack optimizer instead of greedy picking")
+	persist := flag.Bool("persistmempool", false, "load mempool.dat on startup and save mempool to it on shutdown")
+	readers := flag.Int("readers", ingest.DefaultReaders, "workers reading tx files")
+	validators := flag.Int("validators", runtime.NumCPU(), "workers parsing and validating txs")
+	batch := flag.Int("batch", ingest.DefaultBatchSize, "txs admitted to mempool per write")
+	limitAncestors := flag.Int("limitancestorcount", mempool.DefaultLimits.Ancestors, "max in mempool ancestors of a tx, itself included")
+	limitAncestorSize := flag.Uint64("limitancestorsize", mempool.DefaultLimits.AncestorSize/1000, "max size of a tx with its in mempool ancestors in kvB")
+	limitDescendants := flag.Int("limitdescendantcount", mempool.DefaultLimits.Descendants, "max in mempool descendants of a tx, itself included")
+	limitDescendantSize := flag.Uint64("limitdescendantsize", mempool.DefaultLimits.DescendantSize/1000, "max size of a tx with its in mempool descendants in kvB")
+	maxOrphans := flag.Int("maxorphantx", ingest.DefaultMaxOrphans, "max txs kept waiting for parent files to load")
+	verifyScripts := flag.Bool("verifyscripts", false, "verify input scripts while loading txs instead of only when mining")
+	trace := flag.String("trace", "", "txid of a tx to trace through every validation stage")
+	traceFormat := flag.String("traceformat", "text", "format of printed trace: text | json")
+	flag.Parse()
+
+	params, err := chaincfg.ParamsByName(config.Network)
+	if err != nil {
+		panic(err)
+	}
 
 	f, err := os.Create("cpuprofile")
 	if err != nil {
@@ -51,12 +77,7 @@ func main() {
 	pprof.StartCPUProfile(f)
 
 	os.Remove(path.DBPath)
-
-	defer func() {
-		if err := recover(); err != nil {
-			logrus.Error(err)
-		}
-	}()
+	os.Remove(path.BoltDBPath)
 
 	logger := logrus.New()
 	// logger.SetLevel(logrus.InfoLevel)
@@ -71,6 +92,17 @@ func main() {
 		Logger:         logger,
 
 		Dust: uint64(config.Dust),
+
+		Params:  params,
+		Backend: mempool.Backend(*backend),
+		Path:    path.BoltDBPath,
+
+		Limits: mempool.Limits{
+			Ancestors:      *limitAncestors,
+			AncestorSize:   *limitAncestorSize * 1000,
+			Descendants:    *limitDescendants,
+			DescendantSize: *limitDescendantSize * 1000,
+		},
 	}
 
 	// init mempool
@@ -86,123 +118,123 @@ func main() {
 
 	logger.Info("mempool initialized")
 
-	// loop through all files in ./data/mempool
-	// unmarshal all json objects
-	files, err := os.ReadDir(path.MempoolDataPath)
+	rejections, err := report.CreateRejections(path.RejectionsPath)
 	if err != nil {
 		panic(err)
 	}
+	defer rejections.Close()
 
-	totalFiles := len(files)
-	logger.Info("loading ", totalFiles, " files")
-	pb := &ProgressBar{
-		Total:   totalFiles,
-		Current: 0,
-
-		rate: "#",
+	minerOpts := miner.Opts{
+		Logger:       logger,
+		MaxBlockSize: uint(config.MAX_BLOCK_SIZE),
+		Params:       params,
+		Optimize:     *optimize,
+		Rejections:   rejections,
 	}
 
-	wg := new(sync.WaitGroup)
-	doneChan := make(chan struct{})
-
-	rejectedTxFile := "rejected.txt"
-	if _, err := os.Stat(path.Root + "/" + rejectedTxFile); err == nil {
-		os.Remove(path.Root + "/" + rejectedTxFile)
+	// mainnet difficulty can't be mined locally, assignment target is used instead
+	if params.Name != chaincfg.MainNetParams.Name {
+		minerOpts.Chain = params.NewChain()
 	}
 
-	rejTxFile, err := os.OpenFile(path.Root+"/"+rejectedTxFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// payouts are checked against network before spending time on loading txs
+	miner, err := miner.New(pool, minerOpts)
 	if err != nil {
 		panic(err)
 	}
-	defer rejTxFile.Close()
 
-	acceptableErrs := []string{
-		ierrors.ErrAsmAndScriptMismatch.Error(),
-		ierrors.ErrFeeTooLow.Error(),
+	// interrupt stops ingestion, mempool is dumped by deferred save once pipeline and miner are done writing
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *persist {
+		if err := mempool.RestoreFile(pool, path.MempoolDatPath, params, logger); err != nil {
+			panic(err)
+		}
+		defer mempool.SaveFile(pool, path.MempoolDatPath, logger)
 	}
 
-	start := time.Now()
-	// TODO: spawing 8k go routines is bad and should be improved
-	// REASON: will spend more time context switching and GC rather than work
-	// SOLUTION: use a channel to distribute files to set of go routines
-	for i, file := range files {
+	// loop through all files in ./data/mempool
+	// unmarshal all json objects
+	files, err := os.ReadDir(path.MempoolDataPath)
+	if err != nil {
+		panic(err)
+	}
 
-		if file.IsDir() && strings.Split(file.Name(), ".")[1] != "json" {
+	paths := []string{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
 			logger.Info("skipping ", file.Name())
 			continue
 		}
+		paths = append(paths, filepath.Join(path.MempoolDataPath, file.Name()))
+	}
 
-		wg.Add(1)
-		go func(i int, file fs.DirEntry) {
-			defer func() {
-				doneChan <- struct{}{}
-				wg.Done()
-			}()
+	logger.Info("loading ", len(paths), " files")
+	pb := &ProgressBar{
+		Total:   len(paths),
+		Current: 0,
 
-			txData, err := os.ReadFile(path.MempoolDataPath + "/" + file.Name())
-			if err != nil {
-				panic(err)
-			}
+		rate: "#",
+	}
 
-			var tx mempool.Transaction
-			if err := json.Unmarshal(txData, &tx); err != nil {
-				panic(err)
+	pipeline := ingest.New(pool, ingest.Opts{
+		Logger:        logger,
+		Readers:       *readers,
+		Validators:    *validators,
+		BatchSize:     *batch,
+		Orphans:       mempool.OrphanOpts{MaxOrphans: *maxOrphans},
+		VerifyScripts: *verifyScripts,
+		Rejections:    rejections,
+		Trace:         *trace,
+		OnTrace:       func(t *mempool.Trace) { printTrace(t, *traceFormat) },
+		OnResult: func(file string, err error) {
+			pb.Current++
+			if pb.Current%1000 == 0 || pb.Current == pb.Total {
+				pb.Play(pb.Current)
 			}
 
-			if err := pool.PutTx(tx); err != nil {
-				logger.Info("processing ", file.Name())
-				rejTxFile.WriteString(file.Name() + " Reason: " + err.Error() + "\n")
-
-				if Contains(err.Error(), acceptableErrs) {
-					// logger.Info("press enter to continue")
-					// reader := bufio.NewReader(os.Stdin)
-					// _, _ = reader.ReadString('\n')
-					return
-				}
-				panic(err)
+			// malformed and invalid txs are only rejected, each with its reason in rejections report
+			if err != nil {
+				logger.Debug("rejected ", file, " ", err)
 			}
-			// pb.Current++
-			// pb.Play(pb.Current)
+		},
+	})
 
-		}(i, file)
+	metrics, err := pipeline.Run(ctx, paths)
+	fmt.Println("")
+	if errors.Is(err, context.Canceled) {
+		logger.Warn("interrupted after loading ", metrics.Accepted, " transactions, not mining")
+		return
 	}
-
-	for i := 0; i < totalFiles; i++ {
-		<-doneChan
-		pb.Current++
-		if pb.Current%1000 == 0 {
-			pb.Play(pb.Current)
-		}
+	if err != nil {
+		panic(err)
 	}
 
-	fmt.Println("")
-
-	wg.Wait()
-	elapsed := time.Since(start)
-	logger.Info("loaded ", len(files), " transactions into Mempool", " in ", elapsed.Seconds(), " seconds")
+	logger.Info("loaded ", metrics.Accepted, " transactions into Mempool", " in ", metrics.Elapsed.Seconds(), " seconds")
+	// logger only reports panics, metrics are printed below progress bar like traces
+	fmt.Println(metrics)
 
 	logger.Info("starting miner")
 
-	miner, err := miner.New(pool, miner.Opts{
-		Logger:       logger,
-		MaxBlockSize: uint(config.MAX_BLOCK_SIZE),
-	})
-
-	if err != nil {
-		panic(err)
-	}
-
 	if err := miner.Mine(); err != nil {
 		panic(err)
 	}
 
 }
 
-func Contains(target string, array []string) bool {
-	for _, element := range array {
-		if element == target {
-			return true
-		}
+// printTrace prints trace below progress bar
+func printTrace(t *mempool.Trace, format string) {
+	fmt.Println("")
+
+	var err error
+	switch format {
+	case "json":
+		err = t.WriteJSON(os.Stdout)
+	default:
+		err = t.WriteText(os.Stdout)
+	}
+	if err != nil {
+		logrus.Error("unable to print trace ", err)
 	}
-	return false
 }
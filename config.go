@@ -5,3 +5,21 @@ var MaxMemPoolSize uint = 1000_000_000 // 1 GB (1000 Mega bytes)
 var Dust uint = 546 // min fee in satoshis
 
 var MAX_BLOCK_SIZE int = 4_000_000
+
+// network to operate on mainnet | testnet3 | signet | regtest
+var Network string = "mainnet"
+
+// coinbase payout, reward is split across payouts proportional to Weight
+type Payout struct {
+	Address string
+	Weight  uint64
+}
+
+// addresses receiving block subsidy + fees by network name, any address type pkg/address understands.
+// an address only decodes on its own network, test networks share the same key hash
+var CoinbasePayouts = map[string][]Payout{
+	"mainnet":  {{Address: "18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PX", Weight: 1}},
+	"testnet3": {{Address: "mo88XQWwLKHFHcgaGwrqxvTQjb55E8TstY", Weight: 1}},
+	"signet":   {{Address: "mo88XQWwLKHFHcgaGwrqxvTQjb55E8TstY", Weight: 1}},
+	"regtest":  {{Address: "mo88XQWwLKHFHcgaGwrqxvTQjb55E8TstY", Weight: 1}},
+}
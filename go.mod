@@ -10,6 +10,7 @@ require (
 	github.com/onsi/ginkgo/v2 v2.17.0
 	github.com/onsi/gomega v1.32.0
 	github.com/sirupsen/logrus v1.9.3
+	go.etcd.io/bbolt v1.3.9
 	golang.org/x/crypto v0.18.0
 	gorm.io/driver/sqlite v1.5.5
 	gorm.io/gorm v1.25.8
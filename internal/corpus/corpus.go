@@ -0,0 +1,155 @@
+// Package corpus reads txs of mempool dir as seeds of fuzz targets. most of 8k txs are
+// alike, so a seed is kept per shape of tx or script and fuzzing starts in seconds
+package corpus
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sob-miner/internal/path"
+	"sort"
+	"strings"
+)
+
+// fields of mempool json holding scripts, the rest is left to mempool.Transaction
+type tx struct {
+	Vin []struct {
+		Prevout               output   `json:"prevout"`
+		ScriptSig             string   `json:"scriptsig"`
+		ScriptSigAsm          string   `json:"scriptsig_asm"`
+		Witness               []string `json:"witness"`
+		InnerRedeemScriptAsm  string   `json:"inner_redeemscript_asm"`
+		InnerWitnessScriptAsm string   `json:"inner_witnessscript_asm"`
+	} `json:"vin"`
+	Vout []output `json:"vout"`
+}
+
+type output struct {
+	ScriptPubKey     string `json:"scriptpubkey"`
+	ScriptPubKeyAsm  string `json:"scriptpubkey_asm"`
+	ScriptPubKeyType string `json:"scriptpubkey_type"`
+}
+
+// Txs returns json of a tx of mempool dir per set of input and output types, inputs
+// told apart by number of witness items too
+func Txs() ([][]byte, error) {
+	seeds := [][]byte{}
+	seen := map[string]bool{}
+
+	err := each(func(data []byte, t tx) {
+		ins, outs := map[string]bool{}, map[string]bool{}
+		for _, in := range t.Vin {
+			ins[fmt.Sprintf("%s/%d", in.Prevout.ScriptPubKeyType, len(in.Witness))] = true
+		}
+		for _, out := range t.Vout {
+			outs[out.ScriptPubKeyType] = true
+		}
+
+		key := strings.Join(keys(ins), ",") + " -> " + strings.Join(keys(outs), ",")
+		if !seen[key] {
+			seen[key] = true
+			seeds = append(seeds, data)
+		}
+	})
+	return seeds, err
+}
+
+// Scripts returns a script of mempool dir per ASM shape [opcodes with sizes of pushes] of
+// scriptpubkeys and scriptSigs, and a witness item per size
+func Scripts() ([][]byte, error) {
+	seeds := [][]byte{}
+	seen := map[string]bool{}
+	add := func(key, script string) {
+		if seen[key] {
+			return
+		}
+		if raw, err := hex.DecodeString(script); err == nil {
+			seen[key] = true
+			seeds = append(seeds, raw)
+		}
+	}
+
+	err := each(func(_ []byte, t tx) {
+		for _, in := range t.Vin {
+			add(shape(in.Prevout.ScriptPubKeyAsm), in.Prevout.ScriptPubKey)
+			add(shape(in.ScriptSigAsm), in.ScriptSig)
+			for _, item := range in.Witness {
+				add(fmt.Sprintf("witness %d", len(item)), item)
+			}
+		}
+		for _, out := range t.Vout {
+			add(shape(out.ScriptPubKeyAsm), out.ScriptPubKey)
+		}
+	})
+	return seeds, err
+}
+
+// Asm returns a script of mempool dir per ASM shape, inner redeem and witness scripts included
+func Asm() ([]string, error) {
+	seeds := []string{}
+	seen := map[string]bool{}
+	add := func(asm string) {
+		if key := shape(asm); !seen[key] {
+			seen[key] = true
+			seeds = append(seeds, asm)
+		}
+	}
+
+	err := each(func(_ []byte, t tx) {
+		for _, in := range t.Vin {
+			add(in.Prevout.ScriptPubKeyAsm)
+			add(in.ScriptSigAsm)
+			add(in.InnerRedeemScriptAsm)
+			add(in.InnerWitnessScriptAsm)
+		}
+		for _, out := range t.Vout {
+			add(out.ScriptPubKeyAsm)
+		}
+	})
+	return seeds, err
+}
+
+// shape replaces pushed data of asm with its size
+func shape(asm string) string {
+	tokens := strings.Fields(asm)
+	for i, token := range tokens {
+		if !strings.HasPrefix(token, "OP_") {
+			tokens[i] = fmt.Sprint(len(token) / 2)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+func keys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// each calls fn with every tx of mempool dir in name order
+func each(fn func(data []byte, t tx)) error {
+	paths, err := filepath.Glob(filepath.Join(path.MempoolDataPath, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		var t tx
+		if err := json.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(p), err)
+		}
+		fn(data, t)
+	}
+	return nil
+}
@@ -11,20 +11,45 @@ var (
 	ErrFeeTooLow    Err = fmt.Errorf("fee too low")
 	ErrInvalidTx    Err = fmt.Errorf("invalid transaction")
 	ErrTxTooLarge   Err = fmt.Errorf("transaction too large")
+	ErrNonStandard  Err = fmt.Errorf("non standard transaction")
 	ErrAlreadySpent Err = fmt.Errorf("output already spent")
 	ErrLowFee       Err = fmt.Errorf("fee too low")
 
 	ErrInvalidSequence      = errors.New("sequence number too high")
 	ErrInvalidOpCode        = errors.New("invalid opcode")
+	ErrMalformedPush        = errors.New("malformed push data")
 	ErrAsmAndScriptMismatch = errors.New("asm and script mismatch")
 	ErrInvalidScript        = errors.New("invalid script")
+	ErrScriptTypeMismatch   = errors.New("claimed script type does not match scriptpubkey")
 	ErrInvalidAddress       = errors.New("invalid address")
+	ErrWrongNetwork         = errors.New("address belongs to a different network")
 	ErrChecksum             = errors.New("checksum mismatch")
 
+	ErrNonCanonicalCompactSize = errors.New("non canonical compact size")
+	ErrTrailingBytes           = errors.New("trailing bytes after transaction")
+	ErrBadDumpVersion          = errors.New("unsupported mempool dump version")
+
 	ErrUsingOpReturnAsInput = errors.New("using OP_RETURN as input")
 	ErrScriptValidation     = errors.New("script validation error")
 
 	ErrInvalidSignature     = errors.New("invalid signature")
 	ErrRedeemScriptMismatch = errors.New("redeem script mismatch")
 	ErrInvalidWitnessLength = errors.New("invalid witness length")
+
+	ErrTxAlreadyExists   = errors.New("transaction already in mempool")
+	ErrTxNotFound        = errors.New("transaction not found")
+	ErrInvalidTxid       = errors.New("invalid txid")
+	ErrAncestorLimit     = errors.New("exceeds ancestor limits")
+	ErrDescendantLimit   = errors.New("exceeds descendant limits")
+	ErrBadPackage        = errors.New("malformed package")
+	ErrPackageFeeTooLow  = errors.New("package fee too low")
+	ErrMissingInputs     = errors.New("spends outputs of a transaction which was not admitted")
+	ErrOrphanTooLarge    = errors.New("orphan transaction too large")
+	ErrOrphanEvicted     = errors.New("orphan transaction evicted from full orphan pool")
+	ErrOrphanExpired     = errors.New("orphan transaction expired")
+	ErrCoinbaseInMempool = errors.New("coinbase transaction is not allowed in mempool")
+	ErrBadCoinbase       = errors.New("malformed coinbase transaction")
+	ErrBadCoinbaseValue  = errors.New("coinbase pays more than block subsidy plus fees")
+	ErrBadCoinbaseHeight = errors.New("coinbase script does not commit to block height")
+	ErrUnexpectedWitness = errors.New("witness before segwit activation")
 )
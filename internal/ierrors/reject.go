@@ -0,0 +1,164 @@
+package ierrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a machine readable rejection reason, named after bitcoind's reject reasons where there is one
+type Code string
+
+const (
+	// consensus, tx can never be mined as it is
+	CodeMalformed         Code = "malformed"
+	CodeInvalid           Code = "bad-txns"
+	CodeInBelowOut        Code = "bad-txns-in-belowout"
+	CodeMissingInputs     Code = "bad-txns-inputs-missingorspent"
+	CodeScript            Code = "mandatory-script-verify-flag-failed"
+	CodeCoinbase          Code = "coinbase"
+	CodeUnexpectedWitness Code = "unexpected-witness"
+
+	// policy, tx could be mined but isn't accepted into mempool
+	CodeMinFee        Code = "min-fee-not-met"
+	CodeNonStandard   Code = "non-standard"
+	CodeTxSize        Code = "tx-size"
+	CodeChainLimit    Code = "too-long-mempool-chain"
+	CodeAlreadyKnown  Code = "txn-already-in-mempool"
+	CodeOrphan        Code = "orphan"
+	CodeBadPackage    Code = "bad-package"
+	CodePackageFeeLow Code = "package-fee-too-low"
+
+	// error isn't one of ierrors, e.g. a file which can't be read
+	CodeUnknown Code = "unknown"
+)
+
+// Class tells whether a rejected tx is invalid or only not relayed
+type Class string
+
+const (
+	Consensus Class = "consensus"
+	Policy    Class = "policy"
+)
+
+func (c Code) Class() Class {
+	switch c {
+	case CodeMalformed, CodeInvalid, CodeInBelowOut, CodeMissingInputs, CodeScript, CodeCoinbase, CodeUnexpectedWitness:
+		return Consensus
+	case CodeMinFee, CodeNonStandard, CodeTxSize, CodeChainLimit, CodeAlreadyKnown, CodeOrphan, CodeBadPackage, CodePackageFeeLow:
+		return Policy
+	}
+	return ""
+}
+
+// codes of sentinel errors, first match wins
+var codes = []struct {
+	err  error
+	code Code
+}{
+	{ErrFeeTooLow, CodeMinFee},
+	{ErrLowFee, CodeMinFee},
+	{ErrPackageFeeTooLow, CodePackageFeeLow},
+	{ErrBadPackage, CodeBadPackage},
+	{ErrNonStandard, CodeNonStandard},
+	{ErrTxTooLarge, CodeTxSize},
+	{ErrAncestorLimit, CodeChainLimit},
+	{ErrDescendantLimit, CodeChainLimit},
+	{ErrTxAlreadyExists, CodeAlreadyKnown},
+	{ErrOrphanTooLarge, CodeOrphan},
+	{ErrOrphanEvicted, CodeOrphan},
+	{ErrOrphanExpired, CodeOrphan},
+
+	{ErrMissingInputs, CodeMissingInputs},
+	{ErrAlreadySpent, CodeMissingInputs},
+	{ErrCoinbaseInMempool, CodeCoinbase},
+	{ErrBadCoinbase, CodeCoinbase},
+	{ErrBadCoinbaseValue, CodeCoinbase},
+	{ErrBadCoinbaseHeight, CodeCoinbase},
+	{ErrUnexpectedWitness, CodeUnexpectedWitness},
+
+	{ErrInvalidScript, CodeScript},
+	{ErrScriptValidation, CodeScript},
+	{ErrInvalidSignature, CodeScript},
+	{ErrRedeemScriptMismatch, CodeScript},
+	{ErrInvalidWitnessLength, CodeScript},
+	{ErrUsingOpReturnAsInput, CodeScript},
+	{ErrInvalidOpCode, CodeScript},
+	{ErrMalformedPush, CodeScript},
+
+	{ErrAsmAndScriptMismatch, CodeMalformed},
+	{ErrScriptTypeMismatch, CodeMalformed},
+	{ErrInvalidAddress, CodeMalformed},
+	{ErrWrongNetwork, CodeMalformed},
+	{ErrChecksum, CodeMalformed},
+	{ErrNonCanonicalCompactSize, CodeMalformed},
+	{ErrTrailingBytes, CodeMalformed},
+	{ErrInvalidTxid, CodeMalformed},
+
+	{ErrInvalidTx, CodeInvalid},
+	{ErrInvalidSequence, CodeInvalid},
+}
+
+// RejectError is a rejection with its code, failing input and detail. it wraps Err so
+// errors.Is still matches sentinels and errors.As finds RejectError through fmt.Errorf wraps
+type RejectError struct {
+	Code   Code
+	Input  int // index of failing input, -1 when tx as a whole is rejected
+	Detail string
+	Err    error
+}
+
+// Reject wraps err rejecting a tx as a whole, an empty code is taken from err
+func Reject(code Code, err error, detail string) *RejectError {
+	return RejectInput(code, -1, err, detail)
+}
+
+// RejectInput wraps err rejecting input of a tx, an empty code is taken from err
+func RejectInput(code Code, input int, err error, detail string) *RejectError {
+	if code == "" {
+		code = CodeOf(err)
+	}
+	return &RejectError{Code: code, Input: input, Detail: detail, Err: err}
+}
+
+func (e *RejectError) Error() string {
+	msg := e.Err.Error()
+	if e.Input >= 0 {
+		msg = fmt.Sprintf("input %d: %s", e.Input, msg)
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+// CodeOf classifies err, a RejectError anywhere in chain wins over sentinels
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var rejectErr *RejectError
+	if errors.As(err, &rejectErr) && rejectErr.Code != "" {
+		return rejectErr.Code
+	}
+
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return CodeUnknown
+}
+
+// InputOf is index of input err rejects, false when it rejects tx as a whole
+func InputOf(err error) (int, bool) {
+	var rejectErr *RejectError
+	if errors.As(err, &rejectErr) && rejectErr.Input >= 0 {
+		return rejectErr.Input, true
+	}
+	return 0, false
+}
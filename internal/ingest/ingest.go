@@ -0,0 +1,305 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/report"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultReaders       = 4
+	DefaultBatchSize     = 256
+	DefaultFlushInterval = 50 * time.Millisecond
+
+	// files are read about in name order, so in mempool dir up to ~650 children wait for parents at once
+	DefaultMaxOrphans = 1000
+)
+
+// Pipeline loads json txs into mempool in three stages
+//
+//	readers -> validators -> writer
+//
+// readers and validators are bounded worker pools, validators run every admission check
+// which doesn't need mempool state in parallel. a single writer admits validated txs
+// in batches so mempool write lock and db transactions are taken once per batch.
+// files are expected to be named by txid, see writer for txs spending from other files.
+type Pipeline struct {
+	pool mempool.Mempool
+	opts Opts
+}
+
+// Metrics of a Run, stage durations are summed over workers of the stage
+type Metrics struct {
+	Files    int
+	Accepted int
+	Rejected int
+	Batches  int
+	Orphans  int // txs which waited for a parent
+	Packages int // low fee parents admitted with a child
+
+	Read     time.Duration
+	Validate time.Duration
+	Write    time.Duration
+	Elapsed  time.Duration
+}
+
+// TxsPerSecond is rate of files processed over whole run
+func (m Metrics) TxsPerSecond() float64 {
+	if m.Elapsed <= 0 {
+		return 0
+	}
+	return float64(m.Files) / m.Elapsed.Seconds()
+}
+
+func (m Metrics) String() string {
+	return fmt.Sprintf("%d files %d accepted %d rejected in %d batches, %d orphans %d packages, %.0f tx/s [read %v validate %v write %v elapsed %v]",
+		m.Files, m.Accepted, m.Rejected, m.Batches, m.Orphans, m.Packages, m.TxsPerSecond(), m.Read, m.Validate, m.Write, m.Elapsed)
+}
+
+type file struct {
+	name string
+	data []byte
+	err  error
+}
+
+type validated struct {
+	name     string
+	txid     string // empty when tx couldn't be parsed
+	tx       mempool.Transaction
+	prepared mempool.PreparedTx
+	stage    report.Stage
+	err      error
+}
+
+type result struct {
+	name  string
+	txid  string
+	stage report.Stage
+	err   error
+}
+
+func New(pool mempool.Mempool, opts Opts) *Pipeline {
+	if opts.Logger == nil {
+		opts.Logger = logrus.New()
+	}
+
+	if opts.Readers <= 0 {
+		opts.Readers = DefaultReaders
+	}
+
+	if opts.Validators <= 0 {
+		opts.Validators = runtime.NumCPU()
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+
+	if opts.Orphans.MaxOrphans <= 0 {
+		opts.Orphans.MaxOrphans = DefaultMaxOrphans
+	}
+
+	return &Pipeline{pool: pool, opts: opts}
+}
+
+// Run loads every file of paths into mempool, a file is rejected when it can't be
+// read, parsed or admitted. stops early with ctx's error when ctx is done
+func (p *Pipeline) Run(ctx context.Context, paths []string) (Metrics, error) {
+	start := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var metrics Metrics
+	var mu sync.Mutex // guards stage durations
+	track := func(d *time.Duration, since time.Time) {
+		mu.Lock()
+		*d += time.Since(since)
+		mu.Unlock()
+	}
+
+	jobs := make(chan string)
+	files := make(chan file, p.opts.Readers)
+	txs := make(chan validated, p.opts.BatchSize)
+	results := make(chan result, p.opts.BatchSize)
+
+	go func() {
+		defer close(jobs)
+		for _, path := range paths {
+			select {
+			case jobs <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	readers := new(sync.WaitGroup)
+	for i := 0; i < p.opts.Readers; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for path := range jobs {
+				now := time.Now()
+				data, err := os.ReadFile(path)
+				track(&metrics.Read, now)
+
+				select {
+				case files <- file{name: filepath.Base(path), data: data, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// traces of files named by traced txid, a name may be given more than once
+	var traces []*mempool.Trace
+
+	validators := new(sync.WaitGroup)
+	for i := 0; i < p.opts.Validators; i++ {
+		validators.Add(1)
+		go func() {
+			defer validators.Done()
+			for f := range files {
+				if p.traced(f.name) && f.err == nil {
+					t := p.pool.TraceTx(f.data)
+					mu.Lock()
+					traces = append(traces, t)
+					mu.Unlock()
+				}
+
+				now := time.Now()
+				v := p.validate(f)
+				track(&metrics.Validate, now)
+
+				select {
+				case txs <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		readers.Wait()
+		close(files)
+		validators.Wait()
+		close(txs)
+		close(workersDone)
+	}()
+
+	w := newWriter(p, paths, results, &metrics, track)
+	go func() {
+		defer close(results)
+		w.run(ctx, txs)
+	}()
+
+	for r := range results {
+		metrics.Files++
+		if r.err != nil {
+			metrics.Rejected++
+		} else {
+			metrics.Accepted++
+		}
+
+		if r.err != nil && p.opts.Rejections != nil {
+			if err := p.opts.Rejections.Add(report.NewRejection(r.stage, r.name, r.txid, r.err)); err != nil {
+				p.opts.Logger.Warn("unable to report rejection ", err)
+			}
+		}
+
+		if p.opts.OnResult != nil {
+			p.opts.OnResult(r.name, r.err)
+		}
+
+		if p.traced(r.name) {
+			p.finishTrace(&mu, &traces, r)
+		}
+	}
+	<-workersDone
+
+	if p.opts.Trace != "" && len(traces) > 0 {
+		p.opts.Logger.Warn("traced tx ", p.opts.Trace, " wasn't admitted or rejected")
+	}
+
+	metrics.Elapsed = time.Since(start)
+	p.opts.Logger.Info("ingested ", metrics)
+
+	return metrics, ctx.Err()
+}
+
+func (p *Pipeline) traced(name string) bool {
+	return p.opts.Trace != "" && fileTxid(name) == p.opts.Trace
+}
+
+// finishTrace records how traced file ended and hands its trace to OnTrace. a file
+// which couldn't be read has no trace
+func (p *Pipeline) finishTrace(mu *sync.Mutex, traces *[]*mempool.Trace, r result) {
+	mu.Lock()
+	if len(*traces) == 0 {
+		mu.Unlock()
+		p.opts.Logger.Warn("no trace of ", r.name, " ", r.err)
+		return
+	}
+	t := (*traces)[0]
+	*traces = (*traces)[1:]
+	mu.Unlock()
+
+	msg := "admitted"
+	if r.err != nil {
+		msg = fmt.Sprintf("rejected at %s", r.stage)
+	}
+	t.Add(mempool.TraceAdmit, msg, r.err)
+
+	if p.opts.OnTrace != nil {
+		p.opts.OnTrace(t)
+	}
+}
+
+func (p *Pipeline) validate(f file) validated {
+	if f.err != nil {
+		return validated{name: f.name, stage: report.StageRead, err: f.err}
+	}
+
+	var tx mempool.Transaction
+	if err := json.Unmarshal(f.data, &tx); err != nil {
+		return validated{name: f.name, stage: report.StageParse, err: ierrors.Reject(ierrors.CodeMalformed, err, "")}
+	}
+
+	return p.check(f.name, tx)
+}
+
+// check runs admission checks which don't need mempool state
+func (p *Pipeline) check(name string, tx mempool.Transaction) validated {
+	v := validated{name: name, tx: tx, stage: report.StageValidate}
+
+	v.prepared, v.err = p.pool.PrepareTx(tx)
+	if v.err == nil && p.opts.VerifyScripts {
+		v.err = tx.ValidateTxScripts()
+	}
+
+	if v.err == nil {
+		v.txid = mempool.HashToTxid(v.prepared.Tx.Hash)
+	} else {
+		// hashing fails on txs serialization can't handle
+		v.txid, _ = tx.Txid()
+	}
+	return v
+}
@@ -0,0 +1,257 @@
+package ingest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/ingest"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/mempool/mempooltest"
+	"sob-miner/internal/path"
+	"sob-miner/internal/report"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var dataset = []string{
+	"2d4d450afe9432440531531c06b407a65fac6378bc68bf9b7bdf5f610cf9a352.json", // parent
+	"1cbd72230995f87cf262ce4a85a69c737e863b3d67cb69c666a0295bec5d07f5.json", // child of parent
+	"004947e806c5afa74ea4b64de0bfe63bb7488c2c3e4e5d4d5d6c8403d16de46a.json", // p2pkh
+	"0116cb33d4af228a15d3f2951370c24b3da23274e9835307707067ec7422640c.json", // p2sh-p2wsh
+}
+
+// grand parent -> parent -> child
+var chainFiles = []string{
+	"11af62a102b611ecb44523dda00d88333c60a82dad37d1b788dce1bb37796109.json",
+	"278fe7a833141ed5e770d3f204cf837137243c59b083fa703486785d9be95cb5.json",
+	"9a0eb20e008c91db791295e0e491241e738005679a943463b727b2d4941940a6.json",
+}
+
+// parent pays less than min fee, its only child pays for both
+const (
+	lowFeeParentFile = "e6d870ecd3e78026506da94b80411048053758e2f8f248061b553bfe15e76392.json"
+	payingChildFile  = "30808259fd38cf81b921cbba2755620032aead75bcaebcd3521f3683981e9b48.json"
+)
+
+func dataPaths(names ...string) []string {
+	paths := []string{}
+	for _, name := range names {
+		paths = append(paths, filepath.Join(path.MempoolDataPath, name))
+	}
+	return paths
+}
+
+func silentLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return logger
+}
+
+func datasetPaths() []string {
+	return dataPaths(dataset...)
+}
+
+var _ = Describe("Pipeline", func() {
+	newPool := func(backend mempool.Backend) mempool.Mempool {
+		pool, err := mempooltest.New(backend, GinkgoT().TempDir(), mempool.Opts{Logger: silentLogger(), Dust: 546})
+		Expect(err).To(BeNil())
+		DeferCleanup(pool.Close)
+		return pool
+	}
+
+	for _, backend := range mempooltest.Backends {
+		backend := backend
+
+		It("should load files into "+string(backend)+" mempool and report rejections", func() {
+			dir := GinkgoT().TempDir()
+			pool := newPool(backend)
+
+			broken := filepath.Join(dir, "broken.json")
+			Expect(os.WriteFile(broken, []byte("{"), 0644)).To(Succeed())
+
+			paths := append(datasetPaths(), broken, filepath.Join(dir, "missing.json"), datasetPaths()[0])
+
+			rejected := map[string]error{}
+			var buf bytes.Buffer
+			metrics, err := ingest.New(pool, ingest.Opts{
+				Logger:     silentLogger(),
+				Readers:    2,
+				Validators: 3,
+				BatchSize:  2,
+				Rejections: report.NewRejections(&buf),
+				OnResult: func(file string, err error) {
+					if err != nil {
+						rejected[file] = err
+					}
+				},
+			}).Run(context.Background(), paths)
+			Expect(err).To(BeNil())
+
+			Expect(metrics.Files).To(Equal(len(paths)))
+			Expect(metrics.Accepted).To(Equal(len(dataset)))
+			Expect(metrics.Rejected).To(Equal(3))
+			Expect(metrics.Batches).To(BeNumerically(">=", 3))
+			Expect(metrics.TxsPerSecond()).To(BeNumerically(">", 0))
+
+			Expect(rejected).To(HaveKey("broken.json"))
+			Expect(rejected["missing.json"]).To(MatchError(os.ErrNotExist))
+			Expect(rejected[dataset[0]]).To(MatchError(ierrors.ErrTxAlreadyExists))
+
+			rejections, err := report.ReadRejections(&buf)
+			Expect(err).To(BeNil())
+			Expect(rejections).To(ConsistOf(
+				report.Rejection{File: "broken.json", Stage: report.StageParse, Code: ierrors.CodeMalformed, Class: ierrors.Consensus, Message: rejected["broken.json"].Error()},
+				report.Rejection{File: "missing.json", Stage: report.StageRead, Code: ierrors.CodeUnknown, Message: rejected["missing.json"].Error()},
+				report.Rejection{Txid: strings.TrimSuffix(dataset[0], ".json"), File: dataset[0], Stage: report.StageAdmit, Code: ierrors.CodeAlreadyKnown, Class: ierrors.Policy, Message: rejected[dataset[0]].Error()},
+			))
+
+			txs, err := pool.Txs()
+			Expect(err).To(BeNil())
+			Expect(txs).To(HaveLen(len(dataset)))
+		})
+	}
+
+	It("should reject txs with invalid scripts when verifying them", func() {
+		dir := GinkgoT().TempDir()
+
+		data, err := os.ReadFile(datasetPaths()[2])
+		Expect(err).To(BeNil())
+
+		var tx mempool.Transaction
+		Expect(json.Unmarshal(data, &tx)).To(Succeed())
+
+		// change a digit of p2pkh signature's s value
+		sig := strings.Split(tx.Vin[0].ScriptSigAsm, " ")[1]
+		forgedSig := sig[:len(sig)-10] + string("01"[sig[len(sig)-10]%2]) + sig[len(sig)-9:]
+		tx.Vin[0].ScriptSigAsm = strings.Replace(tx.Vin[0].ScriptSigAsm, sig, forgedSig, 1)
+		tx.Vin[0].ScriptSig = strings.Replace(tx.Vin[0].ScriptSig, sig, forgedSig, 1)
+
+		data, err = json.Marshal(tx)
+		Expect(err).To(BeNil())
+		forged := filepath.Join(dir, "forged.json")
+		Expect(os.WriteFile(forged, data, 0644)).To(Succeed())
+
+		// scriptSig without signature and pubkey
+		tx.Vin[0].ScriptSigAsm = ""
+		data, err = json.Marshal(tx)
+		Expect(err).To(BeNil())
+		truncated := filepath.Join(dir, "truncated.json")
+		Expect(os.WriteFile(truncated, data, 0644)).To(Succeed())
+
+		pool := newPool(mempool.BackendMemory)
+		rejected := map[string]error{}
+		metrics, err := ingest.New(pool, ingest.Opts{
+			Logger:        silentLogger(),
+			VerifyScripts: true,
+			OnResult: func(file string, err error) {
+				if err != nil {
+					rejected[file] = err
+				}
+			},
+		}).Run(context.Background(), []string{forged, truncated, datasetPaths()[0]})
+		Expect(err).To(BeNil())
+		Expect(metrics.Accepted).To(Equal(1))
+		Expect(rejected).To(HaveKey("forged.json"))
+		Expect(ierrors.CodeOf(rejected["truncated.json"])).To(Equal(ierrors.CodeScript))
+	})
+
+	Describe("orphans", func() {
+		// files are handled one by one in order of paths
+		run := func(paths []string, orphans mempool.OrphanOpts) (ingest.Metrics, map[string]error, mempool.Mempool) {
+			pool := newPool(mempool.BackendMemory)
+			rejected := map[string]error{}
+			metrics, err := ingest.New(pool, ingest.Opts{
+				Logger:     silentLogger(),
+				Readers:    1,
+				Validators: 1,
+				Orphans:    orphans,
+				OnResult: func(file string, err error) {
+					if err != nil {
+						rejected[file] = err
+					}
+				},
+			}).Run(context.Background(), paths)
+			Expect(err).To(BeNil())
+			Expect(metrics.Files).To(Equal(len(paths)))
+			return metrics, rejected, pool
+		}
+
+		It("should hold children until their parents are admitted", func() {
+			metrics, rejected, pool := run(dataPaths(chainFiles[2], chainFiles[1], chainFiles[0]), mempool.OrphanOpts{})
+			Expect(rejected).To(BeEmpty())
+			Expect(metrics.Accepted).To(Equal(3))
+			Expect(metrics.Orphans).To(Equal(2))
+			Expect(pool.Txs()).To(HaveLen(3))
+		})
+
+		It("should reject orphans of a rejected parent", func() {
+			dir := GinkgoT().TempDir()
+			broken := filepath.Join(dir, chainFiles[0])
+			Expect(os.WriteFile(broken, []byte("{"), 0644)).To(Succeed())
+
+			metrics, rejected, _ := run(append(dataPaths(chainFiles[2], chainFiles[1]), broken), mempool.OrphanOpts{})
+			Expect(metrics.Accepted).To(BeZero())
+			Expect(rejected[chainFiles[1]]).To(MatchError(ierrors.ErrMissingInputs))
+			Expect(rejected[chainFiles[2]]).To(MatchError(ierrors.ErrMissingInputs))
+		})
+
+		It("should evict orphans from a full pool", func() {
+			metrics, rejected, _ := run(dataPaths(chainFiles[2], chainFiles[1], chainFiles[0]), mempool.OrphanOpts{MaxOrphans: 1})
+			Expect(metrics.Accepted).To(Equal(2))
+			Expect(rejected).To(HaveLen(1))
+			Expect(rejected[chainFiles[2]]).To(MatchError(ierrors.ErrOrphanEvicted))
+		})
+
+		It("should admit a low fee parent with its child in any order", func() {
+			for _, names := range [][]string{{lowFeeParentFile, payingChildFile}, {payingChildFile, lowFeeParentFile}} {
+				metrics, rejected, _ := run(dataPaths(names...), mempool.OrphanOpts{})
+				Expect(rejected).To(BeEmpty())
+				Expect(metrics.Accepted).To(Equal(2))
+				Expect(metrics.Packages).To(Equal(1))
+			}
+
+			_, rejected, _ := run(dataPaths(lowFeeParentFile), mempool.OrphanOpts{})
+			Expect(rejected[lowFeeParentFile]).To(MatchError(ierrors.ErrFeeTooLow))
+		})
+	})
+
+	It("should trace a file through validation and admission", func() {
+		traces := []*mempool.Trace{}
+		pool := newPool(mempool.BackendMemory)
+		metrics, err := ingest.New(pool, ingest.Opts{
+			Logger:     silentLogger(),
+			Readers:    1,
+			Validators: 1,
+			Trace:      strings.TrimSuffix(lowFeeParentFile, ".json"),
+			OnTrace:    func(t *mempool.Trace) { traces = append(traces, t) },
+		}).Run(context.Background(), dataPaths(lowFeeParentFile, payingChildFile))
+		Expect(err).To(BeNil())
+		Expect(metrics.Packages).To(Equal(1))
+
+		Expect(traces).To(HaveLen(1))
+		steps := traces[0].Steps
+		Expect(steps[len(steps)-2].Stage).To(Equal(mempool.TraceFee))
+		Expect(steps[len(steps)-2].Err).NotTo(BeEmpty())
+
+		// admitted with child paying for it
+		Expect(steps[len(steps)-1].Stage).To(Equal(mempool.TraceAdmit))
+		Expect(steps[len(steps)-1].Err).To(BeEmpty())
+		Expect(traces[0].Failed()).To(BeFalse())
+	})
+
+	It("should stop on cancelled context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		pool := newPool(mempool.BackendMemory)
+		_, err := ingest.New(pool, ingest.Opts{Logger: silentLogger()}).Run(ctx, datasetPaths())
+		Expect(err).To(MatchError(context.Canceled))
+	})
+})
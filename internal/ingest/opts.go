@@ -0,0 +1,41 @@
+package ingest
+
+import (
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/report"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Opts struct {
+	Logger *logrus.Logger
+
+	// workers reading files and workers parsing and validating them,
+	// default to DefaultReaders and runtime.NumCPU()
+	Readers    int
+	Validators int
+
+	// txs admitted per mempool write, a partial batch is written after FlushInterval.
+	// default to DefaultBatchSize and DefaultFlushInterval
+	BatchSize     int
+	FlushInterval time.Duration
+
+	// limits of pool holding txs until files of their parents are admitted,
+	// MaxOrphans defaults to DefaultMaxOrphans
+	Orphans mempool.OrphanOpts
+
+	// also verify input scripts while validating, otherwise they are only verified by miner
+	VerifyScripts bool
+
+	// optional, every rejected file is reported to it with its stage
+	Rejections *report.Rejections
+
+	// optional, called from Run's goroutine once per file, err is nil for an admitted tx
+	OnResult func(file string, err error)
+
+	// optional, file of txid Trace is also traced through every validation stage and
+	// OnTrace gets the trace once file is admitted or rejected
+	Trace   string
+	OnTrace func(*mempool.Trace)
+}
@@ -0,0 +1,308 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/report"
+	"sort"
+	"strings"
+	"time"
+)
+
+// writer admits validated txs in batches, a batch is flushed when full or when no tx
+// arrived for FlushInterval.
+//
+// files are read and validated in no particular order, so a child may get here before its
+// parent. a tx spending from a file of this run which isn't admitted yet waits in orphan pool
+// and is checked again once parent is admitted, or rejected with ErrMissingInputs when parent
+// is rejected. a parent rejected for fee waits till end of run in case an orphan child pays
+// for it, the two are then submitted as a package
+type writer struct {
+	p       *Pipeline
+	results chan<- result
+	metrics *Metrics
+	track   func(*time.Duration, time.Time)
+
+	orphans *mempool.OrphanPool
+	// files by txid not admitted or rejected yet, and txids admitted
+	pending  map[string]int
+	admitted map[string]bool
+	// parents rejected for fee by txid, reported at end of run unless a child paid for them
+	lowFee map[string]validated
+
+	batch []validated
+	// orphans whose parents were admitted, checked again on flush
+	retry []mempool.Orphan
+}
+
+func newWriter(p *Pipeline, paths []string, results chan<- result, metrics *Metrics, track func(*time.Duration, time.Time)) *writer {
+	w := &writer{
+		p:        p,
+		results:  results,
+		metrics:  metrics,
+		track:    track,
+		orphans:  mempool.NewOrphanPool(p.opts.Orphans),
+		pending:  map[string]int{},
+		admitted: map[string]bool{},
+		lowFee:   map[string]validated{},
+	}
+
+	for _, path := range paths {
+		w.pending[fileTxid(filepath.Base(path))]++
+	}
+	return w
+}
+
+// txid a file is named by
+func fileTxid(name string) string {
+	return strings.TrimSuffix(name, ".json")
+}
+
+func (w *writer) run(ctx context.Context, txs <-chan validated) {
+	timer := time.NewTimer(w.p.opts.FlushInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case v, ok := <-txs:
+			if !ok {
+				w.finish()
+				return
+			}
+
+			w.handle(v)
+			if len(w.batch) >= w.p.opts.BatchSize {
+				w.flush()
+			}
+		case <-timer.C:
+			w.flush()
+		case <-ctx.Done():
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(w.p.opts.FlushInterval)
+	}
+}
+
+func (w *writer) handle(v validated) {
+	if v.err == nil {
+		w.admit(v)
+		return
+	}
+
+	txid := fileTxid(v.name)
+	if _, ok := w.lowFee[txid]; !ok && ierrors.CodeOf(v.err) == ierrors.CodeMinFee {
+		w.lowFee[txid] = v
+		w.payFor(txid)
+		return
+	}
+	w.reject(v.name, v.txid, v.stage, v.err)
+}
+
+// admit batches v or keeps it as orphan when it spends from a file which isn't admitted yet
+func (w *writer) admit(v validated) {
+	parents := w.missing(v)
+	if len(parents) == 1 {
+		if parent, ok := w.lowFee[parents[0]]; ok && w.submitPackage(parent, v.tx, v.name, v.txid) {
+			return
+		}
+		parents = w.missing(v)
+	}
+
+	if len(parents) == 0 {
+		w.batch = append(w.batch, v)
+		return
+	}
+
+	evicted, err := w.orphans.Add(v.tx, v.name, parents, time.Now())
+	if err != nil {
+		w.reject(v.name, v.txid, report.StageOrphan, err)
+		return
+	}
+	w.metrics.Orphans++
+
+	for _, o := range evicted {
+		w.reject(o.Source, o.Txid, report.StageOrphan, ierrors.ErrOrphanEvicted)
+	}
+}
+
+// txids of files v spends from which aren't admitted yet
+func (w *writer) missing(v validated) []string {
+	self := fileTxid(v.name)
+	seen := map[string]bool{}
+	parents := []string{}
+
+	for _, in := range v.tx.Vin {
+		if in.Txid == self || seen[in.Txid] || w.admitted[in.Txid] || w.pending[in.Txid] == 0 {
+			continue
+		}
+		seen[in.Txid] = true
+		parents = append(parents, in.Txid)
+	}
+	return parents
+}
+
+// payFor submits low fee parent txid with first orphan child waiting only for it
+func (w *writer) payFor(txid string) {
+	parent := w.lowFee[txid]
+
+	for _, child := range w.orphans.Children(txid) {
+		if len(child.Parents) != 1 {
+			continue
+		}
+
+		// child would be resolved by its own parent otherwise
+		w.orphans.Remove(child.Txid)
+		if w.submitPackage(parent, child.Tx, child.Source, child.Txid) {
+			return
+		}
+
+		// parent got in alone, child is checked again on its own
+		if w.admitted[txid] {
+			w.retry = append(w.retry, child)
+			return
+		}
+
+		if _, err := w.orphans.Add(child.Tx, child.Source, child.Parents, time.Now()); err != nil {
+			w.reject(child.Source, child.Txid, report.StageOrphan, err)
+		}
+	}
+}
+
+// submitPackage admits parent with child paying for it, reports whether child was admitted
+func (w *writer) submitPackage(parent validated, child mempool.Transaction, childName, childTxid string) bool {
+	now := time.Now()
+	res, err := w.p.pool.SubmitPackage([]mempool.Transaction{parent.tx, child})
+	w.track(&w.metrics.Write, now)
+	if err != nil {
+		return false
+	}
+
+	if res.Errs[0] == nil {
+		delete(w.lowFee, fileTxid(parent.name))
+		w.accept(parent.name, parent.txid)
+	}
+	if res.Errs[1] != nil {
+		return false
+	}
+
+	w.metrics.Packages++
+	w.accept(childName, childTxid)
+	return true
+}
+
+// flush writes batch in chunks of BatchSize along with orphans its txs resolved
+func (w *writer) flush() {
+	for len(w.batch) > 0 || len(w.retry) > 0 {
+		if n := len(w.batch); n > 0 {
+			if n > w.p.opts.BatchSize {
+				n = w.p.opts.BatchSize
+			}
+			batch := w.batch[:n:n]
+			w.batch = w.batch[n:]
+			w.write(batch)
+		}
+
+		retry := w.retry
+		w.retry = nil
+		for _, o := range retry {
+			now := time.Now()
+			v := w.p.check(o.Source, o.Tx)
+			w.track(&w.metrics.Validate, now)
+			w.handle(v)
+		}
+	}
+
+	for _, o := range w.orphans.Expire(time.Now()) {
+		w.reject(o.Source, o.Txid, report.StageOrphan, ierrors.ErrOrphanExpired)
+	}
+}
+
+func (w *writer) write(batch []validated) {
+	prepared := make([]mempool.PreparedTx, len(batch))
+	for i, v := range batch {
+		prepared[i] = v.prepared
+	}
+
+	now := time.Now()
+	errs := w.p.pool.AcceptTxs(prepared)
+	w.track(&w.metrics.Write, now)
+	w.metrics.Batches++
+
+	for i, err := range errs {
+		v := batch[i]
+		switch {
+		case err == nil:
+			w.accept(v.name, v.txid)
+		case errors.Is(err, ierrors.ErrTxAlreadyExists):
+			// children can spend from it all the same
+			w.results <- result{name: v.name, txid: v.txid, stage: report.StageAdmit, err: err}
+			w.settle(v.name, true)
+		default:
+			w.reject(v.name, v.txid, report.StageAdmit, err)
+		}
+	}
+}
+
+// finish flushes what's left and rejects low fee parents no child paid for, with orphans still waiting
+func (w *writer) finish() {
+	w.flush()
+
+	txids := make([]string, 0, len(w.lowFee))
+	for txid := range w.lowFee {
+		txids = append(txids, txid)
+	}
+	sort.Strings(txids)
+
+	for _, txid := range txids {
+		v := w.lowFee[txid]
+		delete(w.lowFee, txid)
+		w.reject(v.name, v.txid, v.stage, v.err)
+	}
+
+	for _, o := range w.orphans.Drain() {
+		w.reject(o.Source, o.Txid, report.StageOrphan, fmt.Errorf("%w: %s", ierrors.ErrMissingInputs, strings.Join(o.Parents, ", ")))
+	}
+}
+
+func (w *writer) accept(name, txid string) {
+	w.results <- result{name: name, txid: txid}
+	w.settle(name, true)
+}
+
+func (w *writer) reject(name, txid string, stage report.Stage, err error) {
+	w.results <- result{name: name, txid: txid, stage: stage, err: err}
+	w.settle(name, false)
+}
+
+// settle marks a file done, orphans of an admitted tx are checked again on flush and
+// orphans of a tx no file is left to admit are rejected along with their own orphans
+func (w *writer) settle(name string, admitted bool) {
+	txid := fileTxid(name)
+	if w.pending[txid] > 0 {
+		w.pending[txid]--
+	}
+
+	if admitted {
+		w.admitted[txid] = true
+		w.retry = append(w.retry, w.orphans.Resolve(txid)...)
+		return
+	}
+
+	if w.pending[txid] > 0 || w.admitted[txid] {
+		return
+	}
+	for _, o := range w.orphans.Fail(txid) {
+		w.reject(o.Source, o.Txid, report.StageOrphan, fmt.Errorf("%w: %s", ierrors.ErrMissingInputs, txid))
+	}
+}
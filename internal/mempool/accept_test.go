@@ -0,0 +1,97 @@
+package mempool_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/pkg/transaction"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL Admission", func() {
+	newPool := func(dust uint64) mempool.Mempool {
+		pool, err := mempool.New(sqlite.Open(filepath.Join(GinkgoT().TempDir(), "mempool.db")), mempool.Opts{Logger: silentLogger(), Dust: dust},
+			&gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+		Expect(err).To(BeNil())
+		return pool
+	}
+
+	prepare := func(pool mempool.Mempool, name string) mempool.PreparedTx {
+		p, err := pool.PrepareTx(readTx(name))
+		Expect(err).To(BeNil())
+		return p
+	}
+
+	// rows of txs spending from or funded by hash
+	rows := func(pool mempool.Mempool, hash string) (inputs int64, outputs int64) {
+		Expect(pool.DB().Model(&transaction.InputTx{}).Where("spending_tx_hash = ?", hash).Count(&inputs).Error).To(Succeed())
+		Expect(pool.DB().Model(&transaction.OutPutTx{}).Where("funding_tx_hash = ?", hash).Count(&outputs).Error).To(Succeed())
+		return inputs, outputs
+	}
+
+	It("should not leave rows of txs failing admission", func() {
+		pool := newPool(1_000_000_000)
+		err := pool.PutTx(readTx(p2pkhFile))
+		Expect(err).To(MatchError(ierrors.ErrFeeTooLow))
+		Expect(ierrors.CodeOf(err)).To(Equal(ierrors.CodeMinFee))
+
+		var count int64
+		Expect(pool.DB().Model(&transaction.InputTx{}).Count(&count).Error).To(Succeed())
+		Expect(count).To(BeZero())
+		Expect(pool.DB().Model(&transaction.OutPutTx{}).Count(&count).Error).To(Succeed())
+		Expect(count).To(BeZero())
+	})
+
+	It("should reject duplicates within and across batches", func() {
+		pool := newPool(546)
+
+		errs := pool.AcceptTxs([]mempool.PreparedTx{prepare(pool, parentFile), prepare(pool, parentFile), prepare(pool, childFile)})
+		Expect(errs[0]).To(BeNil())
+		Expect(errs[1]).To(MatchError(ierrors.ErrTxAlreadyExists))
+		Expect(errs[2]).To(BeNil())
+
+		errs = pool.AcceptTxs([]mempool.PreparedTx{prepare(pool, parentFile)})
+		Expect(errs[0]).To(MatchError(ierrors.ErrTxAlreadyExists))
+
+		txs, err := pool.Txs()
+		Expect(err).To(BeNil())
+		Expect(txs).To(HaveLen(2))
+	})
+
+	It("should roll back only failing tx of a batch", func() {
+		pool := newPool(546)
+		failing := mempool.HashToTxid(strings.TrimSuffix(p2pkhFile, ".json"))
+
+		// outputs are written after tx and input rows, those have to be rolled back
+		Expect(pool.DB().Exec(fmt.Sprintf(`CREATE TRIGGER fail_outputs BEFORE INSERT ON out_put_txes
+			WHEN NEW.funding_tx_hash = '%s' BEGIN SELECT RAISE(ABORT, 'forced failure'); END;`, failing)).Error).To(Succeed())
+
+		errs := pool.AcceptTxs([]mempool.PreparedTx{prepare(pool, parentFile), prepare(pool, p2pkhFile), prepare(pool, p2shP2wshFile)})
+		Expect(errs[0]).To(BeNil())
+		Expect(errs[1]).To(MatchError(ContainSubstring("forced failure")))
+		Expect(errs[2]).To(BeNil())
+
+		txs, err := pool.Txs()
+		Expect(err).To(BeNil())
+		Expect(txs).To(HaveLen(2))
+
+		inputs, outputs := rows(pool, failing)
+		Expect(inputs).To(BeZero())
+		Expect(outputs).To(BeZero())
+
+		Expect(pool.DB().Exec("DROP TRIGGER fail_outputs").Error).To(Succeed())
+		Expect(pool.PutTx(readTx(p2pkhFile))).To(Succeed())
+
+		inputs, outputs = rows(pool, failing)
+		Expect(inputs).To(BeNumerically(">", 0))
+		Expect(outputs).To(BeNumerically(">", 0))
+	})
+})
@@ -0,0 +1,76 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/chaincfg"
+)
+
+const nullTxid = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// IsCoinbase reports whether tx has a single input spending the null outpoint
+func (t *Transaction) IsCoinbase() bool {
+	return len(t.Vin) == 1 && t.Vin[0].Txid == nullTxid && t.Vin[0].Vout == 0xffffffff
+}
+
+// CoinbaseHeightScript returns BIP34 scriptSig prefix committing to block height,
+// a minimally encoded script number push, padded with OP_0 to 2 bytes minimum scriptSig length
+func CoinbaseHeightScript(height uint32) []byte {
+	if height == 0 {
+		return []byte{0x00, 0x00}
+	}
+
+	if height <= 16 {
+		return []byte{0x50 + byte(height), 0x00} // OP_N + OP_0
+	}
+
+	num := []byte{}
+	for h := height; h > 0; h >>= 8 {
+		num = append(num, byte(h&0xff))
+	}
+
+	// keep number positive when high bit of last byte is set
+	if num[len(num)-1]&0x80 != 0 {
+		num = append(num, 0x00)
+	}
+
+	return append([]byte{byte(len(num))}, num...)
+}
+
+// ValidateCoinbase checks coinbase structure, its BIP34 height commitment and
+// that it does not claim more than block subsidy at height plus fees collected
+func ValidateCoinbase(tx Transaction, height uint32, fees uint64, params *chaincfg.Params) error {
+	if !tx.IsCoinbase() || len(tx.Vout) == 0 {
+		return ierrors.ErrBadCoinbase
+	}
+
+	scriptSig, err := hex.DecodeString(tx.Vin[0].ScriptSig)
+	if err != nil || len(scriptSig) < 2 || len(scriptSig) > 100 {
+		return ierrors.ErrBadCoinbase
+	}
+
+	// small heights are a single OP_N, padding OP_0 is not part of commitment
+	heightScript := CoinbaseHeightScript(height)
+	if height <= 16 {
+		heightScript = heightScript[:1]
+	}
+
+	if !bytes.HasPrefix(scriptSig, heightScript) {
+		return ierrors.ErrBadCoinbaseHeight
+	}
+
+	var claimed uint64
+	for _, out := range tx.Vout {
+		claimed += out.Value
+		if claimed < out.Value {
+			return ierrors.ErrBadCoinbaseValue // overflow
+		}
+	}
+
+	if claimed > params.BlockSubsidy(height)+fees {
+		return ierrors.ErrBadCoinbaseValue
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package mempool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/chaincfg"
+	en "sob-miner/pkg/encoding"
+	"sob-miner/pkg/transaction"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// version 1 has no trailing fee deltas
+const DumpVersion uint64 = 2
+
+// LoadResult counts txs read from a dump
+type LoadResult struct {
+	Accepted     int
+	Failed       int
+	AlreadyThere int
+}
+
+// Dump writes every tx of m to w in admission order, same mempool always gives same bytes.
+// integers are little endian
+//
+//	version     u64
+//	count       u64
+//	count times:
+//	  tx          wire format, witness serialization when it has witnesses
+//	  prevouts    value (u64) + CompactSize prefixed scriptpubkey, one per input
+//	  entry time  i64 unix seconds
+//	  fee delta   i64
+//	deltas      u64
+//	deltas times, prioritisations of txs not in mempool ordered by hash:
+//	  hash        32 bytes
+//	  fee delta   i64
+//
+// prevouts are carried as there's no utxo set to look them up in on load
+func Dump(m Mempool, w io.Writer) (int, error) {
+	txs, err := m.Txs()
+	if err != nil {
+		return 0, err
+	}
+
+	// deltas by hash, dumped ones are removed so only pending ones are left
+	deltas := map[string]int64{}
+	for txid, delta := range m.FeeDeltas() {
+		deltas[txidToHash(txid)] = delta
+	}
+
+	buf := en.NewLEBuffer()
+	buf.Set(DumpVersion)
+	buf.Set(uint64(len(txs)))
+
+	for _, tx := range txs {
+		delete(deltas, tx.Hash)
+
+		inputs, err := m.GetInputs(tx.Hash)
+		if err != nil {
+			return 0, err
+		}
+
+		prevOuts := []transaction.OutPutTx{}
+		for _, input := range inputs {
+			prevOut, err := m.GetOutPointByIndex(input.FundingTxHash, input.FundingIndex)
+			if err != nil {
+				return 0, err
+			}
+			prevOuts = append(prevOuts, prevOut)
+		}
+
+		outputs, err := m.GetOutputs(tx.Hash)
+		if err != nil {
+			return 0, err
+		}
+		sort.Slice(outputs, func(i, j int) bool { return outputs[i].FundingTxPos < outputs[j].FundingTxPos })
+
+		wholeTx := assembleTx(tx, inputs, prevOuts, outputs)
+		_, raw, _, err := wholeTx.Serialize()
+		if err != nil {
+			return 0, err
+		}
+		buf.SetBytes(raw, false)
+
+		for _, in := range wholeTx.Vin {
+			scriptPubKey, err := hex.DecodeString(in.Prevout.ScriptPubKey)
+			if err != nil {
+				return 0, ierrors.ErrInvalidScript
+			}
+
+			buf.Set(in.Prevout.Value)
+			buf.SetBytes(en.CompactSize(uint64(len(scriptPubKey))), false)
+			buf.SetBytes(scriptPubKey, false)
+		}
+
+		buf.Set(tx.CreatedAt.Unix())
+		buf.Set(tx.FeeDelta)
+	}
+
+	pending := make([]string, 0, len(deltas))
+	for hash := range deltas {
+		pending = append(pending, hash)
+	}
+	sort.Strings(pending)
+
+	buf.Set(uint64(len(pending)))
+	for _, hash := range pending {
+		rawHash, err := hex.DecodeString(hash)
+		if err != nil {
+			return 0, ierrors.ErrInvalidTxid
+		}
+
+		buf.SetBytes(rawHash, false)
+		buf.Set(deltas[hash])
+	}
+
+	if _, err := w.Write(buf.GetBuffer()); err != nil {
+		return 0, err
+	}
+	return len(txs), nil
+}
+
+// Load reads a dump written by Dump and admits its txs to m like new ones keeping their
+// entry time and fee deltas. txs are revalidated, the ones failing admission are counted
+// and skipped, their fee deltas are kept
+func Load(m Mempool, r io.Reader, params *chaincfg.Params) (LoadResult, error) {
+	var result LoadResult
+	br := bufio.NewReader(r)
+
+	var version, count uint64
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return result, err
+	}
+	if version != 1 && version != DumpVersion {
+		return result, ierrors.ErrBadDumpVersion
+	}
+
+	if err := binary.Read(br, binary.LittleEndian, &count); err != nil {
+		return result, err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		tx, err := ReadTx(br)
+		if err != nil {
+			return result, err
+		}
+
+		for j := range tx.Vin {
+			if err := binary.Read(br, binary.LittleEndian, &tx.Vin[j].Prevout.Value); err != nil {
+				return result, err
+			}
+
+			scriptPubKey, err := readBytes(br)
+			if err != nil {
+				return result, err
+			}
+			tx.Vin[j].Prevout.ScriptPubKey = hex.EncodeToString(scriptPubKey)
+		}
+
+		var entryTime, feeDelta int64
+		if err := binary.Read(br, binary.LittleEndian, &entryTime); err != nil {
+			return result, err
+		}
+		if err := binary.Read(br, binary.LittleEndian, &feeDelta); err != nil {
+			return result, err
+		}
+
+		if feeDelta != 0 {
+			if err := prioritise(m, tx, feeDelta); err != nil {
+				return result, err
+			}
+		}
+
+		if err := Describe(&tx, params); err != nil {
+			result.Failed++
+			continue
+		}
+
+		switch err := m.PutTxAt(tx, time.Unix(entryTime, 0)); {
+		case err == nil:
+			result.Accepted++
+		case errors.Is(err, ierrors.ErrTxAlreadyExists):
+			result.AlreadyThere++
+		default:
+			result.Failed++
+		}
+	}
+
+	if version == 1 {
+		return result, nil
+	}
+
+	var deltas uint64
+	if err := binary.Read(br, binary.LittleEndian, &deltas); err != nil {
+		return result, err
+	}
+
+	for i := uint64(0); i < deltas; i++ {
+		hash := make([]byte, 32)
+		if _, err := io.ReadFull(br, hash); err != nil {
+			return result, err
+		}
+
+		var feeDelta int64
+		if err := binary.Read(br, binary.LittleEndian, &feeDelta); err != nil {
+			return result, err
+		}
+
+		if err := m.PrioritiseTransaction(hex.EncodeToString(reverse(hash)), feeDelta); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// prioritise applies fee delta of a dumped tx before it is admitted
+func prioritise(m Mempool, tx Transaction, feeDelta int64) error {
+	hash, _, _, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+	return m.PrioritiseTransaction(txidToHash(hash), feeDelta)
+}
+
+// DumpFile dumps m into file at path, written next to it first so a crash never leaves half a dump
+func DumpFile(m Mempool, path string) (int, error) {
+	tmpPath := path + ".new"
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := Dump(m, f)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	return count, os.Rename(tmpPath, path)
+}
+
+// LoadFile loads dump at path into m, see Load
+func LoadFile(m Mempool, path string, params *chaincfg.Params) (LoadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	defer f.Close()
+
+	return Load(m, f, params)
+}
+
+// RestoreFile loads dump a previous run left at path into m, missing dump is a fresh start
+func RestoreFile(m Mempool, path string, params *chaincfg.Params, logger *logrus.Logger) error {
+	result, err := LoadFile(m, path, params)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("loaded mempool dump: %d accepted %d failed %d already in mempool", result.Accepted, result.Failed, result.AlreadyThere)
+	return nil
+}
+
+// SaveFile dumps what is left in m to path on shutdown, failure is only logged as there is nothing left to undo.
+// callers must stop every writer first, dump of a mempool still being written may miss txs
+func SaveFile(m Mempool, path string, logger *logrus.Logger) {
+	count, err := DumpFile(m, path)
+	if err != nil {
+		logger.Error("unable to dump mempool ", err)
+		return
+	}
+	logger.Info("dumped ", count, " transactions to ", path)
+}
@@ -0,0 +1,227 @@
+package mempool_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/pkg/chaincfg"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	p2pkhFile     = "004947e806c5afa74ea4b64de0bfe63bb7488c2c3e4e5d4d5d6c8403d16de46a.json"
+	p2shP2wshFile = "0116cb33d4af228a15d3f2951370c24b3da23274e9835307707067ec7422640c.json"
+)
+
+var _ = Describe("Wire", func() {
+	DescribeTable("should decode serialized tx back to its json",
+		func(name string) {
+			tx := readTx(name)
+			_, raw, _, err := tx.Serialize()
+			Expect(err).To(BeNil())
+
+			decoded, err := mempool.DecodeTx(raw)
+			Expect(err).To(BeNil())
+
+			for i, in := range tx.Vin {
+				decoded.Vin[i].Prevout.ScriptPubKey = in.Prevout.ScriptPubKey
+				decoded.Vin[i].Prevout.Value = in.Prevout.Value
+			}
+			Expect(mempool.Describe(&decoded, &chaincfg.MainNetParams)).To(Succeed())
+			Expect(decoded).To(Equal(tx))
+		},
+		Entry("p2pkh", p2pkhFile),
+		Entry("p2sh-p2wsh", p2shP2wshFile),
+		Entry("p2wpkh parent", parentFile),
+		Entry("child", childFile),
+	)
+
+	It("should reject malformed wire txs", func() {
+		tx := readTx(p2pkhFile)
+		raw, _, _, err := tx.Serialize()
+		Expect(err).To(BeNil())
+
+		_, err = mempool.DecodeTx(append(raw, 0x00))
+		Expect(err).To(MatchError(ierrors.ErrTrailingBytes))
+
+		_, err = mempool.DecodeTx(raw[:len(raw)-1])
+		Expect(err).To(HaveOccurred())
+
+		// input count 1 encoded in 3 bytes
+		nonCanonical := append(append(append([]byte{}, raw[:4]...), 0xfd, 0x01, 0x00), raw[5:]...)
+		_, err = mempool.DecodeTx(nonCanonical)
+		Expect(err).To(MatchError(ierrors.ErrNonCanonicalCompactSize))
+
+		// marker and flag without any witness
+		superfluous, err := hex.DecodeString("02000000000100" + "00" + "00000000")
+		Expect(err).To(BeNil())
+		_, err = mempool.DecodeTx(superfluous)
+		Expect(err).To(MatchError(ierrors.ErrInvalidTx))
+	})
+})
+
+var _ = Describe("Dump", func() {
+	files := []string{parentFile, childFile, p2pkhFile, p2shP2wshFile}
+
+	load := func(pool mempool.Mempool) {
+		for _, name := range files {
+			Expect(pool.PutTx(readTx(name))).To(Succeed())
+		}
+	}
+
+	It("should reload dump into an empty mempool", func() {
+		pool := newMemPool(nil)
+		load(pool)
+
+		var dump bytes.Buffer
+		count, err := mempool.Dump(pool, &dump)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(len(files)))
+
+		reloaded := newMemPool(sqlite.Open(filepath.Join(GinkgoT().TempDir(), "mempool.db")))
+		result, err := mempool.Load(reloaded, bytes.NewReader(dump.Bytes()), &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(mempool.LoadResult{Accepted: len(files)}))
+
+		before, err := pool.Txs()
+		Expect(err).To(BeNil())
+		after, err := reloaded.Txs()
+		Expect(err).To(BeNil())
+
+		Expect(after).To(HaveLen(len(before)))
+		for i := range before {
+			Expect(after[i].Hash).To(Equal(before[i].Hash))
+			Expect(after[i].FeeCollected).To(Equal(before[i].FeeCollected))
+			Expect(after[i].Weight).To(Equal(before[i].Weight))
+			Expect(after[i].CreatedAt.Unix()).To(Equal(before[i].CreatedAt.Unix()))
+		}
+
+		// same mempool gives same bytes
+		var again bytes.Buffer
+		_, err = mempool.Dump(reloaded, &again)
+		Expect(err).To(BeNil())
+		Expect(again.Bytes()).To(Equal(dump.Bytes()))
+
+		result, err = mempool.Load(reloaded, bytes.NewReader(dump.Bytes()), &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(mempool.LoadResult{AlreadyThere: len(files)}))
+	})
+
+	It("should revalidate txs on load", func() {
+		pool := newMemPool(nil)
+		load(pool)
+
+		var dump bytes.Buffer
+		_, err := mempool.Dump(pool, &dump)
+		Expect(err).To(BeNil())
+
+		// none of them pays a 1M sats fee
+		strict, err := mempool.New(nil, mempool.Opts{
+			Logger:  silentLogger(),
+			Backend: mempool.BackendMemory,
+			Dust:    1_000_000,
+		})
+		Expect(err).To(BeNil())
+
+		result, err := mempool.Load(strict, bytes.NewReader(dump.Bytes()), &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(result.Accepted).To(BeZero())
+		Expect(result.Failed).To(Equal(len(files)))
+	})
+
+	It("should survive a restart through a dump file", func() {
+		dir := GinkgoT().TempDir()
+		file := filepath.Join(dir, "mempool.dat")
+
+		pool := newMemPool(nil)
+		load(pool)
+		count, err := mempool.DumpFile(pool, file)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(len(files)))
+		Expect(filepath.Join(dir, "mempool.dat.new")).NotTo(BeAnExistingFile())
+
+		restarted := newMemPool(nil)
+		result, err := mempool.LoadFile(restarted, file, &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(result.Accepted).To(Equal(len(files)))
+	})
+
+	It("should start fresh without a dump file and restore a saved one", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "mempool.dat")
+
+		fresh := newMemPool(nil)
+		Expect(mempool.RestoreFile(fresh, file, &chaincfg.MainNetParams, silentLogger())).To(Succeed())
+		txs, err := fresh.Txs()
+		Expect(err).To(BeNil())
+		Expect(txs).To(BeEmpty())
+
+		load(fresh)
+		mempool.SaveFile(fresh, file, silentLogger())
+
+		restarted := newMemPool(nil)
+		Expect(mempool.RestoreFile(restarted, file, &chaincfg.MainNetParams, silentLogger())).To(Succeed())
+		txs, err = restarted.Txs()
+		Expect(err).To(BeNil())
+		Expect(txs).To(HaveLen(len(files)))
+	})
+
+	DescribeTable("should keep empty witness items through a dump",
+		func(backend mempool.Backend) {
+			pool, err := mempool.New(sqlite.Open(filepath.Join(GinkgoT().TempDir(), "mempool.db")), mempool.Opts{Logger: silentLogger(), Dust: 546, Backend: backend},
+				&gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+			Expect(err).To(BeNil())
+
+			// one empty item differs from no witness on wire
+			tx := readTx(parentFile)
+			tx.Vin[0].Witness = []string{""}
+			Expect(pool.PutTx(tx)).To(Succeed())
+
+			var dump bytes.Buffer
+			_, err = mempool.Dump(pool, &dump)
+			Expect(err).To(BeNil())
+
+			reloaded := newMemPool(nil)
+			result, err := mempool.Load(reloaded, bytes.NewReader(dump.Bytes()), &chaincfg.MainNetParams)
+			Expect(err).To(BeNil())
+			Expect(result.Accepted).To(Equal(1))
+
+			before, err := pool.Txs()
+			Expect(err).To(BeNil())
+			after, err := reloaded.Txs()
+			Expect(err).To(BeNil())
+			Expect(after[0].WTXID).To(Equal(before[0].WTXID))
+		},
+		Entry("sql", mempool.BackendSQL),
+		Entry("memory", mempool.BackendMemory),
+	)
+
+	It("should reject unknown dump versions", func() {
+		_, err := mempool.Load(newMemPool(nil), strings.NewReader("\x03\x00\x00\x00\x00\x00\x00\x00"), &chaincfg.MainNetParams)
+		Expect(err).To(MatchError(ierrors.ErrBadDumpVersion))
+	})
+
+	It("should keep entry time on sql mempool", func() {
+		pool, err := mempool.New(sqlite.Open(filepath.Join(GinkgoT().TempDir(), "mempool.db")), mempool.Opts{Logger: silentLogger(), Dust: 546},
+			&gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+		Expect(err).To(BeNil())
+
+		entryTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		Expect(pool.PutTxAt(readTx(parentFile), entryTime)).To(Succeed())
+		Expect(pool.PutTx(readTx(parentFile))).To(MatchError(ierrors.ErrTxAlreadyExists))
+
+		txs, err := pool.Txs()
+		Expect(err).To(BeNil())
+		Expect(txs).To(HaveLen(1))
+		Expect(txs[0].CreatedAt.Equal(entryTime)).To(BeTrue())
+	})
+})
@@ -0,0 +1,63 @@
+package mempool
+
+import (
+	"sob-miner/pkg/transaction"
+
+	"gorm.io/gorm"
+)
+
+// Consistency lists rows of a sql mempool db which no tx refers to, left behind by
+// admissions failing after their rows were written
+type Consistency struct {
+	// inputs whose spending tx was never stored
+	OrphanInputs []transaction.InputTx
+	// outputs neither funded by a stored tx nor spent by an input of one
+	OrphanOutputs []transaction.OutPutTx
+}
+
+func (c Consistency) Ok() bool {
+	return len(c.OrphanInputs) == 0 && len(c.OrphanOutputs) == 0
+}
+
+// txs mined [soft deleted] still own their rows, so every lookup is unscoped
+const (
+	txOfInput = "EXISTS (SELECT 1 FROM txes WHERE txes.hash = input_txes.spending_tx_hash)"
+
+	orphanOutput = "NOT EXISTS (SELECT 1 FROM txes WHERE txes.hash = out_put_txes.funding_tx_hash)" +
+		" AND NOT EXISTS (SELECT 1 FROM input_txes WHERE input_txes.funding_tx_hash = out_put_txes.funding_tx_hash" +
+		" AND input_txes.funding_index = out_put_txes.funding_tx_pos AND " + txOfInput + ")"
+)
+
+// CheckConsistency finds orphaned input and output rows in db of sql store
+func CheckConsistency(db *gorm.DB) (Consistency, error) {
+	var c Consistency
+
+	if err := db.Unscoped().Where("NOT " + txOfInput).Order("id").Find(&c.OrphanInputs).Error; err != nil {
+		return Consistency{}, err
+	}
+
+	if err := db.Unscoped().Where(orphanOutput).Order("id").Find(&c.OrphanOutputs).Error; err != nil {
+		return Consistency{}, err
+	}
+
+	return c, nil
+}
+
+// RemoveOrphans deletes rows found by CheckConsistency for good
+func RemoveOrphans(db *gorm.DB, c Consistency) error {
+	return db.Transaction(func(db *gorm.DB) error {
+		for _, in := range c.OrphanInputs {
+			if err := db.Unscoped().Delete(&transaction.InputTx{}, in.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, out := range c.OrphanOutputs {
+			if err := db.Unscoped().Delete(&transaction.OutPutTx{}, out.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package mempool_test
+
+import (
+	"path/filepath"
+	"sob-miner/internal/mempool"
+	"sob-miner/pkg/transaction"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Consistency", func() {
+	var pool mempool.Mempool
+
+	BeforeEach(func() {
+		var err error
+		pool, err = mempool.New(sqlite.Open(filepath.Join(GinkgoT().TempDir(), "mempool.db")), mempool.Opts{Logger: silentLogger(), Dust: 546},
+			&gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+		Expect(err).To(BeNil())
+
+		for _, name := range []string{parentFile, childFile, p2pkhFile} {
+			Expect(pool.PutTx(readTx(name))).To(Succeed())
+		}
+	})
+
+	It("should find nothing in a consistent db", func() {
+		// rows of mined txs are still referred to
+		txs, err := pool.Txs()
+		Expect(err).To(BeNil())
+		Expect(pool.DeleteTx(txs[0].ID)).To(Succeed())
+
+		c, err := mempool.CheckConsistency(pool.DB())
+		Expect(err).To(BeNil())
+		Expect(c.Ok()).To(BeTrue())
+	})
+
+	It("should find and remove rows of a tx which was never stored", func() {
+		p2pkh := mempool.HashToTxid(strings.TrimSuffix(p2pkhFile, ".json"))
+		inputs, err := pool.GetInputs(p2pkh)
+		Expect(err).To(BeNil())
+
+		// rows a rejected tx left behind, one of its prevouts is shared with a stored tx
+		missing, unknown := strings.Repeat("aa", 32), strings.Repeat("bb", 32)
+		orphanInputs := []transaction.InputTx{
+			{SpendingTxHash: missing, FundingTxHash: unknown, FundingIndex: 1},
+			{SpendingTxHash: missing, FundingTxHash: inputs[0].FundingTxHash, FundingIndex: inputs[0].FundingIndex},
+		}
+		Expect(pool.DB().Create(&orphanInputs).Error).To(Succeed())
+		Expect(pool.DB().Create(&transaction.OutPutTx{FundingTxHash: unknown, FundingTxPos: 1, Value: 1000}).Error).To(Succeed())
+
+		c, err := mempool.CheckConsistency(pool.DB())
+		Expect(err).To(BeNil())
+		Expect(c.Ok()).To(BeFalse())
+		Expect(c.OrphanInputs).To(HaveLen(2))
+		Expect(c.OrphanInputs[0].ID).To(Equal(orphanInputs[0].ID))
+		Expect(c.OrphanInputs[1].ID).To(Equal(orphanInputs[1].ID))
+		Expect(c.OrphanOutputs).To(HaveLen(1))
+		Expect(c.OrphanOutputs[0].FundingTxHash).To(Equal(unknown))
+
+		Expect(mempool.RemoveOrphans(pool.DB(), c)).To(Succeed())
+
+		c, err = mempool.CheckConsistency(pool.DB())
+		Expect(err).To(BeNil())
+		Expect(c.Ok()).To(BeTrue())
+
+		_, err = pool.GetOutPointByIndex(inputs[0].FundingTxHash, inputs[0].FundingIndex)
+		Expect(err).To(BeNil())
+		Expect(pool.GetInputs(p2pkh)).To(HaveLen(len(inputs)))
+	})
+})
@@ -0,0 +1,137 @@
+package mempool_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sob-miner/internal/corpus"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/path"
+	"strings"
+	"testing"
+)
+
+// p2pkh, p2sh-p2wsh and p2wpkh spends, a child and a low fee tx
+var fuzzSeeds = []string{p2pkhFile, p2shP2wshFile, parentFile, childFile, lowFeeParentFile}
+
+func readSeed(tb testing.TB, name string) []byte {
+	data, err := os.ReadFile(filepath.Join(path.MempoolDataPath, name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+func fuzzPool(tb testing.TB) mempool.Mempool {
+	pool, err := mempool.New(nil, mempool.Opts{Logger: silentLogger(), Dust: 546, Backend: mempool.BackendMemory})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return pool
+}
+
+// FuzzTraceTx validates arbitrary json like a tx file, a malformed tx is rejected and never panics
+func FuzzTraceTx(f *testing.F) {
+	files, err := corpus.Txs()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, data := range files {
+		f.Add(data)
+	}
+	f.Add([]byte(`{"vin":[{"prevout":{}}],"vout":[{}]}`))
+	f.Add([]byte(`{"version":1,"vin":[{"txid":"zz","prevout":{"scriptpubkey":"00"},"witness":[""]}],"vout":[]}`))
+
+	pool := fuzzPool(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		trace := pool.TraceTx(data)
+
+		var tx mempool.Transaction
+		if err := json.Unmarshal(data, &tx); err != nil {
+			if !trace.Failed() {
+				t.Fatal("trace of unparsable json passed")
+			}
+			return
+		}
+
+		_, err := pool.PrepareTx(tx)
+		if err == nil {
+			err = tx.ValidateTxScripts()
+		}
+		if (err == nil) == trace.Failed() {
+			t.Fatalf("trace failed %v but validation returned %v", trace.Failed(), err)
+		}
+	})
+}
+
+// FuzzValidateTxScripts replaces script fields of first input of a dataset tx
+func FuzzValidateTxScripts(f *testing.F) {
+	txs := []mempool.Transaction{}
+	for i, name := range fuzzSeeds {
+		var tx mempool.Transaction
+		if err := json.Unmarshal(readSeed(f, name), &tx); err != nil {
+			f.Fatal(err)
+		}
+		txs = append(txs, tx)
+
+		in := tx.Vin[0]
+		f.Add(uint8(i), in.Prevout.ScriptPubKey, in.ScriptSigAsm, strings.Join(in.Witness, " "), in.InnerRedeemScriptAsm, in.InnerWitnessScriptAsm)
+	}
+
+	// used to panic: p2pkh without scriptSig, p2wpkh witness which isn't hex, p2sh-p2wsh with unknown opcode
+	p2pkh, p2sh, p2wpkh := txs[0].Vin[0].Prevout.ScriptPubKey, txs[1].Vin[0].Prevout.ScriptPubKey, txs[2].Vin[0].Prevout.ScriptPubKey
+	f.Add(uint8(0), p2pkh, "", "", "", "")
+	f.Add(uint8(2), p2wpkh, "", "zz 02", "", "")
+	f.Add(uint8(1), p2sh, "", "", "OP_NOPE", "")
+
+	f.Fuzz(func(t *testing.T, seed uint8, scriptPubKey, scriptSigAsm, witness, redeemAsm, witnessAsm string) {
+		tx := txs[int(seed)%len(txs)]
+		tx.Vin = append([]mempool.TxIn{}, tx.Vin...)
+
+		in := &tx.Vin[0]
+		in.Prevout.ScriptPubKey = scriptPubKey
+		in.ScriptSigAsm = scriptSigAsm
+		in.Witness = strings.Fields(witness)
+		in.InnerRedeemScriptAsm = redeemAsm
+		in.InnerWitnessScriptAsm = witnessAsm
+
+		// only must not panic
+		_ = tx.ValidateTxScripts()
+	})
+}
+
+// FuzzDecodeTx decodes wire txs, a tx which decodes serializes back to same bytes
+func FuzzDecodeTx(f *testing.F) {
+	files, err := corpus.Txs()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, data := range files {
+		var tx mempool.Transaction
+		if err := json.Unmarshal(data, &tx); err != nil {
+			f.Fatal(err)
+		}
+
+		_, raw, _, err := tx.Serialize()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(raw)
+	}
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		tx, err := mempool.DecodeTx(raw)
+		if err != nil {
+			return
+		}
+
+		_, serialized, _, err := tx.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(serialized, raw) {
+			t.Fatalf("decoded %x serializes to %x", raw, serialized)
+		}
+	})
+}
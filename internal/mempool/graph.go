@@ -0,0 +1,308 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/transaction"
+	"sort"
+)
+
+// Relatives are in mempool ancestors or descendants of a tx with their totals, tx itself excluded
+type Relatives struct {
+	Txs []transaction.Tx // ordered by ID
+
+	Count int
+	Size  uint64 // vbytes
+	Fee   uint64 // fee paid, fee deltas aren't counted
+}
+
+func newRelatives(txs map[string]transaction.Tx) Relatives {
+	r := Relatives{Txs: make([]transaction.Tx, 0, len(txs))}
+	for _, tx := range txs {
+		r.Txs = append(r.Txs, tx)
+		r.Size += tx.VSize()
+		r.Fee += tx.FeeCollected
+	}
+	r.Count = len(r.Txs)
+
+	sort.Slice(r.Txs, func(i, j int) bool { return r.Txs[i].ID < r.Txs[j].ID })
+	return r
+}
+
+// txGraph is parent child links of txs in a mempool, derived from InputTx.FundingTxHash to Tx.Hash.
+// a tx is linked to the ones it spends from, so children stored before their parent are found too
+type txGraph interface {
+	// in mempool txs among hashes
+	txs(hashes []string) ([]transaction.Tx, error)
+	// hashes of txs stored tx spends from, in mempool or not
+	spends(hash string) ([]string, error)
+	// in mempool txs spending outputs of hash
+	spenders(hash string) ([]transaction.Tx, error)
+}
+
+// ancestorsOf walks up from txs a tx spends from
+func ancestorsOf(g txGraph, spends []string) (map[string]transaction.Tx, error) {
+	found := map[string]transaction.Tx{}
+
+	next := spends
+	for len(next) > 0 {
+		parents, err := g.txs(next)
+		if err != nil {
+			return nil, err
+		}
+
+		next = nil
+		for _, parent := range parents {
+			if _, ok := found[parent.Hash]; ok {
+				continue
+			}
+			found[parent.Hash] = parent
+
+			grandParents, err := g.spends(parent.Hash)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, grandParents...)
+		}
+	}
+
+	return found, nil
+}
+
+// descendantsOf walks down from txs spending hash, hash doesn't have to be in mempool
+func descendantsOf(g txGraph, hash string) (map[string]transaction.Tx, error) {
+	found := map[string]transaction.Tx{}
+
+	next := []string{hash}
+	for len(next) > 0 {
+		cur := next[len(next)-1]
+		next = next[:len(next)-1]
+
+		children, err := g.spenders(cur)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			if _, ok := found[child.Hash]; ok {
+				continue
+			}
+			found[child.Hash] = child
+			next = append(next, child.Hash)
+		}
+	}
+
+	return found, nil
+}
+
+// Limits bound packages a tx may join, counts include tx itself and sizes are in vbytes
+type Limits struct {
+	Ancestors      int
+	AncestorSize   uint64
+	Descendants    int
+	DescendantSize uint64
+}
+
+// bitcoind's -limitancestorcount, -limitancestorsize, -limitdescendantcount and -limitdescendantsize
+var DefaultLimits = Limits{
+	Ancestors:      25,
+	AncestorSize:   101_000,
+	Descendants:    25,
+	DescendantSize: 101_000,
+}
+
+// a tx this small with a single ancestor may exceed descendant limits by one, CPFP carve-out
+const ExtraDescendantTxSize = 10_000
+
+// carveOut limits let a tx with one in mempool ancestor become an extra descendant of it
+func (l Limits) carveOut() Limits {
+	return Limits{
+		Ancestors:      2,
+		AncestorSize:   l.AncestorSize,
+		Descendants:    l.Descendants + 1,
+		DescendantSize: l.DescendantSize + ExtraDescendantTxSize,
+	}
+}
+
+func (l Limits) checkAncestors(txs map[string]transaction.Tx) error {
+	if r := newRelatives(txs); r.Count > l.Ancestors || r.Size > l.AncestorSize {
+		return fmt.Errorf("%w: %d txs of %d vbytes, limit is %d txs of %d vbytes", ierrors.ErrAncestorLimit, r.Count, r.Size, l.Ancestors, l.AncestorSize)
+	}
+	return nil
+}
+
+func (l Limits) checkDescendants(txs map[string]transaction.Tx) error {
+	if r := newRelatives(txs); r.Count > l.Descendants || r.Size > l.DescendantSize {
+		return fmt.Errorf("%w: %d txs of %d vbytes, limit is %d txs of %d vbytes", ierrors.ErrDescendantLimit, r.Count, r.Size, l.Descendants, l.DescendantSize)
+	}
+	return nil
+}
+
+// check fails when admitting tx spending from spends would take it, one of its ancestors
+// or one of its descendants over limits, unless carve-out allows it
+func (l Limits) check(g txGraph, tx transaction.Tx, spends []string) error {
+	err := l.checkPackage(g, tx, spends)
+	if errors.Is(err, ierrors.ErrDescendantLimit) && tx.VSize() <= ExtraDescendantTxSize {
+		if l.carveOut().checkPackage(g, tx, spends) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (l Limits) checkPackage(g txGraph, tx transaction.Tx, spends []string) error {
+	anc, err := ancestorsOf(g, spends)
+	if err != nil {
+		return err
+	}
+
+	desc, err := descendantsOf(g, tx.Hash)
+	if err != nil {
+		return err
+	}
+
+	if err := l.checkAncestors(with(anc, tx)); err != nil {
+		return err
+	}
+	if err := l.checkDescendants(with(desc, tx)); err != nil {
+		return err
+	}
+
+	// ancestors gain tx with its descendants
+	for _, a := range anc {
+		aDesc, err := descendantsOf(g, a.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := l.checkDescendants(with(merge(aDesc, desc), a, tx)); err != nil {
+			return err
+		}
+	}
+
+	// descendants gain tx with its ancestors
+	for _, d := range desc {
+		dSpends, err := g.spends(d.Hash)
+		if err != nil {
+			return err
+		}
+
+		dAnc, err := ancestorsOf(g, dSpends)
+		if err != nil {
+			return err
+		}
+
+		if err := l.checkAncestors(with(merge(dAnc, anc), d, tx)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func merge(a, b map[string]transaction.Tx) map[string]transaction.Tx {
+	merged := make(map[string]transaction.Tx, len(a)+len(b))
+	for hash, tx := range a {
+		merged[hash] = tx
+	}
+	for hash, tx := range b {
+		merged[hash] = tx
+	}
+	return merged
+}
+
+// with returns copy of txs including more
+func with(txs map[string]transaction.Tx, more ...transaction.Tx) map[string]transaction.Tx {
+	all := merge(txs, nil)
+	for _, tx := range more {
+		all[tx.Hash] = tx
+	}
+	return all
+}
+
+// hashes of txs inputs spend from
+func spendsOf(inputs []transaction.InputTx) []string {
+	seen := map[string]struct{}{}
+	spends := []string{}
+	for _, input := range inputs {
+		hash := txidToHash(input.FundingTxHash)
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		seen[hash] = struct{}{}
+		spends = append(spends, hash)
+	}
+	return spends
+}
+
+// memGraph follows links of indexed entries, caller holds lock
+type memGraph struct {
+	m *memPool
+}
+
+func (g memGraph) txs(hashes []string) ([]transaction.Tx, error) {
+	txs := []transaction.Tx{}
+	for _, hash := range hashes {
+		if e, ok := g.m.entries[hash]; ok {
+			txs = append(txs, e.tx)
+		}
+	}
+	return txs, nil
+}
+
+func (g memGraph) spends(hash string) ([]string, error) {
+	if e, ok := g.m.entries[hash]; ok {
+		return e.spends, nil
+	}
+	return nil, nil
+}
+
+func (g memGraph) spenders(hash string) ([]transaction.Tx, error) {
+	txs := []transaction.Tx{}
+	for e := range g.m.spenders[hash] {
+		txs = append(txs, e.tx)
+	}
+	return txs, nil
+}
+
+// getAncestors and getDescendants back GetAncestors and GetDescendants of both mempools
+func getAncestors(g txGraph, hash string) (Relatives, error) {
+	if err := inMempool(g, hash); err != nil {
+		return Relatives{}, err
+	}
+
+	spends, err := g.spends(hash)
+	if err != nil {
+		return Relatives{}, err
+	}
+
+	anc, err := ancestorsOf(g, spends)
+	if err != nil {
+		return Relatives{}, err
+	}
+	return newRelatives(anc), nil
+}
+
+func getDescendants(g txGraph, hash string) (Relatives, error) {
+	if err := inMempool(g, hash); err != nil {
+		return Relatives{}, err
+	}
+
+	desc, err := descendantsOf(g, hash)
+	if err != nil {
+		return Relatives{}, err
+	}
+	return newRelatives(desc), nil
+}
+
+func inMempool(g txGraph, hash string) error {
+	txs, err := g.txs([]string{hash})
+	if err != nil {
+		return err
+	}
+	if len(txs) == 0 {
+		return ierrors.ErrTxNotFound
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package mempool_test
+
+import (
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/mempool/mempooltest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// grand parent -> parent -> child, no other relatives in dataset
+var chainFiles = []string{
+	"11af62a102b611ecb44523dda00d88333c60a82dad37d1b788dce1bb37796109.json",
+	"278fe7a833141ed5e770d3f204cf837137243c59b083fa703486785d9be95cb5.json",
+	"9a0eb20e008c91db791295e0e491241e738005679a943463b727b2d4941940a6.json",
+}
+
+// stored hash of a dataset file, byte reversal turns txid into hash as well
+func fileHash(name string) string {
+	return mempool.HashToTxid(strings.TrimSuffix(name, ".json"))
+}
+
+var _ = Describe("Tx Graph", func() {
+	for _, backend := range mempooltest.Backends {
+		backend := backend
+		newPool := func(limits mempool.Limits) mempool.Mempool {
+			return openPool(backend, mempool.Opts{Logger: silentLogger(), Dust: 546, Limits: limits})
+		}
+
+		Describe(string(backend), func() {
+			grandParent, parent, child := fileHash(chainFiles[0]), fileHash(chainFiles[1]), fileHash(chainFiles[2])
+
+			hashes := func(r mempool.Relatives) []string {
+				hashes := []string{}
+				for _, tx := range r.Txs {
+					hashes = append(hashes, tx.Hash)
+				}
+				return hashes
+			}
+
+			It("should report ancestors and descendants with totals", func() {
+				pool := newPool(mempool.Limits{})
+				for _, name := range chainFiles {
+					Expect(pool.PutTx(readTx(name))).To(Succeed())
+				}
+
+				anc, err := pool.GetAncestors(child)
+				Expect(err).To(BeNil())
+				Expect(hashes(anc)).To(Equal([]string{grandParent, parent}))
+				Expect(anc.Count).To(Equal(2))
+
+				gp, p := txByHash(pool, grandParent), txByHash(pool, parent)
+				Expect(anc.Size).To(Equal(gp.VSize() + p.VSize()))
+				Expect(anc.Fee).To(Equal(gp.FeeCollected + p.FeeCollected))
+
+				desc, err := pool.GetDescendants(grandParent)
+				Expect(err).To(BeNil())
+				Expect(hashes(desc)).To(Equal([]string{parent, child}))
+
+				anc, err = pool.GetAncestors(grandParent)
+				Expect(err).To(BeNil())
+				Expect(anc.Count).To(BeZero())
+
+				desc, err = pool.GetDescendants(child)
+				Expect(err).To(BeNil())
+				Expect(desc.Count).To(BeZero())
+
+				_, err = pool.GetAncestors(strings.Repeat("00", 32))
+				Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+			})
+
+			It("should link children which arrived before their parents", func() {
+				pool := newPool(mempool.Limits{})
+				for i := len(chainFiles) - 1; i >= 0; i-- {
+					Expect(pool.PutTx(readTx(chainFiles[i]))).To(Succeed())
+				}
+
+				anc, err := pool.GetAncestors(child)
+				Expect(err).To(BeNil())
+				Expect(anc.Count).To(Equal(2))
+
+				desc, err := pool.GetDescendants(grandParent)
+				Expect(err).To(BeNil())
+				Expect(desc.Count).To(Equal(2))
+			})
+
+			It("should drop mined txs from packages", func() {
+				pool := newPool(mempool.Limits{})
+				for _, name := range chainFiles {
+					Expect(pool.PutTx(readTx(name))).To(Succeed())
+				}
+				Expect(pool.DeleteTx(txByHash(pool, grandParent).ID)).To(Succeed())
+
+				anc, err := pool.GetAncestors(child)
+				Expect(err).To(BeNil())
+				Expect(hashes(anc)).To(Equal([]string{parent}))
+
+				_, err = pool.GetDescendants(grandParent)
+				Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+			})
+
+			It("should evict descendants of rejected txs", func() {
+				pool := newPool(mempool.Limits{})
+				for _, name := range chainFiles {
+					Expect(pool.PutTx(readTx(name))).To(Succeed())
+				}
+
+				evicted, err := pool.EvictTx(txByHash(pool, parent).ID)
+				Expect(err).To(BeNil())
+				Expect(evicted).To(HaveLen(1))
+				Expect(evicted[0].Hash).To(Equal(child))
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(HaveLen(1))
+				Expect(txs[0].Hash).To(Equal(grandParent))
+
+				desc, err := pool.GetDescendants(grandParent)
+				Expect(err).To(BeNil())
+				Expect(desc.Count).To(BeZero())
+
+				_, err = pool.GetAncestors(child)
+				Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+
+				best, err := pool.PickBestTx()
+				Expect(err).To(BeNil())
+				Expect(best.Hash).To(Equal(grandParent))
+				Expect(pool.DeleteTx(best.ID)).To(Succeed())
+
+				_, err = pool.PickBestTx()
+				Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+			})
+
+			It("should enforce ancestor limit in any arrival order", func() {
+				pool := newPool(mempool.Limits{Ancestors: 2})
+				Expect(pool.PutTx(readTx(chainFiles[0]))).To(Succeed())
+				Expect(pool.PutTx(readTx(chainFiles[1]))).To(Succeed())
+				Expect(pool.PutTx(readTx(chainFiles[2]))).To(MatchError(ierrors.ErrAncestorLimit))
+
+				// parent would give child in mempool a second ancestor
+				pool = newPool(mempool.Limits{Ancestors: 2})
+				Expect(pool.PutTx(readTx(chainFiles[0]))).To(Succeed())
+				Expect(pool.PutTx(readTx(chainFiles[2]))).To(Succeed())
+				Expect(pool.PutTx(readTx(chainFiles[1]))).To(MatchError(ierrors.ErrAncestorLimit))
+			})
+
+			It("should enforce descendant limit past carve-out", func() {
+				pool := newPool(mempool.Limits{Descendants: 1})
+				Expect(pool.PutTx(readTx(chainFiles[0]))).To(Succeed())
+
+				// a single small child is let through
+				Expect(pool.PutTx(readTx(chainFiles[1]))).To(Succeed())
+				Expect(pool.PutTx(readTx(chainFiles[2]))).To(MatchError(ierrors.ErrDescendantLimit))
+
+				desc, err := pool.GetDescendants(grandParent)
+				Expect(err).To(BeNil())
+				Expect(desc.Count).To(Equal(1))
+			})
+		})
+	}
+})
@@ -0,0 +1,80 @@
+package mempool
+
+import (
+	"container/heap"
+	"math/bits"
+)
+
+// FeeRate is an exact fee / weight ratio
+type FeeRate struct {
+	Fee    uint64
+	Weight uint64
+}
+
+// Cmp compares fee rates exactly by cross multiplying in 128 bits,
+// returns -1, 0 or 1 when r is lower, equal or higher than o
+func (r FeeRate) Cmp(o FeeRate) int {
+	hi1, lo1 := bits.Mul64(r.Fee, o.Weight)
+	hi2, lo2 := bits.Mul64(o.Fee, r.Weight)
+
+	switch {
+	case hi1 < hi2 || (hi1 == hi2 && lo1 < lo2):
+		return -1
+	case hi1 > hi2 || (hi1 == hi2 && lo1 > lo2):
+		return 1
+	}
+	return 0
+}
+
+// scoreIndex is a max heap of entries by ancestor score, ties are broken by lower ID
+type scoreIndex []*entry
+
+func (s scoreIndex) Len() int { return len(s) }
+
+func (s scoreIndex) Less(i, j int) bool {
+	return s[i].better(s[j])
+}
+
+func (s scoreIndex) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].heapIndex = i
+	s[j].heapIndex = j
+}
+
+func (s *scoreIndex) Push(x any) {
+	e := x.(*entry)
+	e.heapIndex = len(*s)
+	*s = append(*s, e)
+}
+
+func (s *scoreIndex) Pop() any {
+	old := *s
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.heapIndex = -1
+	*s = old[:len(old)-1]
+	return e
+}
+
+func (s *scoreIndex) add(e *entry) {
+	heap.Push(s, e)
+}
+
+func (s *scoreIndex) remove(e *entry) {
+	if e.heapIndex >= 0 {
+		heap.Remove(s, e.heapIndex)
+	}
+}
+
+func (s *scoreIndex) fix(e *entry) {
+	if e.heapIndex >= 0 {
+		heap.Fix(s, e.heapIndex)
+	}
+}
+
+func (s scoreIndex) best() *entry {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
@@ -0,0 +1,645 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/transaction"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// outpoint identifies an output by its funding tx hash and index
+type outpoint struct {
+	hash  string
+	index uint32
+}
+
+// entry is a tx in memory mempool linked to its in mempool parents and children
+type entry struct {
+	tx transaction.Tx
+
+	// hashes of txs it spends from, in or out of mempool
+	spends []string
+
+	parents  map[*entry]struct{}
+	children map[*entry]struct{}
+
+	// totals of tx and all its in mempool ancestors
+	ancestorFee       uint64
+	ancestorWeight    uint64
+	ancestorSigOpCost uint64
+
+	heapIndex int
+}
+
+func (e *entry) ancestorScore() FeeRate {
+	return FeeRate{Fee: e.ancestorFee, Weight: e.ancestorWeight}
+}
+
+// better orders entries by ancestor score, earlier tx wins a tie
+func (e *entry) better(o *entry) bool {
+	if c := e.ancestorScore().Cmp(o.ancestorScore()); c != 0 {
+		return c > 0
+	}
+	return e.tx.ID < o.tx.ID
+}
+
+// memPool keeps txs indexed in memory by ancestor score on top of a Store,
+// picking best tx is O(1) instead of sorting whole table on every pick.
+type memPool struct {
+	settings
+	store Store
+
+	mu sync.RWMutex
+
+	entries map[string]*entry // live txs by hash
+	byID    map[uint]*entry
+
+	// live txs by hash of tx they spend from.
+	// inputs reference funding tx by txid [RPC byte order], tx hashes are little endian
+	spenders map[string]map[*entry]struct{}
+
+	index scoreIndex
+
+	deltas feeDeltas
+}
+
+func newMemPool(store Store, settings settings) (*memPool, error) {
+	m := &memPool{
+		settings: settings,
+		store:    store,
+
+		entries:  map[string]*entry{},
+		byID:     map[uint]*entry{},
+		spenders: map[string]map[*entry]struct{}{},
+
+		deltas: feeDeltas{},
+	}
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// load indexes every live tx of store which is not indexed yet
+func (m *memPool) load() error {
+	txs, err := m.store.Txs()
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		if _, ok := m.entries[tx.Hash]; ok {
+			continue
+		}
+
+		inputs, err := m.store.Inputs(tx.Hash)
+		if err != nil {
+			return err
+		}
+
+		if tx.FeeDelta != 0 {
+			m.deltas[tx.Hash] = tx.FeeDelta
+		}
+		m.insert(newEntry(tx, inputs))
+	}
+
+	m.logger.Infof("indexed %d txs", len(m.entries))
+	return nil
+}
+
+// DB returns underlying gorm db when store is sql backed, nil otherwise
+func (m *memPool) DB() *gorm.DB {
+	if s, ok := m.store.(interface{ DB() *gorm.DB }); ok {
+		return s.DB()
+	}
+	return nil
+}
+
+func (m *memPool) Close() error {
+	return m.store.Close()
+}
+
+func (m *memPool) PutTx(tx Transaction) error {
+	return m.PutTxAt(tx, time.Now().UTC())
+}
+
+func (m *memPool) PutTxAt(tx Transaction, entryTime time.Time) error {
+	p, err := m.PrepareTx(tx)
+	if err != nil {
+		return err
+	}
+	p.Tx.CreatedAt = entryTime.UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.accept(&p)
+}
+
+// AcceptTxs writes whole batch in one store transaction when store has them, an error
+// committing the batch fails every tx of it
+func (m *memPool) AcceptTxs(txs []PreparedTx) []error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	errs := make([]error, len(txs))
+	err := m.batch(txs, func(store Store) error {
+		for i := range txs {
+			errs[i] = m.admit(store, &txs[i])
+		}
+		return nil
+	})
+
+	if err != nil {
+		m.logger.Info("unable to write batch ", err)
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// accept stores and indexes p, caller holds write lock
+func (m *memPool) accept(p *PreparedTx) error {
+	return m.admit(m.store, p)
+}
+
+// admit is accept writing with store
+func (m *memPool) admit(store Store, p *PreparedTx) error {
+	if _, ok := m.entries[p.Tx.Hash]; ok {
+		return ierrors.ErrTxAlreadyExists
+	}
+
+	if err := m.limits.check(m.graph(), p.Tx, spendsOf(p.Inputs)); err != nil {
+		return err
+	}
+
+	return m.add(store, p)
+}
+
+// add stores p with store and indexes it without checks
+func (m *memPool) add(store Store, p *PreparedTx) error {
+	p.Tx.FeeDelta = m.deltas[p.Tx.Hash]
+
+	if err := store.AddTx(&p.Tx, p.Inputs, p.Outputs); err != nil {
+		m.logger.Infof("unable to store tx %v %v", p.Tx.Hash, err)
+		return err
+	}
+
+	m.insert(newEntry(p.Tx, p.Inputs))
+	return nil
+}
+
+// batch calls fn with store writing into one transaction when m.store is a batcher,
+// txs indexed by fn are unindexed again when that transaction is rolled back
+func (m *memPool) batch(txs []PreparedTx, fn func(store Store) error) error {
+	b, ok := m.store.(batcher)
+	if !ok {
+		return fn(m.store)
+	}
+
+	err := b.Batch(fn)
+	if err != nil {
+		for i := len(txs) - 1; i >= 0; i-- {
+			if e, ok := m.entries[txs[i].Tx.Hash]; ok && e.tx.ID == txs[i].Tx.ID {
+				m.remove(e)
+			}
+		}
+	}
+	return err
+}
+
+func (m *memPool) SubmitPackage(txs []Transaction) (PackageResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.submitPackage(m, txs)
+}
+
+func (m *memPool) graph() txGraph {
+	return memGraph{m}
+}
+
+// acceptAll stores txs in one transaction when store has them, otherwise
+// one by one and only a store error can stop it half way
+func (m *memPool) acceptAll(txs []PreparedTx) error {
+	return m.batch(txs, func(store Store) error {
+		for i := range txs {
+			if err := m.add(store, &txs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func newEntry(tx transaction.Tx, inputs []transaction.InputTx) *entry {
+	e := &entry{tx: tx, heapIndex: -1}
+	for _, input := range inputs {
+		e.spends = append(e.spends, txidToHash(input.FundingTxHash))
+	}
+	return e
+}
+
+// insert links e with its in mempool parents and children and indexes it
+func (m *memPool) insert(e *entry) {
+	e.parents = map[*entry]struct{}{}
+	e.children = map[*entry]struct{}{}
+
+	for _, parentHash := range e.spends {
+		if parent, ok := m.entries[parentHash]; ok {
+			e.parents[parent] = struct{}{}
+			parent.children[e] = struct{}{}
+		}
+
+		if m.spenders[parentHash] == nil {
+			m.spenders[parentHash] = map[*entry]struct{}{}
+		}
+		m.spenders[parentHash][e] = struct{}{}
+	}
+
+	// children which arrived before their parent
+	for child := range m.spenders[e.tx.Hash] {
+		e.children[child] = struct{}{}
+		child.parents[e] = struct{}{}
+	}
+
+	m.entries[e.tx.Hash] = e
+	m.byID[e.tx.ID] = e
+
+	m.updateAncestorStats(e)
+	m.index.add(e)
+
+	for _, d := range descendants(e) {
+		m.updateAncestorStats(d)
+	}
+}
+
+// remove unlinks e from mempool, its descendants stay and lose it as ancestor.
+// only fine for a mined e, a rejected one is evicted with its descendants instead
+func (m *memPool) remove(e *entry) {
+	desc := descendants(e)
+
+	m.unlink(e)
+
+	for _, d := range desc {
+		m.updateAncestorStats(d)
+	}
+}
+
+// evict unlinks e with every descendant, which can't be mined without it, and returns them
+func (m *memPool) evict(e *entry) []*entry {
+	desc := descendants(e)
+
+	m.unlink(e)
+	for _, d := range desc {
+		m.unlink(d)
+	}
+
+	return desc
+}
+
+// unlink drops e from index and from links of its relatives
+func (m *memPool) unlink(e *entry) {
+	m.index.remove(e)
+
+	for parent := range e.parents {
+		delete(parent.children, e)
+	}
+	for child := range e.children {
+		delete(child.parents, e)
+	}
+
+	for _, parentHash := range e.spends {
+		delete(m.spenders[parentHash], e)
+		if len(m.spenders[parentHash]) == 0 {
+			delete(m.spenders, parentHash)
+		}
+	}
+
+	delete(m.entries, e.tx.Hash)
+	delete(m.byID, e.tx.ID)
+	e.parents, e.children = nil, nil
+}
+
+// recomputes ancestor package totals of e and its position in index
+func (m *memPool) updateAncestorStats(e *entry) {
+	e.ancestorFee = e.tx.ModifiedFee()
+	e.ancestorWeight = e.tx.Weight
+	e.ancestorSigOpCost = e.tx.SigOpCost
+
+	for a := range ancestors(e) {
+		e.ancestorFee += a.tx.ModifiedFee()
+		e.ancestorWeight += a.tx.Weight
+		e.ancestorSigOpCost += a.tx.SigOpCost
+	}
+
+	m.index.fix(e)
+}
+
+// HashToTxid turns Tx.Hash into txid in rpc order, same byte reversal as txidToHash
+func HashToTxid(hash string) string {
+	return txidToHash(hash)
+}
+
+// converts RPC byte order txid into little endian hash
+func txidToHash(txid string) string {
+	b, err := hex.DecodeString(txid)
+	if err != nil {
+		return txid
+	}
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return hex.EncodeToString(b)
+}
+
+// all in mempool ancestors of e, e excluded
+func ancestors(e *entry) map[*entry]struct{} {
+	seen := map[*entry]struct{}{}
+	stack := []*entry{e}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for parent := range cur.parents {
+			if _, ok := seen[parent]; ok {
+				continue
+			}
+			seen[parent] = struct{}{}
+			stack = append(stack, parent)
+		}
+	}
+	return seen
+}
+
+// all in mempool descendants of e, e excluded
+func descendants(e *entry) []*entry {
+	seen := map[*entry]struct{}{}
+	desc := []*entry{}
+	stack := []*entry{e}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for child := range cur.children {
+			if _, ok := seen[child]; ok {
+				continue
+			}
+			seen[child] = struct{}{}
+			desc = append(desc, child)
+			stack = append(stack, child)
+		}
+	}
+	return desc
+}
+
+// root of e's package, ancestor without in mempool parents which has to be mined first.
+// a package may have several roots, best one is returned
+func root(e *entry) *entry {
+	best := e
+	if len(e.parents) != 0 {
+		best = nil
+	}
+
+	for a := range ancestors(e) {
+		if len(a.parents) == 0 && (best == nil || a.better(best)) {
+			best = a
+		}
+	}
+	return best
+}
+
+// PickBestTx returns first tx to mine of package with highest ancestor score
+func (m *memPool) PickBestTx() (transaction.Tx, error) {
+	return m.PickBestTxWithinBudget(math.MaxUint64, math.MaxUint64)
+}
+
+func (m *memPool) PickBestTxWithinWeight(weight uint64) (transaction.Tx, error) {
+	return m.PickBestTxWithinBudget(weight, math.MaxUint64)
+}
+
+// PickBestTxWithinBudget returns first tx to mine of best ancestor score package which fits in budget
+func (m *memPool) PickBestTxWithinBudget(weight uint64, sigOpCost uint64) (transaction.Tx, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var best *entry
+	if top := m.index.best(); top != nil && top.ancestorWeight <= weight && top.ancestorSigOpCost <= sigOpCost {
+		best = top
+	} else {
+		for _, e := range m.index {
+			if e.ancestorWeight > weight || e.ancestorSigOpCost > sigOpCost {
+				continue
+			}
+			if best == nil || e.better(best) {
+				best = e
+			}
+		}
+	}
+
+	if best == nil {
+		return transaction.Tx{}, ierrors.ErrTxNotFound
+	}
+
+	return root(best).tx, nil
+}
+
+// PickBestTxsWithinBudget returns up to limit best fee rate txs without in mempool parents
+// which individually fit in budget, so any subset of them can be mined
+func (m *memPool) PickBestTxsWithinBudget(weight uint64, sigOpCost uint64, limit int) ([]transaction.Tx, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	candidates := []*entry{}
+	for _, e := range m.index {
+		if len(e.parents) == 0 && e.tx.Weight <= weight && e.tx.SigOpCost <= sigOpCost {
+			candidates = append(candidates, e)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].better(candidates[j])
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	txs := make([]transaction.Tx, len(candidates))
+	for i, e := range candidates {
+		txs[i] = e.tx
+	}
+	return txs, nil
+}
+
+func (m *memPool) DeleteTx(ID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.byID[ID]
+	if !ok {
+		return nil
+	}
+
+	if err := m.store.DeleteTx(ID); err != nil {
+		return err
+	}
+
+	m.remove(e)
+	return nil
+}
+
+func (m *memPool) EvictTx(ID uint) ([]transaction.Tx, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.byID[ID]
+	if !ok {
+		return nil, nil
+	}
+
+	evicted := append([]*entry{e}, descendants(e)...)
+	err := m.batch(nil, func(store Store) error {
+		for _, ev := range evicted {
+			if err := store.DeleteTx(ev.tx.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	desc := m.evict(e)
+	txs := make([]transaction.Tx, len(desc))
+	for i, d := range desc {
+		txs[i] = d.tx
+	}
+
+	// descendants are walked through maps, admission order keeps result stable
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].ID < txs[j].ID
+	})
+	return txs, nil
+}
+
+func (m *memPool) Txs() ([]transaction.Tx, error) {
+	return m.store.Txs()
+}
+
+func (m *memPool) PrioritiseTransaction(txid string, feeDelta int64) error {
+	hash, err := txidHash(txid)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deltas[hash] += feeDelta
+
+	e, ok := m.entries[hash]
+	if !ok {
+		return nil
+	}
+
+	if err := m.store.SetFeeDelta(e.tx.ID, m.deltas[hash]); err != nil {
+		return err
+	}
+	e.tx.FeeDelta = m.deltas[hash]
+
+	// package scores of tx and everything depending on it change
+	m.updateAncestorStats(e)
+	for _, d := range descendants(e) {
+		m.updateAncestorStats(d)
+	}
+	return nil
+}
+
+func (m *memPool) FeeDeltas() map[string]int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.deltas.byTxid()
+}
+
+func (m *memPool) GetAncestors(hash string) (Relatives, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getAncestors(m.graph(), hash)
+}
+
+func (m *memPool) GetDescendants(hash string) (Relatives, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getDescendants(m.graph(), hash)
+}
+
+func (m *memPool) GetInputs(SpendingTxHash string) ([]transaction.InputTx, error) {
+	return m.store.Inputs(SpendingTxHash)
+}
+
+func (m *memPool) GetOutputs(FundingTxHash string) ([]transaction.OutPutTx, error) {
+	return m.store.Outputs(FundingTxHash)
+}
+
+// missing outpoint is returned as zero value
+func (m *memPool) GetOutPointByIndex(FundingTxHash string, index uint32) (transaction.OutPutTx, error) {
+	out, err := m.store.Output(FundingTxHash, index)
+	if errors.Is(err, ierrors.ErrTxNotFound) {
+		return transaction.OutPutTx{}, nil
+	}
+	return out, err
+}
+
+func (m *memPool) MarkOutPointSpent(FundingTxHash string, index uint32) error {
+	err := m.store.MarkSpent(FundingTxHash, index)
+	if errors.Is(err, ierrors.ErrAlreadySpent) {
+		m.logger.Info("outpoint already spent", FundingTxHash, index)
+	}
+	return err
+}
+
+func (m *memPool) ValidateWholeTx(tx transaction.Tx, inputs []transaction.InputTx) error {
+	prevOuts := make([]transaction.OutPutTx, len(inputs))
+	for i, input := range inputs {
+		out, err := m.GetOutPointByIndex(input.FundingTxHash, input.FundingIndex)
+		if err != nil {
+			return err
+		}
+		prevOuts[i] = out
+	}
+
+	outputs, err := m.store.Outputs(tx.Hash)
+	if err != nil {
+		return err
+	}
+
+	wholeTx := assembleTx(tx, inputs, prevOuts, outputs)
+	return wholeTx.ValidateTxScripts()
+}
+
+// ResetTables restores deleted txs and marks every output unspent
+func (m *memPool) ResetTables() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.store.Reset(); err != nil {
+		return err
+	}
+
+	return m.load()
+}
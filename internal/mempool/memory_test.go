@@ -0,0 +1,133 @@
+package mempool_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/path"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// child spends output of parent, both in mempool dataset
+const (
+	parentFile = "2d4d450afe9432440531531c06b407a65fac6378bc68bf9b7bdf5f610cf9a352.json"
+	childFile  = "1cbd72230995f87cf262ce4a85a69c737e863b3d67cb69c666a0295bec5d07f5.json"
+)
+
+func readTx(name string) mempool.Transaction {
+	data, err := os.ReadFile(filepath.Join(path.MempoolDataPath, name))
+	Expect(err).To(BeNil())
+
+	var tx mempool.Transaction
+	Expect(json.Unmarshal(data, &tx)).To(Succeed())
+	return tx
+}
+
+func silentLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return logger
+}
+
+func newMemPool(dialector gorm.Dialector) mempool.Mempool {
+	pool, err := mempool.New(dialector, mempool.Opts{
+		Logger:  silentLogger(),
+		Backend: mempool.BackendMemory,
+		Dust:    546,
+	}, &gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+	Expect(err).To(BeNil())
+	return pool
+}
+
+var _ = Describe("Memory Mempool", func() {
+	It("should compare fee rates exactly", func() {
+		third := mempool.FeeRate{Fee: 1, Weight: 3}
+		Expect(third.Cmp(mempool.FeeRate{Fee: 333_333, Weight: 1_000_000})).To(Equal(1))
+		Expect(third.Cmp(mempool.FeeRate{Fee: 2, Weight: 6})).To(Equal(0))
+		Expect(third.Cmp(mempool.FeeRate{Fee: 1, Weight: 2})).To(Equal(-1))
+
+		// products overflow 64 bits
+		big := mempool.FeeRate{Fee: 1 << 62, Weight: 1<<62 + 1}
+		Expect(big.Cmp(mempool.FeeRate{Fee: 1<<62 - 1, Weight: 1 << 62})).To(Equal(1))
+	})
+
+	It("should mine parent before child regardless of arrival", func() {
+		pool := newMemPool(nil)
+		Expect(pool.PutTx(readTx(childFile))).To(Succeed())
+		Expect(pool.PutTx(readTx(parentFile))).To(Succeed())
+		Expect(pool.PutTx(readTx(parentFile))).To(MatchError(ierrors.ErrTxAlreadyExists))
+
+		parent, err := pool.PickBestTx()
+		Expect(err).To(BeNil())
+
+		inputs, err := pool.GetInputs(parent.Hash)
+		Expect(err).To(BeNil())
+		Expect(inputs[0].FundingTxHash).To(Equal(readTx(parentFile).Vin[0].Txid))
+
+		Expect(pool.DeleteTx(parent.ID)).To(Succeed())
+
+		child, err := pool.PickBestTx()
+		Expect(err).To(BeNil())
+		Expect(child.ID).NotTo(Equal(parent.ID))
+		Expect(pool.DeleteTx(child.ID)).To(Succeed())
+
+		_, err = pool.PickBestTx()
+		Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+	})
+
+	It("should pick best package which fits in budget", func() {
+		pool := newMemPool(nil)
+		Expect(pool.PutTx(readTx(parentFile))).To(Succeed())
+		Expect(pool.PutTx(readTx(childFile))).To(Succeed())
+
+		parent, err := pool.PickBestTx()
+		Expect(err).To(BeNil())
+
+		_, err = pool.PickBestTxWithinWeight(parent.Weight - 1)
+		Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+
+		// only txs without in mempool parents are candidates
+		txs, err := pool.PickBestTxsWithinBudget(4_000_000, 80_000, 10)
+		Expect(err).To(BeNil())
+		Expect(txs).To(HaveLen(1))
+		Expect(txs[0].ID).To(Equal(parent.ID))
+	})
+
+	It("should persist to and reload from sqlite", func() {
+		dbPath := filepath.Join(GinkgoT().TempDir(), "mempool.db")
+
+		pool := newMemPool(sqlite.Open(dbPath))
+		Expect(pool.PutTx(readTx(parentFile))).To(Succeed())
+		Expect(pool.PutTx(readTx(childFile))).To(Succeed())
+
+		parent, err := pool.PickBestTx()
+		Expect(err).To(BeNil())
+		Expect(pool.DeleteTx(parent.ID)).To(Succeed())
+
+		outputs, err := pool.GetOutputs(parent.Hash)
+		Expect(err).To(BeNil())
+		Expect(outputs).NotTo(BeEmpty())
+		Expect(pool.MarkOutPointSpent(parent.Hash, outputs[0].FundingTxPos)).To(Succeed())
+
+		reloaded := newMemPool(sqlite.Open(dbPath))
+		child, err := reloaded.PickBestTx()
+		Expect(err).To(BeNil())
+		Expect(child.ID).NotTo(Equal(parent.ID))
+		Expect(reloaded.MarkOutPointSpent(parent.Hash, outputs[0].FundingTxPos)).To(MatchError(ierrors.ErrAlreadySpent))
+
+		Expect(reloaded.ResetTables()).To(Succeed())
+		tx, err := reloaded.PickBestTx()
+		Expect(err).To(BeNil())
+		Expect(tx.ID).To(Equal(parent.ID))
+		Expect(reloaded.MarkOutPointSpent(parent.Hash, outputs[0].FundingTxPos)).To(Succeed())
+	})
+})
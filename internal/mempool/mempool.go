@@ -2,37 +2,76 @@ package mempool
 
 import (
 	"encoding/hex"
-	"os"
+	"encoding/json"
+	"fmt"
 	"sob-miner/internal/ierrors"
 	"sob-miner/pkg/address"
+	"sob-miner/pkg/chaincfg"
 	"sob-miner/pkg/opcode"
+	"sob-miner/pkg/script"
 	"sob-miner/pkg/transaction"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
-type mempool struct {
-	dust           uint64
-	maxTxSize      uint
-	maxMemPoolSize uint
-	db             *gorm.DB
-	logger         *logrus.Logger
-
-	mu             sync.RWMutex
-	rejectedTxFile *os.File
+// admission settings shared by mempool implementations
+type settings struct {
+	dust              uint64
+	maxTxSize         uint
+	acceptNonStandard bool
+	limits            Limits
+	maxMemPoolSize    uint
+	logger            *logrus.Logger
+	params            *chaincfg.Params
 }
 
 type Mempool interface {
 	DB() *gorm.DB
 	ResetTables() error
+	Close() error
 
 	PutTx(tx Transaction) error
+	// PrepareTx runs every admission check which doesn't need mempool state,
+	// safe to call concurrently. prepared txs are admitted in batches by AcceptTxs
+	PrepareTx(tx Transaction) (PreparedTx, error)
+	// AcceptTxs admits prepared txs in one go and returns an error per tx
+	AcceptTxs(txs []PreparedTx) []error
+	// SubmitPackage admits a child with its parents, parents first. a parent below min fee
+	// is admitted with its child when they pay min fee for each of them, see PackageResult
+	SubmitPackage(txs []Transaction) (PackageResult, error)
+	// TraceTx validates json tx without admitting it, recording every stage with
+	// script stacks of each input, for debugging rejections
+	TraceTx(data []byte) *Trace
+	// PutTxAt is PutTx keeping given entry time, used when reloading a dump
+	PutTxAt(tx Transaction, entryTime time.Time) error
+	// Txs returns every tx in mempool ordered by admission
+	Txs() ([]transaction.Tx, error)
 	PickBestTx() (transaction.Tx, error)
 	PickBestTxWithinWeight(weight uint64) (transaction.Tx, error)
+	PickBestTxWithinBudget(weight uint64, sigOpCost uint64) (transaction.Tx, error)
+	// PickBestTxsWithinBudget returns up to limit best fee rate txs fitting in budget one by one.
+	// none of them spends a tx still in mempool, so any subset of them can be mined
+	PickBestTxsWithinBudget(weight uint64, sigOpCost uint64, limit int) ([]transaction.Tx, error)
+	// DeleteTx removes mined tx, txs spending from it stay in mempool
 	DeleteTx(ID uint) error
+	// EvictTx removes tx rejected when mining with every tx spending from it directly or not,
+	// those can't be mined without it. evicted descendants are returned in admission order
+	EvictTx(ID uint) ([]transaction.Tx, error)
+
+	// PrioritiseTransaction adds feeDelta sats to fee tx is ranked by [with its package],
+	// fee paid to coinbase is unchanged. txid is in RPC byte order, tx doesn't have to be in mempool yet
+	PrioritiseTransaction(txid string, feeDelta int64) error
+	// FeeDeltas returns every prioritisation by txid
+	FeeDeltas() map[string]int64
+
+	// GetAncestors returns in mempool txs hash spends from directly or not,
+	// ierrors.ErrTxNotFound when hash isn't in mempool
+	GetAncestors(hash string) (Relatives, error)
+	// GetDescendants returns in mempool txs spending from hash directly or not
+	GetDescendants(hash string) (Relatives, error)
 
 	GetInputs(SpendingTxHash string) ([]transaction.InputTx, error)
 	GetOutputs(FundingTxHash string) ([]transaction.OutPutTx, error)
@@ -42,387 +81,306 @@ type Mempool interface {
 	ValidateWholeTx(tx transaction.Tx, inputs []transaction.InputTx) error
 }
 
+// New returns mempool on given Opts.Backend, txs are always indexed in memory by ancestor score
+//
+// - sql [default]: txs are stored in dialector's db
+// - memory: txs are stored in dialector's db or in memory only if dialector is nil
+// - bolt: txs are stored in bbolt file at Opts.Path
 func New(dialector gorm.Dialector, mempoolOpts Opts, opts ...gorm.Option) (Mempool, error) {
-	db, err := gorm.Open(dialector, opts...)
-	if err != nil {
-		return nil, err
+	settings := newSettings(mempoolOpts)
 
+	switch mempoolOpts.Backend {
+	case BackendMemory:
+		if dialector == nil {
+			return newMemPool(NewMemoryStore(), settings)
+		}
+		fallthrough
+	case BackendSQL, "":
+		store, err := NewSQLStore(dialector, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return newMemPool(store, settings)
+	case BackendBolt:
+		store, err := NewBoltStore(mempoolOpts.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newMemPool(store, settings)
+	default:
+		return nil, ErrUnknownBackend
 	}
+}
 
-	if err := db.AutoMigrate(transaction.Tx{}, transaction.InputTx{}, transaction.OutPutTx{}); err != nil {
-		return nil, err
+// NewWithStore returns mempool indexed in memory on top of given store, Opts.Backend is ignored
+func NewWithStore(store Store, mempoolOpts Opts) (Mempool, error) {
+	return newMemPool(store, newSettings(mempoolOpts))
+}
+
+func newSettings(mempoolOpts Opts) settings {
+	if mempoolOpts.Params == nil {
+		mempoolOpts.Params = &chaincfg.MainNetParams
 	}
 
-	var _tx transaction.Tx
-	stmt := db.Session(&gorm.Session{DryRun: true}).Order("fee_collected / weight desc").Limit(1).Find(&_tx).Limit(1).Statement
-	if err := db.Order("fee_collected / weight desc").Find(&_tx).Limit(1).Error; err != nil {
-		return nil, err
+	limits := mempoolOpts.Limits
+	if limits.Ancestors <= 0 {
+		limits.Ancestors = DefaultLimits.Ancestors
+	}
+	if limits.AncestorSize == 0 {
+		limits.AncestorSize = DefaultLimits.AncestorSize
+	}
+	if limits.Descendants <= 0 {
+		limits.Descendants = DefaultLimits.Descendants
+	}
+	if limits.DescendantSize == 0 {
+		limits.DescendantSize = DefaultLimits.DescendantSize
 	}
-	mempoolOpts.Logger.Infof("Dry Run Test: %v", stmt.SQL.String())
-	mempoolOpts.Logger.Infof("Dry Run Test: %v", _tx)
 
-	return &mempool{
-		db:     db,
+	return settings{
 		logger: mempoolOpts.Logger,
+		params: mempoolOpts.Params,
 
 		maxMemPoolSize: mempoolOpts.MaxMemPoolSize,
 
-		dust:      mempoolOpts.Dust,
-		maxTxSize: mempoolOpts.MaxTxSize,
-
-		mu: sync.RWMutex{},
-
-		// rejectedTxFile: ,
-	}, nil
+		dust:              mempoolOpts.Dust,
+		maxTxSize:         mempoolOpts.MaxTxSize,
+		acceptNonStandard: mempoolOpts.AcceptNonStandard,
+		limits:            limits,
+	}
 }
 
-func (m *mempool) DB() *gorm.DB {
-	return m.db
+// PreparedTx is a tx which passed stateless admission checks with rows to store it,
+// Outputs are prevouts spent by Inputs followed by outputs of tx
+type PreparedTx struct {
+	Tx      transaction.Tx
+	Inputs  []transaction.InputTx
+	Outputs []transaction.OutPutTx
 }
 
-// TODO: configure proper logger
-func (m *mempool) PutTx(tx Transaction) error {
-
-	if err := tx.Validate(); err != nil {
-		m.logger.Info("tx id is invalid ", err)
-		return err
-	}
+func (s *settings) PrepareTx(tx Transaction) (PreparedTx, error) {
+	return s.prepare(tx, nil)
+}
 
-	txHash, wtxid, weight, err := tx.Hash()
+// prepare is PrepareTx recording its stages to t
+func (s *settings) prepare(tx Transaction, t *Trace) (PreparedTx, error) {
+	p, err := s.prepareRows(tx, t)
 	if err != nil {
-		m.logger.Info("unable to compute Hash", err)
-		return err
+		return PreparedTx{}, err
 	}
 
-	_tx := transaction.Tx{
-		Version:  tx.Version,
-		Locktime: tx.Locktime,
-		Hash:     txHash,
-		Weight:   uint64(weight),
-		WTXID:    wtxid,
+	if p.Tx.FeeCollected < s.dust {
+		s.logger.Info("fee collected is less than dust")
+		err := ierrors.Reject(ierrors.CodeMinFee, ierrors.ErrFeeTooLow, fmt.Sprintf("pays %d sats, %d needed", p.Tx.FeeCollected, s.dust))
+		t.Add(TraceFee, "min fee", err)
+		return PreparedTx{}, err
 	}
+	t.Add(TraceFee, fmt.Sprintf("pays min fee of %d sats", s.dust), nil)
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	amountLoad, err := m.PutInputTx(tx.Vin, txHash)
-	if err != nil {
-		m.logger.Info("unable to PutInputTx", err)
-		return err
-	}
+	return p, nil
+}
 
-	amountSpent, err := m.PutOutputTx(tx.Vout, []string{txHash}, []uint32{})
+// prepareRows is PrepareTx without min fee check, a package may pay it for tx.
+// spending more than inputs hold is never fine
+func (s *settings) prepareRows(tx Transaction, t *Trace) (PreparedTx, error) {
+	_tx, err := s.prepareTx(tx, t)
 	if err != nil {
-		m.logger.Infof("unable to PutOutputTx %v for tx %v", err, txHash)
-		return err
-	}
-
-	feeCollected := amountLoad - amountSpent
-
-	if feeCollected < int(m.dust) {
-		m.logger.Info("fee collected is less than dust")
-		return ierrors.ErrFeeTooLow
+		return PreparedTx{}, err
 	}
 
-	_tx.FeeCollected = uint64(feeCollected)
-	return m.db.Create(&_tx).Error
-}
-
-// TODO: batch writes to db
-func (m *mempool) PutInputTx(Vin []TxIn, spendingHash string) (amountLoaded int, err error) {
-
-	outputs := []TxOut{}
-
-	fundingTxIndexes := []uint32{}
-	fundingTxHashes := []string{}
+	inputs := []transaction.InputTx{}
+	outputs := []transaction.OutPutTx{}
+	amountLoad, amountSpent := uint64(0), uint64(0)
 
-	inputTxs := []transaction.InputTx{}
-
-	isRBF := false
-
-	for i := 0; i < len(Vin); i++ {
-		witness := ""
-		for j := 0; j < len(Vin[i].Witness); j++ {
-			if j != 0 {
-				witness += ","
-			}
-			witness += Vin[i].Witness[j]
+	for i, in := range tx.Vin {
+		prevOut, err := s.newOutPutTx(in.Prevout, in.Txid, in.Vout)
+		if err != nil {
+			err = ierrors.RejectInput("", i, err, "prevout")
+			t.Add(TraceParse, "prevouts match their scripts", err)
+			return PreparedTx{}, err
 		}
 
-		inputTx := transaction.InputTx{
-			SpendingTxHash: spendingHash,
-
-			FundingTxHash: Vin[i].Txid,
-			FundingIndex:  Vin[i].Vout,
-
-			ScriptSig: Vin[i].ScriptSig,
-			Sequence:  Vin[i].Sequence,
-			ScriptAsm: Vin[i].ScriptSigAsm,
-			Witness:   witness,
-
-			IsCoinbase: Vin[i].IsCoinbase, // no coinbase txs in given mempool [might remove in future iterations]
+		inputs = append(inputs, newInputTx(in, _tx.Hash))
+		outputs = append(outputs, prevOut)
+		amountLoad += in.Prevout.Value
+	}
 
-			InnerWitnessScriptAsm: Vin[i].InnerWitnessScriptAsm,
-			InnerRedeemScriptAsm:  Vin[i].InnerRedeemScriptAsm,
+	for i, out := range tx.Vout {
+		outPutTx, err := s.newOutPutTx(out, _tx.Hash, uint32(i))
+		if err != nil {
+			err = ierrors.Reject("", err, fmt.Sprintf("output %d", i))
+			t.Add(TraceParse, "outputs match their scripts", err)
+			return PreparedTx{}, err
 		}
 
-		inputTxs = append(inputTxs, inputTx)
-
-		outputs = append(outputs, Vin[i].Prevout)
-		fundingTxIndexes = append(fundingTxIndexes, Vin[i].Vout)
-		fundingTxHashes = append(fundingTxHashes, Vin[i].Txid)
-
-		if Vin[i].Sequence <= 0xFFFFFFFD && !isRBF {
-			isRBF = true
-		}
+		outputs = append(outputs, outPutTx)
+		amountSpent += out.Value
 	}
+	t.Add(TraceParse, "prevouts and outputs match their scripts", nil)
 
-	if err := m.db.Create(&inputTxs).Error; err != nil {
-		m.logger.Info("unable to create input txs", err)
-		return 0, err
+	if amountLoad < amountSpent {
+		s.logger.Info("tx spends more than its inputs")
+		err := ierrors.Reject(ierrors.CodeInBelowOut, ierrors.ErrFeeTooLow, fmt.Sprintf("spends %d sats of %d", amountSpent, amountLoad))
+		t.Add(TraceFee, "inputs cover outputs", err)
+		return PreparedTx{}, err
 	}
+	_tx.FeeCollected = amountLoad - amountSpent
+	t.Add(TraceFee, fmt.Sprintf("inputs %d sats outputs %d sats fee %d sats", amountLoad, amountSpent, _tx.FeeCollected), nil)
 
-	amountLoaded, err = m.PutOutputTx(outputs, fundingTxHashes, fundingTxIndexes)
-	if err != nil {
-		m.logger.Infof("unable to PutOutputTx %v for tx %v", err, spendingHash)
-		return 0, err
-	}
-
-	return amountLoaded, nil
+	return PreparedTx{Tx: _tx, Inputs: inputs, Outputs: outputs}, nil
 }
 
-// TODO: batch writes to db
-func (m *mempool) PutOutputTx(Vout []TxOut, fundingTxHashes []string, fundingIndexes []uint32) (amountSpent int, err error) {
-	amountSpent = 0
-
-	if len(fundingIndexes) == 0 {
-		for i := 0; i < len(Vout); i++ {
-			fundingIndexes = append(fundingIndexes, uint32(i))
-		}
+// prepareTx runs tx level checks which don't need mempool state and returns tx row without fee
+func (s *settings) prepareTx(tx Transaction, t *Trace) (transaction.Tx, error) {
+	if err := tx.Validate(); err != nil {
+		s.logger.Info("tx id is invalid ", err)
+		t.Add(TraceParse, "required fields", err)
+		return transaction.Tx{}, err
 	}
 
-	if len(fundingTxHashes) == 1 {
-		for i := 1; i < len(Vout); i++ {
-			fundingTxHashes = append(fundingTxHashes, fundingTxHashes[0])
-		}
+	txHash, wtxid, weight, err := tx.Hash()
+	if err != nil {
+		s.logger.Info("unable to compute Hash", err)
+		t.Add(TraceHash, "serialize", err)
+		return transaction.Tx{}, err
 	}
-
-	if len(Vout) != len(fundingIndexes) {
-		m.logger.Info("len(Vout) != len(fundingIndexes)")
-		return 0, ierrors.ErrInvalidTx
+	if t != nil {
+		t.Txid = HashToTxid(txHash)
+		t.Add(TraceHash, fmt.Sprintf("txid %s wtxid %s weight %d", t.Txid, HashToTxid(wtxid), weight), nil)
 	}
 
-	for i := 0; i < len(Vout); i++ {
-		amountSpent += int(Vout[i].Value)
-
-		outPutTx := transaction.OutPutTx{
-			FundingTxHash: fundingTxHashes[i],
-			FundingTxPos:  uint32(fundingIndexes[i]),
-			ScriptPubKey:  Vout[i].ScriptPubKey,
-			ScriptAsm:     Vout[i].ScriptPubKeyAsm,
-			ScriptType:    transaction.Type(Vout[i].ScriptPubKeyType),
-			ScriptAddress: Vout[i].ScriptPubKeyAddress,
-			Value:         Vout[i].Value,
-		}
-
-		if err := m.ValidateOutput(outPutTx); err != nil {
-			m.logger.Info("unable to ValidateOutput", err)
-			return 0, err
-		}
-
-		var tempOut transaction.OutPutTx
-
-		// check if outpoint already exists in db by fundingTxHash and index
-		if err := m.db.Where("funding_tx_hash = ? AND funding_tx_pos = ?", outPutTx.FundingTxHash, fundingIndexes[i]).Take(&tempOut).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				if err := m.db.Create(&outPutTx).Error; err != nil {
-					m.logger.Info("unable to create outpoint tx", err)
-					return 0, err
-				}
-				continue
-			}
+	// coinbase is only valid as first tx of a block
+	if tx.IsCoinbase() {
+		t.Add(TracePolicy, "not a coinbase", ierrors.ErrCoinbaseInMempool)
+		return transaction.Tx{}, ierrors.ErrCoinbaseInMempool
+	}
 
-			m.logger.Info("err while fetching outpoint from db", err)
-			return 0, err
+	if !s.acceptNonStandard {
+		if err := tx.CheckStandard(); err != nil {
+			s.logger.Info("tx is non standard ", err)
+			t.Add(TracePolicy, "standard", err)
+			return transaction.Tx{}, err
 		}
-
-		m.logger.Debugf("outpoint %s:%d already exists in db ID %d obj %v and err %v", outPutTx.FundingTxHash, fundingIndexes[i], tempOut.ID, tempOut, err)
-		continue
 	}
 
-	return amountSpent, nil
-}
-
-func (m *mempool) PickBestTx() (transaction.Tx, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var _tx transaction.Tx
-
-	if err := m.db.Order("fee_collected / weight desc").Find(&_tx).Limit(1).Error; err != nil {
+	sigOpCost, err := tx.SigOpCost()
+	if err != nil {
+		s.logger.Info("unable to count sigops ", err)
+		t.Add(TracePolicy, "sigop cost", err)
 		return transaction.Tx{}, err
 	}
+	t.Add(TracePolicy, fmt.Sprintf("standard with sigop cost %d", sigOpCost), nil)
 
-	return _tx, nil
+	return transaction.Tx{
+		Version:   tx.Version,
+		Locktime:  tx.Locktime,
+		Hash:      txHash,
+		Weight:    uint64(weight),
+		SigOpCost: sigOpCost,
+		WTXID:     wtxid,
+	}, nil
 }
 
-func (m *mempool) GetInputs(SpendingTxHash string) ([]transaction.InputTx, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// input row of Vin spent by spendingHash
+func newInputTx(in TxIn, spendingHash string) transaction.InputTx {
+	return transaction.InputTx{
+		SpendingTxHash: spendingHash,
 
-	var inputs []transaction.InputTx
-	if err := m.db.Where("spending_tx_hash = ?", SpendingTxHash).Find(&inputs).Error; err != nil {
-		return nil, err
-	}
+		FundingTxHash: in.Txid,
+		FundingIndex:  in.Vout,
 
-	return inputs, nil
-}
+		ScriptSig: in.ScriptSig,
+		Sequence:  in.Sequence,
+		ScriptAsm: in.ScriptSigAsm,
+		Witness:   joinWitness(in.Witness),
 
-func (m *mempool) GetOutputs(FundingTxHash string) ([]transaction.OutPutTx, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+		IsCoinbase: in.IsCoinbase, // no coinbase txs in given mempool [might remove in future iterations]
 
-	var outputs []transaction.OutPutTx
-	if err := m.db.Where("funding_tx_hash = ?", FundingTxHash).Find(&outputs).Error; err != nil {
-		return nil, err
+		InnerWitnessScriptAsm: in.InnerWitnessScriptAsm,
+		InnerRedeemScriptAsm:  in.InnerRedeemScriptAsm,
 	}
-
-	return outputs, nil
 }
 
-func (m *mempool) GetOutPointByIndex(FundingTxHash string, index uint32) (transaction.OutPutTx, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var output transaction.OutPutTx
-	if err := m.db.Where("funding_tx_hash = ? AND funding_tx_pos = ?", FundingTxHash, index).Find(&output).Error; err != nil {
-		return transaction.OutPutTx{}, err
+// newOutPutTx classifies and validates out, funded by fundingHash at pos
+func (s *settings) newOutPutTx(out TxOut, fundingHash string, pos uint32) (transaction.OutPutTx, error) {
+	// derive script type from scriptpubkey bytes instead of trusting json label
+	scriptPubKey, err := hex.DecodeString(out.ScriptPubKey)
+	if err != nil {
+		s.logger.Info("invalid scriptpubkey hex ", out.ScriptPubKey)
+		return transaction.OutPutTx{}, ierrors.ErrInvalidScript
 	}
 
-	return output, nil
-}
-
-func (m *mempool) MarkOutPointSpent(FundingTxHash string, index uint32) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	var output transaction.OutPutTx
-	if err := m.db.Where("funding_tx_hash = ? AND funding_tx_pos = ?", FundingTxHash, index).Find(&output).Error; err != nil {
-		return err
+	class := script.Classify(scriptPubKey)
+	if !script.TypeMatches(transaction.Type(out.ScriptPubKeyType), class.Type) {
+		s.logger.Infof("script type mismatch claimed %v classified %v", out.ScriptPubKeyType, class.Type)
+		return transaction.OutPutTx{}, ierrors.ErrScriptTypeMismatch
 	}
 
-	if output.Spent {
-		m.logger.Info("outpoint already spent", FundingTxHash, index)
-		return ierrors.ErrAlreadySpent
+	outPutTx := transaction.OutPutTx{
+		FundingTxHash: fundingHash,
+		FundingTxPos:  pos,
+		ScriptPubKey:  out.ScriptPubKey,
+		ScriptAsm:     out.ScriptPubKeyAsm,
+		ScriptType:    class.Type,
+		ScriptAddress: out.ScriptPubKeyAddress,
+		Value:         out.Value,
 	}
 
-	output.Spent = true
-	if err := m.db.Save(&output).Error; err != nil {
-		return err
+	if err := s.validateOutput(outPutTx); err != nil {
+		s.logger.Info("unable to ValidateOutput", err)
+		return transaction.OutPutTx{}, err
 	}
 
-	return nil
+	return outPutTx, nil
 }
 
-// UnUsed
-func (m *mempool) ValidateInput(input transaction.InputTx) error {
+func (s *settings) validateOutput(out transaction.OutPutTx) error {
+	if out.ScriptAsm == "" {
+		return nil
+	}
 
-	// TODO: validate sequence only if tx version is 2
-	if input.Sequence > 0xffffffff {
-		return ierrors.ErrInvalidSequence
+	// generate asm from scriptpubkey instead of trusting given one
+	scriptPubKey, err := hex.DecodeString(out.ScriptPubKey)
+	if err != nil {
+		s.logger.Info("invalid hex string ", out.ScriptPubKey, err)
+		return ierrors.ErrInvalidScript
 	}
 
-	prevOut, err := m.GetOutPointByIndex(input.FundingTxHash, input.FundingIndex)
+	asm, err := opcode.Disassemble(scriptPubKey)
 	if err != nil {
-		m.logger.Info("err while fetching outpoint from db", err)
+		s.logger.Infof("unable to disassemble %v %v", out.ScriptPubKey, err)
 		return err
 	}
 
-	switch prevOut.ScriptType {
-	case transaction.OP_RETURN_TYPE:
-		return ierrors.ErrUsingOpReturnAsInput
-	case transaction.P2MS:
-	case transaction.P2PKH,
-		transaction.P2WPKH, transaction.P2WSH,
-		transaction.P2TR, transaction.P2PK, transaction.P2SH:
-	default:
-		m.logger.Info("invalid script type", prevOut.ScriptType)
-		return ierrors.ErrInvalidScript
+	if asm != out.ScriptAsm {
+		s.logger.Infof("asm and script mismatch %v %v", asm, out.ScriptAsm)
+		return ierrors.ErrAsmAndScriptMismatch
 	}
 
-	return nil
-}
-
-func (m *mempool) ValidateOutput(out transaction.OutPutTx) error {
-
 	if out.ScriptType == transaction.OP_RETURN_TYPE {
 		return nil
 	}
 
-	if out.ScriptAsm == "" {
-		return nil
-	}
-	asmScript := strings.Split(out.ScriptAsm, " ")
-
-	decoded_script := []byte{}
-	for _, item := range asmScript {
-		if len(item) > 3 && item[:3] == "OP_" {
-			bytedecoded, ok := opcode.OpCodeMap[item]
-			if !ok {
-				m.logger.Info("invalid opcode", item, out.ScriptAddress)
-				return ierrors.ErrInvalidOpCode
-			}
-			decoded_script = append(decoded_script, bytedecoded)
-			continue
-		}
-
-		byteItem, err := hex.DecodeString(item)
-		if err != nil {
-			m.logger.Info("invalid hex string", " "+item+" ", err, " "+out.ScriptAddress)
-			return err
-		}
-
-		decoded_script = append(decoded_script, byteItem...)
-	}
-
-	hexstring := hex.EncodeToString(decoded_script)
-	if hexstring != out.ScriptPubKey {
-		m.logger.Infof("asm and script mismatch %v %v", hexstring, out.ScriptPubKey)
-		return ierrors.ErrAsmAndScriptMismatch
-	}
-
-	encodedAddress, err := address.EncodeAddress(out.ScriptAsm, out.ScriptType)
+	encodedAddress, err := address.EncodeAddress(out.ScriptAsm, out.ScriptType, s.params)
 	if err != nil {
-		m.logger.Infof("unable to encode address %v for script %v", err, out.ScriptAsm)
+		s.logger.Infof("unable to encode address %v for script %v", err, out.ScriptAsm)
 		return err
 	}
 
 	if encodedAddress != out.ScriptAddress {
-		m.logger.Info("asm and address mismatch", encodedAddress, out.ScriptAddress)
+		s.logger.Info("asm and address mismatch", encodedAddress, out.ScriptAddress)
 		return ierrors.ErrInvalidAddress
 	}
 
 	return nil
 }
 
-func (m *mempool) DeleteTx(ID uint) error {
-	return m.db.Delete(&transaction.Tx{}, ID).Error
-}
-
-func (m *mempool) ValidateWholeTx(tx transaction.Tx, inputs []transaction.InputTx) error {
-
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
+// assembleTx rebuilds tx from its stored rows, prevOuts[i] is spent by inputs[i]
+func assembleTx(tx transaction.Tx, inputs []transaction.InputTx, prevOuts []transaction.OutPutTx, outputs []transaction.OutPutTx) Transaction {
 	var Vins []TxIn
 	var Vouts []TxOut
 
-	for _, input := range inputs {
-		outpoint, err := m.GetOutPointByIndex(input.FundingTxHash, input.FundingIndex)
-		if err != nil {
-			return err
-		}
-
+	for i, input := range inputs {
+		outpoint := prevOuts[i]
 		Vins = append(Vins, TxIn{
 			Txid: input.FundingTxHash,
 			Vout: input.FundingIndex,
@@ -435,7 +393,7 @@ func (m *mempool) ValidateWholeTx(tx transaction.Tx, inputs []transaction.InputT
 			},
 			ScriptSig:    input.ScriptSig,
 			ScriptSigAsm: input.ScriptAsm,
-			Witness:      strings.Split(input.Witness, ","),
+			Witness:      splitWitness(input.Witness),
 			Sequence:     input.Sequence,
 
 			InnerWitnessScriptAsm: input.InnerWitnessScriptAsm,
@@ -443,11 +401,6 @@ func (m *mempool) ValidateWholeTx(tx transaction.Tx, inputs []transaction.InputT
 		})
 	}
 
-	outputs, err := m.GetOutputs(tx.Hash)
-	if err != nil {
-		return err
-	}
-
 	for _, output := range outputs {
 		Vouts = append(Vouts, TxOut{
 			ScriptPubKey:        output.ScriptPubKey,
@@ -458,39 +411,37 @@ func (m *mempool) ValidateWholeTx(tx transaction.Tx, inputs []transaction.InputT
 		})
 	}
 
-	wholeTx := Transaction{
+	return Transaction{
 		Version:  tx.Version,
 		Locktime: tx.Locktime,
 		Vin:      Vins,
 		Vout:     Vouts,
 	}
-
-	return wholeTx.ValidateTxScripts()
 }
 
-func (m *mempool) ResetTables() error {
-	if err := m.db.Exec("UPDATE txes SET deleted_at = NULL;").Error; err != nil {
-		return err
-	}
+// 6a4c58325b1056bbd88c79d8a9a1648ff834e11d75cd5053aaa1d1878c2cfa809d7cb75913b944fa322a1f943a4f5c9c103548622aa92e1fa448e7c83d244a39b9da02f16c000cbbf60001000cabd5000849
+// 6a4c5058325b1056bbd88c79d8a9a1648ff834e11d75cd5053aaa1d1878c2cfa809d7cb75913b944fa322a1f943a4f5c9c103548622aa92e1fa448e7c83d244a39b9da02f16c000cbbf60001000cabd5000849
 
-	if err := m.db.Exec("UPDATE out_put_txes SET spent = false;").Error; err != nil {
-		return err
+// witness items are stored as json array so an empty item is kept, no items is stored as empty string
+func joinWitness(items []string) string {
+	if len(items) == 0 {
+		return ""
 	}
 
-	return nil
+	// marshalling strings can't fail
+	data, _ := json.Marshal(items)
+	return string(data)
 }
 
-func (m *mempool) PickBestTxWithinWeight(weight uint64) (transaction.Tx, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var _tx transaction.Tx
-	if err := m.db.Where("weight <= ?", weight).Order("fee_collected / weight desc").Take(&_tx).Limit(1).Error; err != nil {
-		return transaction.Tx{}, err
+// splitWitness reads items stored by joinWitness, or comma joined by older dbs
+func splitWitness(witness string) []string {
+	if witness == "" {
+		return nil
 	}
 
-	return _tx, nil
+	var items []string
+	if strings.HasPrefix(witness, "[") && json.Unmarshal([]byte(witness), &items) == nil {
+		return items
+	}
+	return strings.Split(witness, ",")
 }
-
-// 6a4c58325b1056bbd88c79d8a9a1648ff834e11d75cd5053aaa1d1878c2cfa809d7cb75913b944fa322a1f943a4f5c9c103548622aa92e1fa448e7c83d244a39b9da02f16c000cbbf60001000cabd5000849
-// 6a4c5058325b1056bbd88c79d8a9a1648ff834e11d75cd5053aaa1d1878c2cfa809d7cb75913b944fa322a1f943a4f5c9c103548622aa92e1fa448e7c83d244a39b9da02f16c000cbbf60001000cabd5000849
@@ -1,6 +1,8 @@
 package mempool_test
 
 import (
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/mempool/mempooltest"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -11,3 +13,19 @@ func TestMempool(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Mempool Suite")
 }
+
+// openStore returns an empty store of backend, closed once spec ends
+func openStore(backend mempool.Backend) mempool.Store {
+	store, err := mempooltest.NewStore(backend, GinkgoT().TempDir())
+	Expect(err).To(BeNil())
+	DeferCleanup(store.Close)
+	return store
+}
+
+// openPool returns an empty mempool on store of backend, closed once spec ends
+func openPool(backend mempool.Backend, opts mempool.Opts) mempool.Mempool {
+	pool, err := mempooltest.New(backend, GinkgoT().TempDir(), opts)
+	Expect(err).To(BeNil())
+	DeferCleanup(pool.Close)
+	return pool
+}
@@ -8,8 +8,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sob-miner/internal/ierrors"
 	"sob-miner/internal/mempool"
 	"sob-miner/internal/path"
+	"sob-miner/pkg/chaincfg"
 	"strings"
 	"time"
 
@@ -59,6 +61,104 @@ var _ = Describe("Mempool", func() {
 			Expect(tx.ValidateTxScripts()).To(BeNil())
 		})
 	})
+	Context("Test Future Witness Versions", func() {
+		tx := mempool.Transaction{
+			Version: 2,
+			Vin: []mempool.TxIn{{
+				Txid:     "6ae73833e5f58616445bfe35171e89b23c5b59ef585637537f6ba34a019449ac",
+				Vout:     0,
+				Sequence: 0xffffffff,
+				Prevout: mempool.TxOut{
+					Value:            10_000,
+					ScriptPubKey:     "5210751e76e8199196d454941c45d1b3a323",
+					ScriptPubKeyType: "witness_unknown",
+				},
+			}},
+			Vout: []mempool.TxOut{{Value: 9_000, ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6"}},
+		}
+
+		It("should be valid by consensus", func() {
+			Expect(tx.ValidateTxScripts()).To(Succeed())
+		})
+
+		It("should be non standard to spend", func() {
+			err := tx.CheckStandard()
+			Expect(err).To(MatchError(ierrors.ErrNonStandard))
+			Expect(ierrors.CodeOf(err)).To(Equal(ierrors.CodeNonStandard))
+			Expect(ierrors.CodeOf(err).Class()).To(Equal(ierrors.Policy))
+
+			input, ok := ierrors.InputOf(err)
+			Expect(ok).To(BeTrue())
+			Expect(input).To(Equal(0))
+		})
+	})
+
+	Context("Test SigOp Cost", func() {
+		It("should scale legacy sigops and count witness sigops once", func() {
+			tx := mempool.Transaction{
+				Version: 2,
+				Vin: []mempool.TxIn{
+					{
+						Txid:     "6ae73833e5f58616445bfe35171e89b23c5b59ef585637537f6ba34a019449ac",
+						Sequence: 0xffffffff,
+						Prevout:  mempool.TxOut{ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+						Witness:  []string{"3044", "02b2fb48ce4536bc0218d0d72d84d791f07649b0650cecb46d9b1ee94afc1785d4"},
+					},
+					{
+						Txid:      "6ae73833e5f58616445bfe35171e89b23c5b59ef585637537f6ba34a019449ac",
+						Vout:      1,
+						Sequence:  0xffffffff,
+						Prevout:   mempool.TxOut{ScriptPubKey: "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac"},
+						ScriptSig: "023044" + "2102b2fb48ce4536bc0218d0d72d84d791f07649b0650cecb46d9b1ee94afc1785d4",
+					},
+				},
+				Vout: []mempool.TxOut{
+					{Value: 1_000, ScriptPubKey: "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac"},
+					{Value: 1_000, ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+				},
+			}
+
+			// p2pkh output x4 + p2wpkh input
+			cost, err := tx.SigOpCost()
+			Expect(err).To(BeNil())
+			Expect(cost).To(Equal(uint64(5)))
+		})
+	})
+
+	Context("Test Coinbase Validation", func() {
+		coinbase := func(value uint64, height uint32) mempool.Transaction {
+			return mempool.Transaction{
+				Version: 2,
+				Vin: []mempool.TxIn{{
+					Txid:       "0000000000000000000000000000000000000000000000000000000000000000",
+					Vout:       0xffffffff,
+					ScriptSig:  hex.EncodeToString(mempool.CoinbaseHeightScript(height)),
+					Sequence:   0xffffffff,
+					IsCoinbase: true,
+				}},
+				Vout: []mempool.TxOut{{Value: value, ScriptPubKey: "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac"}},
+			}
+		}
+
+		It("should encode BIP34 height", func() {
+			Expect(hex.EncodeToString(mempool.CoinbaseHeightScript(835_944))).To(Equal("0368c10c"))
+			Expect(hex.EncodeToString(mempool.CoinbaseHeightScript(128))).To(Equal("028000"))
+			Expect(hex.EncodeToString(mempool.CoinbaseHeightScript(5))).To(Equal("5500"))
+		})
+
+		It("should accept subsidy plus fees", func() {
+			Expect(mempool.ValidateCoinbase(coinbase(6_2500_0000+1000, 835_944), 835_944, 1000, &chaincfg.MainNetParams)).To(Succeed())
+		})
+
+		It("should reject coinbase claiming more than subsidy plus fees", func() {
+			Expect(mempool.ValidateCoinbase(coinbase(6_2500_0000+1001, 835_944), 835_944, 1000, &chaincfg.MainNetParams)).To(MatchError(ierrors.ErrBadCoinbaseValue))
+		})
+
+		It("should reject coinbase committing to wrong height", func() {
+			Expect(mempool.ValidateCoinbase(coinbase(1000, 835_943), 835_944, 1000, &chaincfg.MainNetParams)).To(MatchError(ierrors.ErrBadCoinbaseHeight))
+		})
+	})
+
 	Context("Test Transaction Hash", func() {
 		BeforeEach(func() {
 			Skip("Skipping for now")
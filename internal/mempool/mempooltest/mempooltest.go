@@ -0,0 +1,40 @@
+// Package mempooltest opens every Store implementation by backend name, so specs of
+// mempool and of its users run against each of them from one table
+package mempooltest
+
+import (
+	"path/filepath"
+	"sob-miner/internal/mempool"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// Backends in order specs run against them
+var Backends = []mempool.Backend{mempool.BackendSQL, mempool.BackendMemory, mempool.BackendBolt}
+
+// NewStore returns an empty store of backend keeping its files in dir,
+// memory backend is kept in memory only
+func NewStore(backend mempool.Backend, dir string) (mempool.Store, error) {
+	switch backend {
+	case mempool.BackendSQL:
+		return mempool.NewSQLStore(sqlite.Open(filepath.Join(dir, "mempool.db")), &gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+	case mempool.BackendMemory:
+		return mempool.NewMemoryStore(), nil
+	case mempool.BackendBolt:
+		return mempool.NewBoltStore(filepath.Join(dir, "mempool.bolt"))
+	default:
+		return nil, mempool.ErrUnknownBackend
+	}
+}
+
+// New returns an empty mempool on store of backend, opts.Backend is ignored
+func New(backend mempool.Backend, dir string, opts mempool.Opts) (mempool.Mempool, error) {
+	store, err := NewStore(backend, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return mempool.NewWithStore(store, opts)
+}
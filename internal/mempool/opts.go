@@ -1,14 +1,50 @@
 package mempool
 
-import "github.com/sirupsen/logrus"
+import (
+	"errors"
+	"sob-miner/pkg/chaincfg"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Backend selects Mempool implementation returned by New
+type Backend string
+
+const (
+	// txs are kept in memory indexed by ancestor score, persisted in sql db
+	BackendSQL Backend = "sql"
+
+	// txs are kept in memory indexed by ancestor score, db is optional persistence
+	BackendMemory Backend = "memory"
+
+	// txs are kept in memory indexed by ancestor score, persisted in bbolt file at Opts.Path
+	BackendBolt Backend = "bolt"
+)
+
+var ErrUnknownBackend = errors.New("unknown mempool backend")
 
 type Opts struct {
 	Logger *logrus.Logger
 
+	// defaults to BackendSQL
+	Backend Backend
+
+	// file used by BackendBolt
+	Path string
+
+	// network params used for address encoding, defaults to mainnet
+	Params *chaincfg.Params
+
 	// mempoolConfig
 	MaxMemPoolSize uint
 
 	// tx config
 	Dust      uint64
 	MaxTxSize uint
+
+	// accept txs failing relay policy but valid by consensus [-acceptnonstdtxn]
+	AcceptNonStandard bool
+
+	// ancestor and descendant limits at admission, zero fields default to DefaultLimits
+	Limits Limits
 }
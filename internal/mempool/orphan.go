@@ -0,0 +1,288 @@
+package mempool
+
+import (
+	"fmt"
+	"sob-miner/internal/ierrors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// bitcoind's DEFAULT_MAX_ORPHAN_TRANSACTIONS, ORPHAN_TX_EXPIRE_TIME and MAX_STANDARD_TX_WEIGHT
+const (
+	DefaultMaxOrphans      = 100
+	DefaultOrphanExpiry    = 20 * time.Minute
+	DefaultMaxOrphanWeight = 400_000
+)
+
+type OrphanOpts struct {
+	// zero values default to DefaultMaxOrphans, DefaultMaxOrphanWeight and DefaultOrphanExpiry
+	MaxOrphans int
+	MaxWeight  int
+	Expiry     time.Duration
+}
+
+// Outpoint is an output of a tx, txid is in rpc order like TxIn.Txid
+type Outpoint struct {
+	Txid string
+	Vout uint32
+}
+
+// Orphan is a tx waiting for txs it spends from
+type Orphan struct {
+	Tx     Transaction
+	Txid   string
+	Source string // caller's label, e.g. file tx was read from
+
+	// txids of parents tx still waits for, sorted
+	Parents []string
+	Expires time.Time
+
+	missing map[Outpoint]struct{}
+	seq     uint64
+}
+
+// OrphanPool holds txs spending outputs of txs which weren't admitted yet, like bitcoind's TxOrphanage.
+// without utxo set it can't tell a missing parent from a mined one, so caller names missing parents
+// when adding a tx and reports every parent admitted or rejected
+type OrphanPool struct {
+	mu   sync.Mutex
+	opts OrphanOpts
+
+	orphans map[string]*Orphan
+	// orphans by missing outpoint, outpoints by parent so a parent resolves all its outputs at once
+	byOutpoint map[Outpoint]map[string]*Orphan
+	byParent   map[string]map[Outpoint]struct{}
+
+	seq uint64
+}
+
+func NewOrphanPool(opts OrphanOpts) *OrphanPool {
+	if opts.MaxOrphans <= 0 {
+		opts.MaxOrphans = DefaultMaxOrphans
+	}
+	if opts.MaxWeight <= 0 {
+		opts.MaxWeight = DefaultMaxOrphanWeight
+	}
+	if opts.Expiry <= 0 {
+		opts.Expiry = DefaultOrphanExpiry
+	}
+
+	return &OrphanPool{
+		opts:       opts,
+		orphans:    map[string]*Orphan{},
+		byOutpoint: map[Outpoint]map[string]*Orphan{},
+		byParent:   map[string]map[Outpoint]struct{}{},
+	}
+}
+
+// Add keeps tx until every outpoint it spends of txs in parents is resolved. when pool is
+// over MaxOrphans orphans added first are evicted and returned
+func (o *OrphanPool) Add(tx Transaction, source string, parents []string, now time.Time) ([]Orphan, error) {
+	hash, _, weight, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if weight > o.opts.MaxWeight {
+		return nil, fmt.Errorf("%w: weight %d exceeds %d", ierrors.ErrOrphanTooLarge, weight, o.opts.MaxWeight)
+	}
+
+	isParent := map[string]bool{}
+	for _, parent := range parents {
+		isParent[parent] = true
+	}
+
+	missing := map[Outpoint]struct{}{}
+	for _, in := range tx.Vin {
+		if isParent[in.Txid] {
+			missing[Outpoint{Txid: in.Txid, Vout: in.Vout}] = struct{}{}
+		}
+	}
+	if len(missing) == 0 {
+		return nil, fmt.Errorf("%w: spends none of its missing parents", ierrors.ErrInvalidTx)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	txid := HashToTxid(hash)
+	if _, ok := o.orphans[txid]; ok {
+		return nil, fmt.Errorf("%w: already an orphan", ierrors.ErrTxAlreadyExists)
+	}
+
+	o.seq++
+	orphan := &Orphan{
+		Tx:      tx,
+		Txid:    txid,
+		Source:  source,
+		Expires: now.Add(o.opts.Expiry),
+		missing: missing,
+		seq:     o.seq,
+	}
+	o.orphans[txid] = orphan
+
+	for op := range missing {
+		if o.byOutpoint[op] == nil {
+			o.byOutpoint[op] = map[string]*Orphan{}
+		}
+		o.byOutpoint[op][txid] = orphan
+
+		if o.byParent[op.Txid] == nil {
+			o.byParent[op.Txid] = map[Outpoint]struct{}{}
+		}
+		o.byParent[op.Txid][op] = struct{}{}
+	}
+
+	evicted := []Orphan{}
+	for len(o.orphans) > o.opts.MaxOrphans {
+		oldest := o.sorted(o.orphans)[0]
+		o.remove(oldest)
+		evicted = append(evicted, oldest.copy())
+	}
+	return evicted, nil
+}
+
+// Resolve marks outputs of admitted parent txid as available and returns orphans
+// which no longer miss anything, those leave pool and can be admitted again
+func (o *OrphanPool) Resolve(txid string) []Orphan {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ready := map[string]*Orphan{}
+	for op := range o.byParent[txid] {
+		for id, orphan := range o.byOutpoint[op] {
+			delete(orphan.missing, op)
+			if len(orphan.missing) == 0 {
+				ready[id] = orphan
+			}
+		}
+		delete(o.byOutpoint, op)
+	}
+	delete(o.byParent, txid)
+
+	return o.take(ready)
+}
+
+// Fail removes and returns orphans spending rejected parent txid, they can never be admitted
+func (o *OrphanPool) Fail(txid string) []Orphan {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.take(o.children(txid))
+}
+
+// Children are orphans waiting for txid, they stay in pool
+func (o *OrphanPool) Children(txid string) []Orphan {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	children := []Orphan{}
+	for _, orphan := range o.sorted(o.children(txid)) {
+		children = append(children, orphan.copy())
+	}
+	return children
+}
+
+// Remove drops orphan txid, e.g. when it was admitted some other way
+func (o *OrphanPool) Remove(txid string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	orphan, ok := o.orphans[txid]
+	if ok {
+		o.remove(orphan)
+	}
+	return ok
+}
+
+// Expire removes and returns orphans which waited past their expiry
+func (o *OrphanPool) Expire(now time.Time) []Orphan {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	expired := map[string]*Orphan{}
+	for txid, orphan := range o.orphans {
+		if !now.Before(orphan.Expires) {
+			expired[txid] = orphan
+		}
+	}
+	return o.take(expired)
+}
+
+// Drain removes and returns every orphan
+func (o *OrphanPool) Drain() []Orphan {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.take(o.orphans)
+}
+
+func (o *OrphanPool) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.orphans)
+}
+
+func (o *OrphanPool) children(txid string) map[string]*Orphan {
+	children := map[string]*Orphan{}
+	for op := range o.byParent[txid] {
+		for id, orphan := range o.byOutpoint[op] {
+			children[id] = orphan
+		}
+	}
+	return children
+}
+
+// take removes orphans and returns them in order they were added
+func (o *OrphanPool) take(orphans map[string]*Orphan) []Orphan {
+	taken := []Orphan{}
+	for _, orphan := range o.sorted(orphans) {
+		o.remove(orphan)
+		taken = append(taken, orphan.copy())
+	}
+	return taken
+}
+
+func (o *OrphanPool) remove(orphan *Orphan) {
+	delete(o.orphans, orphan.Txid)
+
+	for op := range orphan.missing {
+		delete(o.byOutpoint[op], orphan.Txid)
+		if len(o.byOutpoint[op]) > 0 {
+			continue
+		}
+		delete(o.byOutpoint, op)
+
+		delete(o.byParent[op.Txid], op)
+		if len(o.byParent[op.Txid]) == 0 {
+			delete(o.byParent, op.Txid)
+		}
+	}
+}
+
+func (o *OrphanPool) sorted(orphans map[string]*Orphan) []*Orphan {
+	s := make([]*Orphan, 0, len(orphans))
+	for _, orphan := range orphans {
+		s = append(s, orphan)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].seq < s[j].seq })
+	return s
+}
+
+// copy fills Parents from outpoints orphan still misses
+func (orphan *Orphan) copy() Orphan {
+	c := *orphan
+	c.missing = nil
+
+	seen := map[string]bool{}
+	c.Parents = []string{}
+	for op := range orphan.missing {
+		if !seen[op.Txid] {
+			seen[op.Txid] = true
+			c.Parents = append(c.Parents, op.Txid)
+		}
+	}
+	sort.Strings(c.Parents)
+	return c
+}
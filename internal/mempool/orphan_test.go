@@ -0,0 +1,110 @@
+package mempool_test
+
+import (
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Orphan Pool", func() {
+	var (
+		now    time.Time
+		parent mempool.Transaction
+		// parent file spends from two txs not in dataset
+		first, second string
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		parent = readTx(parentFile)
+		first, second = parent.Vin[0].Txid, parent.Vin[1].Txid
+	})
+
+	txids := func(orphans []mempool.Orphan) []string {
+		txids := []string{}
+		for _, o := range orphans {
+			txids = append(txids, o.Txid)
+		}
+		return txids
+	}
+
+	It("should give an orphan back once every parent is resolved", func() {
+		pool := mempool.NewOrphanPool(mempool.OrphanOpts{})
+		evicted, err := pool.Add(parent, parentFile, []string{first, second}, now)
+		Expect(err).To(BeNil())
+		Expect(evicted).To(BeEmpty())
+
+		children := pool.Children(first)
+		Expect(children).To(HaveLen(1))
+		Expect(children[0].Txid).To(Equal(strings.TrimSuffix(parentFile, ".json")))
+		Expect(children[0].Source).To(Equal(parentFile))
+		Expect(children[0].Parents).To(ConsistOf(first, second))
+
+		Expect(pool.Resolve(first)).To(BeEmpty())
+		Expect(pool.Children(second)[0].Parents).To(Equal([]string{second}))
+
+		Expect(txids(pool.Resolve(second))).To(Equal([]string{children[0].Txid}))
+		Expect(pool.Len()).To(BeZero())
+	})
+
+	It("should drop orphans of a failed parent", func() {
+		pool := mempool.NewOrphanPool(mempool.OrphanOpts{})
+		_, err := pool.Add(parent, parentFile, []string{first, second}, now)
+		Expect(err).To(BeNil())
+
+		Expect(pool.Fail(second)).To(HaveLen(1))
+		Expect(pool.Len()).To(BeZero())
+		Expect(pool.Children(first)).To(BeEmpty())
+		Expect(pool.Resolve(first)).To(BeEmpty())
+	})
+
+	It("should refuse txs it can't hold", func() {
+		pool := mempool.NewOrphanPool(mempool.OrphanOpts{MaxWeight: 100})
+		_, err := pool.Add(parent, parentFile, []string{first}, now)
+		Expect(err).To(MatchError(ierrors.ErrOrphanTooLarge))
+
+		pool = mempool.NewOrphanPool(mempool.OrphanOpts{})
+		_, err = pool.Add(parent, parentFile, []string{strings.Repeat("00", 32)}, now)
+		Expect(err).To(MatchError(ierrors.ErrInvalidTx))
+
+		_, err = pool.Add(parent, parentFile, []string{first}, now)
+		Expect(err).To(BeNil())
+		_, err = pool.Add(parent, parentFile, []string{first}, now)
+		Expect(err).To(MatchError(ierrors.ErrTxAlreadyExists))
+	})
+
+	It("should evict orphans added first when full", func() {
+		child, p2pkh := readTx(childFile), readTx(p2pkhFile)
+
+		pool := mempool.NewOrphanPool(mempool.OrphanOpts{MaxOrphans: 2})
+		_, err := pool.Add(parent, parentFile, []string{first}, now)
+		Expect(err).To(BeNil())
+		_, err = pool.Add(child, childFile, []string{child.Vin[0].Txid}, now)
+		Expect(err).To(BeNil())
+
+		evicted, err := pool.Add(p2pkh, p2pkhFile, []string{p2pkh.Vin[0].Txid}, now)
+		Expect(err).To(BeNil())
+		Expect(evicted).To(HaveLen(1))
+		Expect(evicted[0].Source).To(Equal(parentFile))
+
+		Expect(pool.Len()).To(Equal(2))
+		Expect(pool.Children(first)).To(BeEmpty())
+	})
+
+	It("should expire orphans", func() {
+		pool := mempool.NewOrphanPool(mempool.OrphanOpts{Expiry: time.Minute})
+		_, err := pool.Add(parent, parentFile, []string{first}, now)
+		Expect(err).To(BeNil())
+
+		Expect(pool.Expire(now.Add(time.Second))).To(BeEmpty())
+
+		expired := pool.Expire(now.Add(time.Minute))
+		Expect(expired).To(HaveLen(1))
+		Expect(expired[0].Source).To(Equal(parentFile))
+		Expect(pool.Len()).To(BeZero())
+	})
+})
@@ -0,0 +1,240 @@
+package mempool
+
+import (
+	"fmt"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/transaction"
+)
+
+// bitcoind's MAX_PACKAGE_COUNT and MAX_PACKAGE_WEIGHT
+const (
+	MaxPackageCount  = 25
+	MaxPackageWeight = 404_000
+)
+
+// PackageResult is outcome of SubmitPackage
+type PackageResult struct {
+	// per tx in package order, nil for txs admitted or already in mempool
+	Errs []error
+
+	// hashes of txs which didn't pay min fee alone and were evaluated together, with their totals
+	Package []string
+	Fee     uint64
+	VSize   uint64
+}
+
+// packageAdmitter is what SubmitPackage needs from a mempool, caller holds write lock
+type packageAdmitter interface {
+	graph() txGraph
+	// accept admits p alone running every admission check
+	accept(p *PreparedTx) error
+	// acceptAll stores txs already checked against limits, in one db transaction when backend has one
+	acceptAll(txs []PreparedTx) error
+}
+
+// submitPackage admits txs like bitcoind's submitpackage. txs paying min fee are admitted alone
+// in package order, the rest with every tx spending from them are admitted together only when
+// they pay min fee for each of them and stay within limits as a whole
+func (s *settings) submitPackage(a packageAdmitter, txs []Transaction) (PackageResult, error) {
+	pkg, err := s.preparePackage(txs)
+	if err != nil {
+		return PackageResult{}, err
+	}
+	result := PackageResult{Errs: make([]error, len(pkg))}
+
+	hashes := make([]string, len(pkg))
+	for i := range pkg {
+		hashes[i] = pkg[i].Tx.Hash
+	}
+
+	g := a.graph()
+	existing, err := g.txs(hashes)
+	if err != nil {
+		return PackageResult{}, err
+	}
+
+	inMempool := map[string]bool{}
+	for _, tx := range existing {
+		inMempool[tx.Hash] = true
+	}
+
+	deferred := map[string]bool{}
+	rest := []int{}
+	for i := range pkg {
+		p := &pkg[i]
+		if inMempool[p.Tx.Hash] {
+			continue
+		}
+
+		// a child of a deferred tx is paying for it
+		if p.Tx.FeeCollected >= s.dust && !spendsAny(*p, deferred) {
+			result.Errs[i] = a.accept(p)
+			continue
+		}
+
+		deferred[p.Tx.Hash] = true
+		rest = append(rest, i)
+	}
+
+	if len(rest) == 0 {
+		return result, nil
+	}
+
+	together := make([]PreparedTx, len(rest))
+	for j, i := range rest {
+		together[j] = pkg[i]
+		result.Package = append(result.Package, pkg[i].Tx.Hash)
+		result.Fee += pkg[i].Tx.FeeCollected
+		result.VSize += pkg[i].Tx.VSize()
+	}
+
+	fail := func(err error) (PackageResult, error) {
+		for _, i := range rest {
+			result.Errs[i] = err
+		}
+		return result, nil
+	}
+
+	if needed := s.dust * uint64(len(rest)); result.Fee < needed {
+		return fail(fmt.Errorf("%w: %d txs pay %d sats, %d needed", ierrors.ErrPackageFeeTooLow, len(rest), result.Fee, needed))
+	}
+
+	// every tx is checked against mempool as it would be with whole package in
+	overlay := newPackageGraph(g, together)
+	for _, p := range together {
+		if err := s.limits.check(overlay, p.Tx, spendsOf(p.Inputs)); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := a.acceptAll(together); err != nil {
+		return fail(err)
+	}
+	return result, nil
+}
+
+// preparePackage runs stateless checks on every tx and checks package is a child with its parents,
+// parents first, without two txs spending same outpoint
+func (s *settings) preparePackage(txs []Transaction) ([]PreparedTx, error) {
+	if len(txs) == 0 || len(txs) > MaxPackageCount {
+		return nil, fmt.Errorf("%w: %d txs, 1 to %d allowed", ierrors.ErrBadPackage, len(txs), MaxPackageCount)
+	}
+
+	pkg := make([]PreparedTx, len(txs))
+	position := map[string]int{}
+	spent := map[outpoint]struct{}{}
+	weight := uint64(0)
+
+	for i, tx := range txs {
+		p, err := s.prepareRows(tx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d of package: %w", i, err)
+		}
+
+		if _, ok := position[p.Tx.Hash]; ok {
+			return nil, fmt.Errorf("%w: tx %d is a duplicate", ierrors.ErrBadPackage, i)
+		}
+
+		for _, in := range p.Inputs {
+			op := outpoint{hash: in.FundingTxHash, index: in.FundingIndex}
+			if _, ok := spent[op]; ok {
+				return nil, fmt.Errorf("%w: tx %d spends outpoint spent by another tx", ierrors.ErrBadPackage, i)
+			}
+			spent[op] = struct{}{}
+		}
+
+		pkg[i], position[p.Tx.Hash] = p, i
+		weight += p.Tx.Weight
+	}
+
+	if weight > MaxPackageWeight {
+		return nil, fmt.Errorf("%w: weight %d exceeds %d", ierrors.ErrBadPackage, weight, MaxPackageWeight)
+	}
+
+	for i := range pkg {
+		for _, hash := range spendsOf(pkg[i].Inputs) {
+			if j, ok := position[hash]; ok && j > i {
+				return nil, fmt.Errorf("%w: tx %d spends later tx %d", ierrors.ErrBadPackage, i, j)
+			}
+		}
+	}
+
+	child := map[string]bool{}
+	for _, hash := range spendsOf(pkg[len(pkg)-1].Inputs) {
+		child[hash] = true
+	}
+	for i := 0; i < len(pkg)-1; i++ {
+		if !child[pkg[i].Tx.Hash] {
+			return nil, fmt.Errorf("%w: tx %d is not a parent of last tx", ierrors.ErrBadPackage, i)
+		}
+	}
+
+	return pkg, nil
+}
+
+func spendsAny(p PreparedTx, hashes map[string]bool) bool {
+	for _, hash := range spendsOf(p.Inputs) {
+		if hashes[hash] {
+			return true
+		}
+	}
+	return false
+}
+
+// packageGraph is mempool graph with package txs in it
+type packageGraph struct {
+	base txGraph
+
+	txsByHash map[string]transaction.Tx
+	spendsBy  map[string][]string
+	children  map[string][]transaction.Tx
+}
+
+func newPackageGraph(base txGraph, pkg []PreparedTx) packageGraph {
+	g := packageGraph{
+		base:      base,
+		txsByHash: map[string]transaction.Tx{},
+		spendsBy:  map[string][]string{},
+		children:  map[string][]transaction.Tx{},
+	}
+
+	for _, p := range pkg {
+		spends := spendsOf(p.Inputs)
+		g.txsByHash[p.Tx.Hash] = p.Tx
+		g.spendsBy[p.Tx.Hash] = spends
+
+		for _, hash := range spends {
+			g.children[hash] = append(g.children[hash], p.Tx)
+		}
+	}
+	return g
+}
+
+func (g packageGraph) txs(hashes []string) ([]transaction.Tx, error) {
+	txs, err := g.base.txs(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		if tx, ok := g.txsByHash[hash]; ok {
+			txs = append(txs, tx)
+		}
+	}
+	return txs, nil
+}
+
+func (g packageGraph) spends(hash string) ([]string, error) {
+	if spends, ok := g.spendsBy[hash]; ok {
+		return spends, nil
+	}
+	return g.base.spends(hash)
+}
+
+func (g packageGraph) spenders(hash string) ([]transaction.Tx, error) {
+	txs, err := g.base.spenders(hash)
+	if err != nil {
+		return nil, err
+	}
+	return append(txs, g.children[hash]...), nil
+}
@@ -0,0 +1,120 @@
+package mempool_test
+
+import (
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/mempool/mempooltest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// parent pays 237 sats, below min fee, its only child pays 4286 sats
+const (
+	lowFeeParentFile = "e6d870ecd3e78026506da94b80411048053758e2f8f248061b553bfe15e76392.json"
+	payingChildFile  = "30808259fd38cf81b921cbba2755620032aead75bcaebcd3521f3683981e9b48.json"
+)
+
+var _ = Describe("Package", func() {
+	for _, backend := range mempooltest.Backends {
+		backend := backend
+		newPool := func(opts mempool.Opts) mempool.Mempool {
+			return openPool(backend, opts)
+		}
+
+		Describe(string(backend), func() {
+			opts := mempool.Opts{Logger: silentLogger(), Dust: 546}
+			parent, child := fileHash(lowFeeParentFile), fileHash(payingChildFile)
+
+			submit := func(pool mempool.Mempool, files ...string) (mempool.PackageResult, error) {
+				txs := []mempool.Transaction{}
+				for _, name := range files {
+					txs = append(txs, readTx(name))
+				}
+				return pool.SubmitPackage(txs)
+			}
+
+			It("should admit low fee parent with child paying for it", func() {
+				pool := newPool(opts)
+				Expect(pool.PutTx(readTx(lowFeeParentFile))).To(MatchError(ierrors.ErrFeeTooLow))
+
+				result, err := submit(pool, lowFeeParentFile, payingChildFile)
+				Expect(err).To(BeNil())
+				Expect(result.Errs).To(Equal([]error{nil, nil}))
+				Expect(result.Package).To(Equal([]string{parent, child}))
+				Expect(result.Fee).To(Equal(uint64(237 + 4286)))
+
+				p, c := txByHash(pool, parent), txByHash(pool, child)
+				Expect(result.VSize).To(Equal(p.VSize() + c.VSize()))
+				Expect(p.FeeCollected).To(Equal(uint64(237)))
+
+				anc, err := pool.GetAncestors(child)
+				Expect(err).To(BeNil())
+				Expect(anc.Count).To(Equal(1))
+
+				// resubmitting finds both in mempool
+				result, err = submit(pool, lowFeeParentFile, payingChildFile)
+				Expect(err).To(BeNil())
+				Expect(result.Errs).To(Equal([]error{nil, nil}))
+				Expect(result.Package).To(BeEmpty())
+			})
+
+			It("should admit txs paying min fee alone", func() {
+				pool := newPool(opts)
+
+				result, err := submit(pool, chainFiles[0], chainFiles[1])
+				Expect(err).To(BeNil())
+				Expect(result.Errs).To(Equal([]error{nil, nil}))
+				Expect(result.Package).To(BeEmpty())
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(HaveLen(2))
+			})
+
+			It("should reject package not paying min fee for each tx", func() {
+				pool := newPool(mempool.Opts{Logger: silentLogger(), Dust: 3000})
+
+				result, err := submit(pool, lowFeeParentFile, payingChildFile)
+				Expect(err).To(BeNil())
+				Expect(result.Errs[0]).To(MatchError(ierrors.ErrPackageFeeTooLow))
+				Expect(result.Errs[1]).To(MatchError(ierrors.ErrPackageFeeTooLow))
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(BeEmpty())
+			})
+
+			It("should check package against limits as a whole", func() {
+				pool := newPool(mempool.Opts{Logger: silentLogger(), Dust: 546, Limits: mempool.Limits{Ancestors: 1}})
+
+				result, err := submit(pool, lowFeeParentFile, payingChildFile)
+				Expect(err).To(BeNil())
+				Expect(result.Errs[0]).To(MatchError(ierrors.ErrAncestorLimit))
+				Expect(result.Errs[1]).To(MatchError(ierrors.ErrAncestorLimit))
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(BeEmpty())
+			})
+
+			It("should reject malformed packages", func() {
+				pool := newPool(opts)
+
+				for _, files := range [][]string{
+					{},
+					{payingChildFile, lowFeeParentFile},  // not sorted
+					{lowFeeParentFile, lowFeeParentFile}, // duplicate
+					{p2pkhFile, payingChildFile},         // not a parent
+				} {
+					_, err := submit(pool, files...)
+					Expect(err).To(MatchError(ierrors.ErrBadPackage))
+				}
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(BeEmpty())
+			})
+		})
+	}
+})
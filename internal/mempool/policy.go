@@ -0,0 +1,42 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/script"
+	"sob-miner/pkg/transaction"
+)
+
+// a single tx may use at most a fifth of block sigop budget [MAX_STANDARD_TX_SIGOPS_COST]
+const MaxStandardTxSigOpsCost = script.MaxBlockSigOpsCost / 5
+
+// CheckStandard applies relay policy on top of consensus rules.
+// a tx failing it is valid in a block but is not accepted into the mempool.
+//
+// - spending future segwit versions is non standard, those programs are anyone-can-spend
+// today and become encumbered once a soft fork gives them meaning [DISCOURAGE_UPGRADABLE_WITNESS_PROGRAM]
+// - sigop cost above MaxStandardTxSigOpsCost is non standard
+func (t *Transaction) CheckStandard() error {
+	for i, input := range t.Vin {
+		prevOut, err := hex.DecodeString(input.Prevout.ScriptPubKey)
+		if err != nil {
+			return ierrors.ErrInvalidScript
+		}
+
+		if script.Classify(prevOut).Type == transaction.WitnessUnknown {
+			return ierrors.RejectInput(ierrors.CodeNonStandard, i, ierrors.ErrNonStandard, "spends upgradable witness program")
+		}
+	}
+
+	sigOpCost, err := t.SigOpCost()
+	if err != nil {
+		return err
+	}
+
+	if sigOpCost > MaxStandardTxSigOpsCost {
+		return fmt.Errorf("%w: sigop cost %d exceeds %d", ierrors.ErrNonStandard, sigOpCost, MaxStandardTxSigOpsCost)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"sob-miner/internal/ierrors"
+)
+
+// feeDeltas are prioritisations by tx hash, a delta given before its tx arrives
+// is kept and applied on admission
+type feeDeltas map[string]int64
+
+// byTxid returns copy of deltas keyed by RPC byte order txid
+func (d feeDeltas) byTxid() map[string]int64 {
+	deltas := make(map[string]int64, len(d))
+	for hash, delta := range d {
+		deltas[HashToTxid(hash)] = delta
+	}
+	return deltas
+}
+
+// txidHash validates RPC byte order txid and converts it into tx hash
+func txidHash(txid string) (string, error) {
+	if b, err := hex.DecodeString(txid); err != nil || len(b) != 32 {
+		return "", ierrors.ErrInvalidTxid
+	}
+	return txidToHash(txid), nil
+}
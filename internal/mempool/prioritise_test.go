@@ -0,0 +1,130 @@
+package mempool_test
+
+import (
+	"bytes"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/mempool/mempooltest"
+	"sob-miner/pkg/chaincfg"
+	"sob-miner/pkg/transaction"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func txByHash(pool mempool.Mempool, hash string) transaction.Tx {
+	txs, err := pool.Txs()
+	Expect(err).To(BeNil())
+	for _, tx := range txs {
+		if tx.Hash == hash {
+			return tx
+		}
+	}
+	Fail("tx not in mempool " + hash)
+	return transaction.Tx{}
+}
+
+var _ = Describe("Prioritisation", func() {
+	for _, backend := range mempooltest.Backends {
+		backend := backend
+		newPool := func() mempool.Mempool {
+			return openPool(backend, mempool.Opts{Logger: silentLogger(), Dust: 546})
+		}
+
+		Describe(string(backend), func() {
+			It("should rank by modified fee without changing paid fee", func() {
+				pool := newPool()
+				Expect(pool.PutTx(readTx(p2pkhFile))).To(Succeed())
+				Expect(pool.PutTx(readTx(p2shP2wshFile))).To(Succeed())
+
+				best, err := pool.PickBestTx()
+				Expect(err).To(BeNil())
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				other := txs[0]
+				if other.Hash == best.Hash {
+					other = txs[1]
+				}
+
+				Expect(pool.PrioritiseTransaction(mempool.HashToTxid(other.Hash), 1_000_000)).To(Succeed())
+				picked, err := pool.PickBestTx()
+				Expect(err).To(BeNil())
+				Expect(picked.Hash).To(Equal(other.Hash))
+				Expect(picked.FeeCollected).To(Equal(other.FeeCollected))
+				Expect(picked.ModifiedFee()).To(Equal(other.FeeCollected + 1_000_000))
+
+				// deltas add up
+				Expect(pool.PrioritiseTransaction(mempool.HashToTxid(other.Hash), -1_000_000)).To(Succeed())
+				picked, err = pool.PickBestTx()
+				Expect(err).To(BeNil())
+				Expect(picked.Hash).To(Equal(best.Hash))
+				Expect(pool.FeeDeltas()).To(Equal(map[string]int64{mempool.HashToTxid(other.Hash): 0}))
+			})
+
+			It("should apply delta given before tx arrives", func() {
+				pool := newPool()
+				Expect(pool.PutTx(readTx(p2pkhFile))).To(Succeed())
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				hash := txs[0].Hash
+				Expect(pool.DeleteTx(txs[0].ID)).To(Succeed())
+
+				fresh := newPool()
+				Expect(fresh.PrioritiseTransaction(mempool.HashToTxid(hash), 5000)).To(Succeed())
+				Expect(fresh.PutTx(readTx(p2pkhFile))).To(Succeed())
+				Expect(txByHash(fresh, hash).FeeDelta).To(Equal(int64(5000)))
+			})
+
+			It("should reject malformed txids", func() {
+				pool := newPool()
+				Expect(pool.PrioritiseTransaction("abcd", 1)).To(MatchError(ierrors.ErrInvalidTxid))
+				Expect(pool.PrioritiseTransaction(strings.Repeat("zz", 32), 1)).To(MatchError(ierrors.ErrInvalidTxid))
+			})
+
+			It("should let prioritised child pull its parent into block", func() {
+				pool := newPool()
+				Expect(pool.PutTx(readTx(parentFile))).To(Succeed())
+				Expect(pool.PutTx(readTx(childFile))).To(Succeed())
+				Expect(pool.PutTx(readTx(p2pkhFile))).To(Succeed())
+
+				txs, err := pool.Txs()
+				Expect(err).To(BeNil())
+				parent, child := txs[0], txs[1]
+
+				// parent alone ranks last, child pays for it
+				Expect(pool.PrioritiseTransaction(mempool.HashToTxid(parent.Hash), -int64(parent.FeeCollected))).To(Succeed())
+				Expect(pool.PrioritiseTransaction(mempool.HashToTxid(child.Hash), 10_000_000)).To(Succeed())
+
+				best, err := pool.PickBestTx()
+				Expect(err).To(BeNil())
+				Expect(best.Hash).To(Equal(parent.Hash))
+				Expect(best.ModifiedFee()).To(BeZero())
+			})
+		})
+	}
+
+	It("should persist deltas through dump", func() {
+		pool := newMemPool(nil)
+		Expect(pool.PutTx(readTx(p2pkhFile))).To(Succeed())
+		txs, err := pool.Txs()
+		Expect(err).To(BeNil())
+
+		pending := strings.Repeat("ab", 32)
+		Expect(pool.PrioritiseTransaction(mempool.HashToTxid(txs[0].Hash), 2500)).To(Succeed())
+		Expect(pool.PrioritiseTransaction(pending, -300)).To(Succeed())
+
+		var dump bytes.Buffer
+		_, err = mempool.Dump(pool, &dump)
+		Expect(err).To(BeNil())
+
+		reloaded := newMemPool(nil)
+		result, err := mempool.Load(reloaded, &dump, &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(result.Accepted).To(Equal(1))
+
+		Expect(reloaded.FeeDeltas()).To(Equal(pool.FeeDeltas()))
+		Expect(txByHash(reloaded, txs[0].Hash).FeeDelta).To(Equal(int64(2500)))
+	})
+})
@@ -0,0 +1,64 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/script"
+)
+
+// SigOpCost returns sigop cost of tx, port of bitcoin core's GetTransactionSigOpCost.
+//
+// - legacy sigops of every input scriptSig and output scriptPubKey x4, prevout scriptPubKeys are not counted
+// - P2SH redeem script sigops x4
+// - witness sigops x1
+func (t *Transaction) SigOpCost() (uint64, error) {
+	legacy := 0
+	for _, out := range t.Vout {
+		scriptPubKey, err := hex.DecodeString(out.ScriptPubKey)
+		if err != nil {
+			return 0, ierrors.ErrInvalidScript
+		}
+		legacy += script.CountSigOps(scriptPubKey, false)
+	}
+
+	for _, input := range t.Vin {
+		scriptSig, err := hex.DecodeString(input.ScriptSig)
+		if err != nil {
+			return 0, ierrors.ErrInvalidScript
+		}
+		legacy += script.CountSigOps(scriptSig, false)
+	}
+
+	cost := uint64(legacy * script.WitnessScaleFactor)
+
+	// coinbase has no prevouts
+	if t.IsCoinbase() {
+		return cost, nil
+	}
+
+	for _, input := range t.Vin {
+		scriptSig, err := hex.DecodeString(input.ScriptSig)
+		if err != nil {
+			return 0, ierrors.ErrInvalidScript
+		}
+
+		prevOut, err := hex.DecodeString(input.Prevout.ScriptPubKey)
+		if err != nil {
+			return 0, ierrors.ErrInvalidScript
+		}
+
+		witness := make([][]byte, 0, len(input.Witness))
+		for _, item := range input.Witness {
+			data, err := hex.DecodeString(item)
+			if err != nil {
+				return 0, ierrors.ErrInvalidTx
+			}
+			witness = append(witness, data)
+		}
+
+		cost += uint64(script.P2SHSigOps(prevOut, scriptSig) * script.WitnessScaleFactor)
+		cost += uint64(script.WitnessSigOps(scriptSig, prevOut, witness))
+	}
+
+	return cost, nil
+}
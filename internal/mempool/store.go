@@ -0,0 +1,47 @@
+package mempool
+
+import (
+	"sob-miner/pkg/transaction"
+)
+
+// Store persists mempool rows, validation and tx selection of Mempool sit on top of it.
+// every implementation must pass store conformance suite in store_test.go
+type Store interface {
+	// AddTx writes tx with its inputs and outputs atomically and assigns tx.ID,
+	// tx.CreatedAt is set to now unless given.
+	// outputs whose outpoint is already stored [shared prevouts] are skipped
+	AddTx(tx *transaction.Tx, inputs []transaction.InputTx, outputs []transaction.OutPutTx) error
+
+	// DeleteTx soft deletes tx, it is restored by Reset. unknown ID is a no-op
+	DeleteTx(ID uint) error
+
+	// SetFeeDelta updates prioritisation of tx, returns ierrors.ErrTxNotFound for unknown ID
+	SetFeeDelta(ID uint, feeDelta int64) error
+
+	// Txs returns every not deleted tx ordered by ID
+	Txs() ([]transaction.Tx, error)
+
+	// Inputs returns inputs of spending tx in insertion order
+	Inputs(spendingTxHash string) ([]transaction.InputTx, error)
+
+	// Outputs returns outputs funded by tx ordered by index
+	Outputs(fundingTxHash string) ([]transaction.OutPutTx, error)
+
+	// Output returns ierrors.ErrTxNotFound for an unknown outpoint
+	Output(fundingTxHash string, index uint32) (transaction.OutPutTx, error)
+
+	// MarkSpent returns ierrors.ErrAlreadySpent if outpoint is spent
+	// and ierrors.ErrTxNotFound if it is unknown
+	MarkSpent(fundingTxHash string, index uint32) error
+
+	// Reset restores deleted txs and marks every output unspent
+	Reset() error
+
+	Close() error
+}
+
+// batcher is a Store able to write several txs in one transaction, a failing AddTx
+// of batch only rolls back that tx. whole batch is rolled back when fn fails
+type batcher interface {
+	Batch(fn func(Store) error) error
+}
@@ -0,0 +1,302 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/transaction"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"gorm.io/gorm"
+)
+
+var (
+	txsBucket     = []byte("txs")
+	inputsBucket  = []byte("inputs")
+	outputsBucket = []byte("outputs")
+)
+
+// boltStore keeps rows in an embedded bbolt key value file as json
+//
+// - txs:     big endian ID -> tx
+// - inputs:  spending tx hash / big endian sequence -> input
+// - outputs: funding tx hash / big endian index -> output
+//
+// hash prefixed keys keep rows of a tx adjacent and in order for cursor scans
+type boltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{txsBucket, inputsBucket, outputsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func idKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+func hashPrefix(hash string) []byte {
+	return append([]byte(hash), '/')
+}
+
+func outpointKey(hash string, index uint32) []byte {
+	return binary.BigEndian.AppendUint32(hashPrefix(hash), index)
+}
+
+func put(b *bolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func (s *boltStore) AddTx(tx *transaction.Tx, inputs []transaction.InputTx, outputs []transaction.OutPutTx) error {
+	return s.db.Update(func(btx *bolt.Tx) error {
+		now := time.Now().UTC()
+
+		txs := btx.Bucket(txsBucket)
+		id, err := txs.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		row := *tx
+		row.ID = uint(id)
+		if row.CreatedAt.IsZero() {
+			row.CreatedAt = now
+		}
+		row.UpdatedAt = now
+		if err := put(txs, idKey(id), row); err != nil {
+			return err
+		}
+
+		ins := btx.Bucket(inputsBucket)
+		for _, input := range inputs {
+			seq, err := ins.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			input.ID = uint(seq)
+			input.CreatedAt, input.UpdatedAt = now, now
+			if err := put(ins, binary.BigEndian.AppendUint64(hashPrefix(input.SpendingTxHash), seq), input); err != nil {
+				return err
+			}
+		}
+
+		outs := btx.Bucket(outputsBucket)
+		for _, out := range outputs {
+			key := outpointKey(out.FundingTxHash, out.FundingTxPos)
+			if outs.Get(key) != nil {
+				continue
+			}
+
+			seq, err := outs.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			out.ID = uint(seq)
+			out.CreatedAt, out.UpdatedAt = now, now
+			if err := put(outs, key, out); err != nil {
+				return err
+			}
+		}
+
+		*tx = row
+		return nil
+	})
+}
+
+func (s *boltStore) DeleteTx(ID uint) error {
+	return s.db.Update(func(btx *bolt.Tx) error {
+		txs := btx.Bucket(txsBucket)
+		data := txs.Get(idKey(uint64(ID)))
+		if data == nil {
+			return nil
+		}
+
+		var tx transaction.Tx
+		if err := json.Unmarshal(data, &tx); err != nil {
+			return err
+		}
+
+		if tx.DeletedAt.Valid {
+			return nil
+		}
+		tx.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+		return put(txs, idKey(uint64(ID)), tx)
+	})
+}
+
+func (s *boltStore) SetFeeDelta(ID uint, feeDelta int64) error {
+	return s.db.Update(func(btx *bolt.Tx) error {
+		txs := btx.Bucket(txsBucket)
+		data := txs.Get(idKey(uint64(ID)))
+		if data == nil {
+			return ierrors.ErrTxNotFound
+		}
+
+		var tx transaction.Tx
+		if err := json.Unmarshal(data, &tx); err != nil {
+			return err
+		}
+
+		if tx.DeletedAt.Valid {
+			return ierrors.ErrTxNotFound
+		}
+
+		tx.FeeDelta = feeDelta
+		return put(txs, idKey(uint64(ID)), tx)
+	})
+}
+
+func (s *boltStore) Txs() ([]transaction.Tx, error) {
+	txs := []transaction.Tx{}
+	err := s.db.View(func(btx *bolt.Tx) error {
+		return btx.Bucket(txsBucket).ForEach(func(_, data []byte) error {
+			var tx transaction.Tx
+			if err := json.Unmarshal(data, &tx); err != nil {
+				return err
+			}
+
+			if !tx.DeletedAt.Valid {
+				txs = append(txs, tx)
+			}
+			return nil
+		})
+	})
+	return txs, err
+}
+
+// scan decodes every value under hash prefix into a new T
+func scan[T any](db *bolt.DB, bucket []byte, hash string) ([]T, error) {
+	rows := []T{}
+	prefix := hashPrefix(hash)
+
+	err := db.View(func(btx *bolt.Tx) error {
+		c := btx.Bucket(bucket).Cursor()
+		for k, data := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, data = c.Next() {
+			var row T
+			if err := json.Unmarshal(data, &row); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *boltStore) Inputs(spendingTxHash string) ([]transaction.InputTx, error) {
+	return scan[transaction.InputTx](s.db, inputsBucket, spendingTxHash)
+}
+
+func (s *boltStore) Outputs(fundingTxHash string) ([]transaction.OutPutTx, error) {
+	return scan[transaction.OutPutTx](s.db, outputsBucket, fundingTxHash)
+}
+
+func (s *boltStore) Output(fundingTxHash string, index uint32) (transaction.OutPutTx, error) {
+	var out transaction.OutPutTx
+	err := s.db.View(func(btx *bolt.Tx) error {
+		data := btx.Bucket(outputsBucket).Get(outpointKey(fundingTxHash, index))
+		if data == nil {
+			return ierrors.ErrTxNotFound
+		}
+		return json.Unmarshal(data, &out)
+	})
+	return out, err
+}
+
+func (s *boltStore) MarkSpent(fundingTxHash string, index uint32) error {
+	return s.db.Update(func(btx *bolt.Tx) error {
+		outs := btx.Bucket(outputsBucket)
+		key := outpointKey(fundingTxHash, index)
+
+		data := outs.Get(key)
+		if data == nil {
+			return ierrors.ErrTxNotFound
+		}
+
+		var out transaction.OutPutTx
+		if err := json.Unmarshal(data, &out); err != nil {
+			return err
+		}
+
+		if out.Spent {
+			return ierrors.ErrAlreadySpent
+		}
+
+		out.Spent = true
+		return put(outs, key, out)
+	})
+}
+
+func (s *boltStore) Reset() error {
+	return s.db.Update(func(btx *bolt.Tx) error {
+		txs := btx.Bucket(txsBucket)
+		if err := rewrite(txs, func(tx *transaction.Tx) bool {
+			changed := tx.DeletedAt.Valid
+			tx.DeletedAt = gorm.DeletedAt{}
+			return changed
+		}); err != nil {
+			return err
+		}
+
+		return rewrite(btx.Bucket(outputsBucket), func(out *transaction.OutPutTx) bool {
+			changed := out.Spent
+			out.Spent = false
+			return changed
+		})
+	})
+}
+
+// rewrite applies update to every row of bucket and stores rows it reports as changed
+func rewrite[T any](b *bolt.Bucket, update func(*T) bool) error {
+	changed := map[string]T{}
+	if err := b.ForEach(func(k, data []byte) error {
+		var row T
+		if err := json.Unmarshal(data, &row); err != nil {
+			return err
+		}
+
+		if update(&row) {
+			changed[string(k)] = row
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// bucket can't be modified while iterating it
+	for k, row := range changed {
+		if err := put(b, []byte(k), row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,181 @@
+package mempool
+
+import (
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/transaction"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryStore keeps rows in maps, nothing survives process exit
+type memoryStore struct {
+	mu sync.RWMutex
+
+	lastTxID     uint
+	lastInputID  uint
+	lastOutputID uint
+
+	txs         map[uint]*transaction.Tx
+	inputs      map[string][]transaction.InputTx // by spending tx hash
+	outputs     map[outpoint]*transaction.OutPutTx
+	outputsByTx map[string][]*transaction.OutPutTx
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		txs:         map[uint]*transaction.Tx{},
+		inputs:      map[string][]transaction.InputTx{},
+		outputs:     map[outpoint]*transaction.OutPutTx{},
+		outputsByTx: map[string][]*transaction.OutPutTx{},
+	}
+}
+
+func (s *memoryStore) AddTx(tx *transaction.Tx, inputs []transaction.InputTx, outputs []transaction.OutPutTx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	s.lastTxID++
+	tx.ID = s.lastTxID
+	if tx.CreatedAt.IsZero() {
+		tx.CreatedAt = now
+	}
+	tx.UpdatedAt = now
+	stored := *tx
+	s.txs[tx.ID] = &stored
+
+	for _, input := range inputs {
+		s.lastInputID++
+		input.ID = s.lastInputID
+		input.CreatedAt, input.UpdatedAt = now, now
+		s.inputs[input.SpendingTxHash] = append(s.inputs[input.SpendingTxHash], input)
+	}
+
+	for _, out := range outputs {
+		key := outpoint{out.FundingTxHash, out.FundingTxPos}
+		if _, ok := s.outputs[key]; ok {
+			continue
+		}
+
+		s.lastOutputID++
+		out.ID = s.lastOutputID
+		out.CreatedAt, out.UpdatedAt = now, now
+
+		row := out
+		o := &row
+		s.outputs[key] = o
+		s.outputsByTx[out.FundingTxHash] = append(s.outputsByTx[out.FundingTxHash], o)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) DeleteTx(ID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tx, ok := s.txs[ID]; ok && !tx.DeletedAt.Valid {
+		tx.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+	}
+	return nil
+}
+
+func (s *memoryStore) SetFeeDelta(ID uint, feeDelta int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.txs[ID]
+	if !ok || tx.DeletedAt.Valid {
+		return ierrors.ErrTxNotFound
+	}
+
+	tx.FeeDelta = feeDelta
+	return nil
+}
+
+func (s *memoryStore) Txs() ([]transaction.Tx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	txs := []transaction.Tx{}
+	for _, tx := range s.txs {
+		if !tx.DeletedAt.Valid {
+			txs = append(txs, *tx)
+		}
+	}
+
+	sort.Slice(txs, func(i, j int) bool { return txs[i].ID < txs[j].ID })
+	return txs, nil
+}
+
+func (s *memoryStore) Inputs(spendingTxHash string) ([]transaction.InputTx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]transaction.InputTx{}, s.inputs[spendingTxHash]...), nil
+}
+
+func (s *memoryStore) Outputs(fundingTxHash string) ([]transaction.OutPutTx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	outputs := make([]transaction.OutPutTx, 0, len(s.outputsByTx[fundingTxHash]))
+	for _, out := range s.outputsByTx[fundingTxHash] {
+		outputs = append(outputs, *out)
+	}
+
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].FundingTxPos < outputs[j].FundingTxPos
+	})
+	return outputs, nil
+}
+
+func (s *memoryStore) Output(fundingTxHash string, index uint32) (transaction.OutPutTx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out, ok := s.outputs[outpoint{fundingTxHash, index}]
+	if !ok {
+		return transaction.OutPutTx{}, ierrors.ErrTxNotFound
+	}
+	return *out, nil
+}
+
+func (s *memoryStore) MarkSpent(fundingTxHash string, index uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, ok := s.outputs[outpoint{fundingTxHash, index}]
+	if !ok {
+		return ierrors.ErrTxNotFound
+	}
+
+	if out.Spent {
+		return ierrors.ErrAlreadySpent
+	}
+
+	out.Spent = true
+	return nil
+}
+
+func (s *memoryStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tx := range s.txs {
+		tx.DeletedAt = gorm.DeletedAt{}
+	}
+
+	for _, out := range s.outputs {
+		out.Spent = false
+	}
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,176 @@
+package mempool
+
+import (
+	"errors"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/transaction"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sqlStore keeps rows in gorm tables, soft deleted txs are mined ones
+type sqlStore struct {
+	db *gorm.DB
+}
+
+func NewSQLStore(dialector gorm.Dialector, opts ...gorm.Option) (Store, error) {
+	db, err := openDB(dialector, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlStore{db: db}, nil
+}
+
+func openDB(dialector gorm.Dialector, opts ...gorm.Option) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.AutoMigrate(transaction.Tx{}, transaction.InputTx{}, transaction.OutPutTx{}); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (s *sqlStore) DB() *gorm.DB {
+	return s.db
+}
+
+// Batch calls fn with store writing into one db transaction, every AddTx of it under its own savepoint
+func (s *sqlStore) Batch(fn func(Store) error) error {
+	return s.db.Transaction(func(db *gorm.DB) error {
+		return fn(&sqlStore{db: db})
+	})
+}
+
+func (s *sqlStore) AddTx(tx *transaction.Tx, inputs []transaction.InputTx, outputs []transaction.OutPutTx) error {
+	return s.db.Transaction(func(db *gorm.DB) error {
+		return addTx(db, tx, inputs, outputs)
+	})
+}
+
+// rows per insert statement, keeps bound variables well under sqlite's limit
+const insertBatchSize = 500
+
+// addTx writes tx rows with db in bulk, outputs already stored are skipped
+func addTx(db *gorm.DB, tx *transaction.Tx, inputs []transaction.InputTx, outputs []transaction.OutPutTx) error {
+	if err := db.Create(tx).Error; err != nil {
+		return err
+	}
+
+	if len(inputs) > 0 {
+		if err := db.CreateInBatches(&inputs, insertBatchSize).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(outputs) > 0 {
+		// prevouts are shared by every tx spending them, first one stores it
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "funding_tx_hash"}, {Name: "funding_tx_pos"}},
+			DoNothing: true,
+		}).CreateInBatches(&outputs, insertBatchSize).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStore) DeleteTx(ID uint) error {
+	return s.db.Delete(&transaction.Tx{}, ID).Error
+}
+
+func (s *sqlStore) SetFeeDelta(ID uint, feeDelta int64) error {
+	result := s.db.Model(&transaction.Tx{}).Where("id = ?", ID).Update("fee_delta", feeDelta)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ierrors.ErrTxNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) Txs() ([]transaction.Tx, error) {
+	var txs []transaction.Tx
+	if err := s.db.Order("id").Find(&txs).Error; err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (s *sqlStore) Inputs(spendingTxHash string) ([]transaction.InputTx, error) {
+	var inputs []transaction.InputTx
+	if err := s.db.Where("spending_tx_hash = ?", spendingTxHash).Order("id").Find(&inputs).Error; err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+func (s *sqlStore) Outputs(fundingTxHash string) ([]transaction.OutPutTx, error) {
+	var outputs []transaction.OutPutTx
+	if err := s.db.Where("funding_tx_hash = ?", fundingTxHash).Order("funding_tx_pos").Find(&outputs).Error; err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+func (s *sqlStore) Output(fundingTxHash string, index uint32) (transaction.OutPutTx, error) {
+	var output transaction.OutPutTx
+	if err := s.db.Where("funding_tx_hash = ? AND funding_tx_pos = ?", fundingTxHash, index).Take(&output).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return transaction.OutPutTx{}, ierrors.ErrTxNotFound
+		}
+		return transaction.OutPutTx{}, err
+	}
+	return output, nil
+}
+
+func (s *sqlStore) MarkSpent(fundingTxHash string, index uint32) error {
+	return s.db.Transaction(func(db *gorm.DB) error {
+		var output transaction.OutPutTx
+		if err := db.Where("funding_tx_hash = ? AND funding_tx_pos = ?", fundingTxHash, index).Take(&output).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ierrors.ErrTxNotFound
+			}
+			return err
+		}
+
+		if output.Spent {
+			return ierrors.ErrAlreadySpent
+		}
+
+		return db.Model(&output).Update("spent", true).Error
+	})
+}
+
+func (s *sqlStore) Reset() error {
+	return resetDB(s.db)
+}
+
+// restores soft deleted txs and marks every output unspent
+func resetDB(db *gorm.DB) error {
+	if err := db.Exec("UPDATE txes SET deleted_at = NULL;").Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec("UPDATE out_put_txes SET spent = false;").Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqlStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
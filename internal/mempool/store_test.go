@@ -0,0 +1,190 @@
+package mempool_test
+
+import (
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/mempool/mempooltest"
+	"sob-miner/pkg/transaction"
+	"time"
+
+	"gorm.io/gorm"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func output(hash string, pos uint32, value uint64) transaction.OutPutTx {
+	return transaction.OutPutTx{
+		FundingTxHash: hash,
+		FundingTxPos:  pos,
+		ScriptPubKey:  "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		ScriptType:    transaction.P2WPKH,
+		Value:         value,
+	}
+}
+
+func input(spending, funding string, index uint32) transaction.InputTx {
+	return transaction.InputTx{SpendingTxHash: spending, FundingTxHash: funding, FundingIndex: index, Sequence: 0xffffffff}
+}
+
+var _ = Describe("Store Conformance", func() {
+	for _, backend := range mempooltest.Backends {
+		backend := backend
+
+		Describe(string(backend), func() {
+			var store mempool.Store
+
+			BeforeEach(func() {
+				store = openStore(backend)
+			})
+
+			It("should assign increasing tx ids", func() {
+				a := transaction.Tx{Hash: "aa", Weight: 400, FeeCollected: 1000}
+				b := transaction.Tx{Hash: "bb", Weight: 800, FeeCollected: 3000}
+				Expect(store.AddTx(&a, nil, nil)).To(Succeed())
+				Expect(store.AddTx(&b, nil, nil)).To(Succeed())
+
+				Expect(a.ID).NotTo(BeZero())
+				Expect(b.ID).To(BeNumerically(">", a.ID))
+
+				txs, err := store.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(HaveLen(2))
+				Expect(txs[0].Hash).To(Equal("aa"))
+				Expect(txs[1].FeeCollected).To(Equal(uint64(3000)))
+			})
+
+			It("should keep given entry time", func() {
+				entryTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+				tx := transaction.Tx{Hash: "dd", Model: gorm.Model{CreatedAt: entryTime}}
+				Expect(store.AddTx(&tx, nil, nil)).To(Succeed())
+
+				txs, err := store.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs[0].CreatedAt.Equal(entryTime)).To(BeTrue())
+			})
+
+			It("should update fee delta of live txs", func() {
+				tx := transaction.Tx{Hash: "ee", FeeCollected: 1000}
+				Expect(store.AddTx(&tx, nil, nil)).To(Succeed())
+				Expect(store.SetFeeDelta(tx.ID, -400)).To(Succeed())
+
+				txs, err := store.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs[0].FeeDelta).To(Equal(int64(-400)))
+				Expect(txs[0].ModifiedFee()).To(Equal(uint64(600)))
+
+				Expect(store.SetFeeDelta(tx.ID+1, 1)).To(MatchError(ierrors.ErrTxNotFound))
+			})
+
+			It("should store inputs in order and outputs by index", func() {
+				tx := transaction.Tx{Hash: "cc"}
+				inputs := []transaction.InputTx{input("cc", "f1", 3), input("cc", "f0", 1)}
+				outputs := []transaction.OutPutTx{output("cc", 1, 20), output("cc", 0, 10), output("f1", 3, 50)}
+				Expect(store.AddTx(&tx, inputs, outputs)).To(Succeed())
+
+				stored, err := store.Inputs("cc")
+				Expect(err).To(BeNil())
+				Expect(stored).To(HaveLen(2))
+				Expect(stored[0].FundingTxHash).To(Equal("f1"))
+				Expect(stored[1].FundingIndex).To(Equal(uint32(1)))
+
+				outs, err := store.Outputs("cc")
+				Expect(err).To(BeNil())
+				Expect(outs).To(HaveLen(2))
+				Expect(outs[0].Value).To(Equal(uint64(10)))
+				Expect(outs[1].Value).To(Equal(uint64(20)))
+				Expect(outs[1].ScriptType).To(Equal(transaction.P2WPKH))
+
+				out, err := store.Output("f1", 3)
+				Expect(err).To(BeNil())
+				Expect(out.Value).To(Equal(uint64(50)))
+
+				_, err = store.Output("f1", 4)
+				Expect(err).To(MatchError(ierrors.ErrTxNotFound))
+
+				Expect(store.Inputs("unknown")).To(BeEmpty())
+				Expect(store.Outputs("unknown")).To(BeEmpty())
+			})
+
+			It("should keep first copy of a shared outpoint", func() {
+				a := transaction.Tx{Hash: "aa"}
+				b := transaction.Tx{Hash: "bb"}
+				Expect(store.AddTx(&a, nil, []transaction.OutPutTx{output("ff", 0, 10)})).To(Succeed())
+				Expect(store.MarkSpent("ff", 0)).To(Succeed())
+				Expect(store.AddTx(&b, nil, []transaction.OutPutTx{output("ff", 0, 99)})).To(Succeed())
+
+				out, err := store.Output("ff", 0)
+				Expect(err).To(BeNil())
+				Expect(out.Value).To(Equal(uint64(10)))
+				Expect(out.Spent).To(BeTrue())
+			})
+
+			It("should mark outpoints spent once", func() {
+				tx := transaction.Tx{Hash: "aa"}
+				Expect(store.AddTx(&tx, nil, []transaction.OutPutTx{output("aa", 0, 10)})).To(Succeed())
+
+				Expect(store.MarkSpent("aa", 0)).To(Succeed())
+				Expect(store.MarkSpent("aa", 0)).To(MatchError(ierrors.ErrAlreadySpent))
+				Expect(store.MarkSpent("aa", 1)).To(MatchError(ierrors.ErrTxNotFound))
+			})
+
+			It("should soft delete and restore txs on reset", func() {
+				a := transaction.Tx{Hash: "aa"}
+				b := transaction.Tx{Hash: "bb"}
+				Expect(store.AddTx(&a, nil, []transaction.OutPutTx{output("aa", 0, 10)})).To(Succeed())
+				Expect(store.AddTx(&b, nil, nil)).To(Succeed())
+
+				Expect(store.DeleteTx(a.ID)).To(Succeed())
+				Expect(store.DeleteTx(a.ID)).To(Succeed())
+				Expect(store.DeleteTx(12345)).To(Succeed())
+				Expect(store.MarkSpent("aa", 0)).To(Succeed())
+
+				txs, err := store.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(HaveLen(1))
+				Expect(txs[0].ID).To(Equal(b.ID))
+
+				// rows of deleted tx are kept
+				Expect(store.Outputs("aa")).To(HaveLen(1))
+
+				Expect(store.Reset()).To(Succeed())
+
+				txs, err = store.Txs()
+				Expect(err).To(BeNil())
+				Expect(txs).To(HaveLen(2))
+
+				out, err := store.Output("aa", 0)
+				Expect(err).To(BeNil())
+				Expect(out.Spent).To(BeFalse())
+			})
+
+			It("should back a mempool", func() {
+				pool, err := mempool.NewWithStore(store, mempool.Opts{Logger: silentLogger(), Dust: 546})
+				Expect(err).To(BeNil())
+
+				Expect(pool.PutTx(readTx(childFile))).To(Succeed())
+				Expect(pool.PutTx(readTx(parentFile))).To(Succeed())
+
+				parent, err := pool.PickBestTx()
+				Expect(err).To(BeNil())
+				Expect(pool.ValidateWholeTx(parent, must(pool.GetInputs(parent.Hash)))).To(Succeed())
+				Expect(pool.DeleteTx(parent.ID)).To(Succeed())
+
+				// a mempool on same store sees same state
+				reopened, err := mempool.NewWithStore(store, mempool.Opts{Logger: silentLogger(), Dust: 546})
+				Expect(err).To(BeNil())
+
+				child, err := reopened.PickBestTx()
+				Expect(err).To(BeNil())
+				Expect(child.ID).NotTo(Equal(parent.ID))
+				Expect(reopened.ValidateWholeTx(child, must(reopened.GetInputs(child.Hash)))).To(Succeed())
+			})
+		})
+	}
+})
+
+func must[T any](v T, err error) T {
+	Expect(err).To(BeNil())
+	return v
+}
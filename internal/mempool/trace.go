@@ -0,0 +1,147 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// TraceStage is a validation stage recorded by a Trace
+type TraceStage string
+
+const (
+	TraceParse  TraceStage = "parse"
+	TraceHash   TraceStage = "hash"
+	TracePolicy TraceStage = "policy"
+	TraceScript TraceStage = "script"
+	TraceFee    TraceStage = "fee"
+
+	// recorded by callers admitting traced tx, e.g. ingest
+	TraceAdmit TraceStage = "admit"
+)
+
+type TraceStep struct {
+	Stage   TraceStage    `json:"stage"`
+	Input   *int          `json:"input,omitempty"`
+	Message string        `json:"message"`
+	Stack   []string      `json:"stack,omitempty"` // hex items, bottom first
+	Err     string        `json:"error,omitempty"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+// Trace is what validation of a tx went through, see TraceTx. methods are no-ops on
+// nil Trace so validation records steps the same way whether it is traced or not
+type Trace struct {
+	Txid  string      `json:"txid,omitempty"`
+	Steps []TraceStep `json:"steps"`
+	// first failure, admit step overrides it as tx failing alone may be admitted in a package
+	Err string `json:"error,omitempty"`
+
+	last time.Time
+}
+
+func newTrace() *Trace {
+	return &Trace{Steps: []TraceStep{}, last: time.Now()}
+}
+
+// Add records a step of stage, elapsed is time since previous step
+func (t *Trace) Add(stage TraceStage, message string, err error) {
+	t.add(TraceStep{Stage: stage, Message: message}, err)
+}
+
+func (t *Trace) addInput(stage TraceStage, input int, message string, stack [][]byte, err error) {
+	step := TraceStep{Stage: stage, Input: &input, Message: message}
+	for _, item := range stack {
+		step.Stack = append(step.Stack, hex.EncodeToString(item))
+	}
+	t.add(step, err)
+}
+
+func (t *Trace) add(step TraceStep, err error) {
+	if t == nil {
+		return
+	}
+
+	now := time.Now()
+	step.Elapsed = now.Sub(t.last)
+	t.last = now
+
+	if err != nil {
+		step.Err = err.Error()
+		if t.Err == "" {
+			t.Err = step.Err
+		}
+	}
+	if step.Stage == TraceAdmit {
+		t.Err = step.Err
+	}
+	t.Steps = append(t.Steps, step)
+}
+
+func (t *Trace) Failed() bool {
+	return t != nil && t.Err != ""
+}
+
+func (t *Trace) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// WriteText writes a step per line with stack items under it
+func (t *Trace) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "trace of %s\n", t.Txid)
+	for _, step := range t.Steps {
+		status := "ok"
+		if step.Err != "" {
+			status = "FAIL"
+		}
+
+		msg := step.Message
+		if step.Input != nil {
+			msg = fmt.Sprintf("input %d: %s", *step.Input, msg)
+		}
+		if step.Err != "" {
+			msg += ": " + step.Err
+		}
+		fmt.Fprintf(&b, "  %-7s %-4s %s [%v]\n", step.Stage, status, msg, step.Elapsed)
+
+		for i, item := range step.Stack {
+			fmt.Fprintf(&b, "               stack[%d] %s\n", i, item)
+		}
+	}
+
+	if t.Err == "" {
+		b.WriteString("passed\n")
+	} else {
+		fmt.Fprintf(&b, "failed: %s\n", t.Err)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// TraceTx parses and validates json tx like ingestion does, scripts included, recording every
+// stage. nothing is admitted, trace stops at first failing stage
+func (s *settings) TraceTx(data []byte) *Trace {
+	t := newTrace()
+
+	var tx Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		t.Add(TraceParse, "json", err)
+		return t
+	}
+	t.Add(TraceParse, fmt.Sprintf("%d inputs %d outputs", len(tx.Vin), len(tx.Vout)), nil)
+
+	if _, err := s.prepare(tx, t); err != nil {
+		return t
+	}
+
+	tx.validateTxScripts(t)
+	return t
+}
@@ -0,0 +1,125 @@
+package mempool_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/path"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trace", func() {
+	var pool mempool.Mempool
+
+	BeforeEach(func() {
+		var err error
+		pool, err = mempool.New(nil, mempool.Opts{Logger: silentLogger(), Dust: 546, Backend: mempool.BackendMemory})
+		Expect(err).To(BeNil())
+	})
+
+	readFile := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join(path.MempoolDataPath, name))
+		Expect(err).To(BeNil())
+		return data
+	}
+
+	stages := func(t *mempool.Trace) []mempool.TraceStage {
+		stages := []mempool.TraceStage{}
+		for _, step := range t.Steps {
+			stages = append(stages, step.Stage)
+		}
+		return stages
+	}
+
+	It("should record every stage of a valid tx", func() {
+		t := pool.TraceTx(readFile(p2pkhFile))
+		Expect(t.Failed()).To(BeFalse())
+		Expect(t.Txid).To(Equal(strings.TrimSuffix(p2pkhFile, ".json")))
+		Expect(stages(t)).To(Equal([]mempool.TraceStage{
+			mempool.TraceParse, mempool.TraceHash, mempool.TracePolicy,
+			mempool.TraceParse, mempool.TraceFee, mempool.TraceFee,
+			mempool.TraceScript, mempool.TraceScript, mempool.TraceScript,
+		}))
+
+		// signature and pubkey before and after sighash, true left by OP_CHECKSIG
+		spend, sighash, checkSig := t.Steps[len(t.Steps)-3], t.Steps[len(t.Steps)-2], t.Steps[len(t.Steps)-1]
+		Expect(*spend.Input).To(Equal(0))
+		Expect(spend.Stack).To(HaveLen(2))
+		Expect(sighash.Message).To(HavePrefix("legacy sighash 0x01 digest "))
+		Expect(sighash.Stack).To(Equal(spend.Stack))
+		Expect(checkSig.Message).To(Equal("OP_CHECKSIG"))
+		Expect(checkSig.Stack).To(Equal([]string{"01"}))
+
+		// nothing is admitted
+		Expect(pool.Txs()).To(BeEmpty())
+
+		var text, js bytes.Buffer
+		Expect(t.WriteText(&text)).To(Succeed())
+		Expect(text.String()).To(ContainSubstring("input 0: p2pkh spend"))
+		Expect(text.String()).To(HaveSuffix("passed\n"))
+
+		Expect(t.WriteJSON(&js)).To(Succeed())
+		var decoded mempool.Trace
+		Expect(json.Unmarshal(js.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded.Steps).To(HaveLen(len(t.Steps)))
+	})
+
+	It("should stop at fee stage of a low fee tx", func() {
+		t := pool.TraceTx(readFile(lowFeeParentFile))
+		Expect(t.Failed()).To(BeTrue())
+
+		last := t.Steps[len(t.Steps)-1]
+		Expect(last.Stage).To(Equal(mempool.TraceFee))
+		Expect(last.Err).To(ContainSubstring("pays 237 sats"))
+	})
+
+	It("should show stack of input failing its script", func() {
+		tx := readTx(p2pkhFile)
+		// output value is signed
+		tx.Vout[0].Value--
+		data, err := json.Marshal(tx)
+		Expect(err).To(BeNil())
+
+		t := pool.TraceTx(data)
+		Expect(t.Failed()).To(BeTrue())
+
+		// OP_CHECKSIG leaves false
+		last := t.Steps[len(t.Steps)-1]
+		Expect(last.Stage).To(Equal(mempool.TraceScript))
+		Expect(*last.Input).To(Equal(0))
+		Expect(last.Message).To(Equal("OP_CHECKSIG"))
+		Expect(last.Stack).To(Equal([]string{""}))
+		Expect(last.Err).NotTo(BeEmpty())
+	})
+
+	It("should record script hash compare of a p2sh spend", func() {
+		t := pool.TraceTx(readFile(p2shP2wshFile))
+		Expect(t.Failed()).To(BeFalse())
+
+		last := t.Steps[len(t.Steps)-1]
+		Expect(last.Message).To(HavePrefix("OP_HASH160 of redeem script "))
+		Expect(last.Stack).To(Equal([]string{"01"}))
+
+		tx := readTx(p2shP2wshFile)
+		tx.Vin[0].InnerRedeemScriptAsm = "OP_0 OP_PUSHBYTES_1 00"
+		data, err := json.Marshal(tx)
+		Expect(err).To(BeNil())
+
+		t = pool.TraceTx(data)
+		Expect(t.Failed()).To(BeTrue())
+		last = t.Steps[len(t.Steps)-1]
+		Expect(last.Stack).To(Equal([]string{""}))
+		Expect(last.Err).To(ContainSubstring("redeem script"))
+	})
+
+	It("should record unparsable json", func() {
+		t := pool.TraceTx([]byte("{"))
+		Expect(t.Failed()).To(BeTrue())
+		Expect(stages(t)).To(Equal([]mempool.TraceStage{mempool.TraceParse}))
+	})
+})
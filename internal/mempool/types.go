@@ -72,6 +72,15 @@ func (t *Transaction) Hash() (string, string, int, error) {
 	return doubleHash(serializedTx), doubleHash(serializedWitnessTx), weight, nil
 }
 
+// Txid is tx hash in rpc order, like TxIn.Txid and file names of mempool dir
+func (t *Transaction) Txid() (string, error) {
+	hash, _, _, err := t.Hash()
+	if err != nil {
+		return "", err
+	}
+	return HashToTxid(hash), nil
+}
+
 func doubleHash(item []byte) string {
 	h := sha256.New()
 	h.Write(item)
@@ -230,22 +239,13 @@ func (t *Transaction) Serialize() ([]byte, []byte, int, error) {
 	return serializedTx.GetBuffer(), serializedWitnessTx.GetBuffer(), weight, nil
 }
 
-func (t *Transaction) MustSerializeWithSigHashAll() []byte {
-	serializedTx, _, _, err := t.Serialize()
-	if err != nil {
-		panic(err)
-	}
-	serializedTx = append(serializedTx, []byte{0x01, 0x00, 0x00, 0x00}...)
-	return serializedTx
-}
-
-func (t *Transaction) MustSerializeWithSigHashAnyOneCanPaySigHashAll() []byte {
+// serializeWithSigHash is legacy sighash preimage of tx, sigHash is appended as u32
+func (t *Transaction) serializeWithSigHash(sigHash byte) ([]byte, error) {
 	serializedTx, _, _, err := t.Serialize()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	serializedTx = append(serializedTx, []byte{0x81, 0x00, 0x00, 0x00}...)
-	return serializedTx
+	return append(serializedTx, sigHash, 0x00, 0x00, 0x00), nil
 }
 
 var strict_check_tag = "strict_check"
@@ -1,12 +1,14 @@
 package mempool
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"sob-miner/internal/ierrors"
 	"sob-miner/pkg/encoding"
 	"sob-miner/pkg/opcode"
+	"sob-miner/pkg/script"
 	"sob-miner/pkg/transaction"
 	"strings"
 
@@ -17,78 +19,183 @@ import (
 )
 
 func (t *Transaction) ValidateTxScripts() error {
+	return t.validateTxScripts(nil)
+}
+
+// validateTxScripts is ValidateTxScripts recording every input with its stack after each check to tr
+func (t *Transaction) validateTxScripts(tr *Trace) error {
 	// iter through inputs and validate each one of em based on their type
 	for i, input := range t.Vin {
-		var err error = nil
-		switch transaction.Type(input.Prevout.ScriptPubKeyType) {
-		case transaction.OP_RETURN_TYPE:
-			err = ierrors.ErrUsingOpReturnAsInput
-		case transaction.P2PK:
-			err = nil // ignore for now no p2pk txs in assignment
-		case transaction.P2PKH:
-
-			tempTx := *t
-
-			stackElem := strings.Split(input.ScriptSigAsm, " ")
-
-			// compressed pubkey
-			pubKey := stackElem[len(stackElem)-1]
-			Signature := stackElem[1]
+		var err error
+		if tr == nil {
+			err = t.validateInput(nil, i, input)
+		} else {
+			err = t.traceInput(tr, i, input)
+		}
+		if err != nil {
+			return ierrors.RejectInput("", i, err, fmt.Sprintf("spending %s:%d", input.Txid, input.Vout))
+		}
+	}
+	return nil
+}
 
-			signatureBytes := MustHexDecode(Signature)
-			lastSigByte := signatureBytes[len(signatureBytes)-1]
+// traceInput records input to tr with stack it starts from, followed by checks validateInput records
+func (t *Transaction) traceInput(tr *Trace, i int, input TxIn) error {
+	tr.addInput(TraceScript, i, fmt.Sprintf("%s spend of %s:%d", input.Prevout.ScriptPubKeyType, input.Txid, input.Vout), initialStack(input), nil)
 
-			MessageHash := generateMessageHashLegacy(tempTx, i, input, lastSigByte)
+	err := t.validateInput(tr, i, input)
+	// malformed fields fail input before any check records a step
+	if err != nil && !tr.Failed() {
+		tr.addInput(TraceScript, i, "script checks", nil, err)
+	}
+	return err
+}
 
-			err = ECVerify(MessageHash, signatureBytes, MustHexDecode(pubKey))
+// initialStack is what input puts on stack before its prevout script runs, witness items for segwit spends
+func initialStack(input TxIn) [][]byte {
+	stack := [][]byte{}
+	if len(input.Witness) > 0 {
+		for _, item := range input.Witness {
+			b, _ := hex.DecodeString(item)
+			stack = append(stack, b)
+		}
+		return stack
+	}
 
-		case transaction.P2SH:
-			redeemScript := MustDecodeAsmScript(strings.Split(input.InnerRedeemScriptAsm, " "))
-			redeemScripExpectedtHash := strings.Split(input.Prevout.ScriptPubKeyAsm, " ")[2]
+	scriptSig, err := hex.DecodeString(input.ScriptSig)
+	if err != nil {
+		return stack
+	}
+	pushes, _ := script.Pushes(scriptSig)
+	return append(stack, pushes...)
+}
 
-			if hex.EncodeToString(H160(redeemScript)) != redeemScripExpectedtHash {
-				err = ierrors.ErrRedeemScriptMismatch
-			}
+// validateInput checks input against its prevout, malformed fields of tx are errors.
+// sighash, script hash and signature checks are recorded to tr with stack they leave
+func (t *Transaction) validateInput(tr *Trace, i int, input TxIn) error {
+	scriptPubKey, err := decodeHex(input.Prevout.ScriptPubKey, "prevout scriptpubkey")
+	if err != nil {
+		return err
+	}
 
-		case transaction.P2MS:
-			err = nil
+	switch script.Classify(scriptPubKey).Type {
+	case transaction.OP_RETURN_TYPE:
+		return ierrors.ErrUsingOpReturnAsInput
+	case transaction.P2PK:
+		return nil // ignore for now no p2pk txs in assignment
+	case transaction.P2PKH:
+		stackElem := strings.Split(input.ScriptSigAsm, " ")
+		if len(stackElem) < 2 {
+			return fmt.Errorf("%w: scriptsig doesn't push a signature and a pubkey", ierrors.ErrInvalidScript)
+		}
 
-		case transaction.P2WSH:
-			redeemScript := MustDecodeAsmScript(strings.Split(input.InnerWitnessScriptAsm, " "))
-			redeemScripExpectedtHash := strings.Split(input.Prevout.ScriptPubKeyAsm, " ")[2]
+		// compressed pubkey
+		pubKey, err := decodeHex(stackElem[len(stackElem)-1], "pubkey")
+		if err != nil {
+			return err
+		}
+		signature, err := decodeHex(stackElem[1], "signature")
+		if err != nil {
+			return err
+		}
+		if len(signature) == 0 {
+			return ierrors.ErrInvalidSignature
+		}
 
-			if hex.EncodeToString(Sha256(redeemScript)) != redeemScripExpectedtHash {
-				err = ierrors.ErrRedeemScriptMismatch
-			}
+		messageHash, err := generateMessageHashLegacy(*t, i, input, signature[len(signature)-1])
+		if err != nil {
+			return err
+		}
+		tr.addInput(TraceScript, i, fmt.Sprintf("legacy sighash %#02x digest %x", signature[len(signature)-1], messageHash), [][]byte{signature, pubKey}, nil)
+		return checkSig(tr, i, messageHash, signature, pubKey)
 
-		case transaction.P2WPKH:
-			tempTx := *t
+	case transaction.P2SH:
+		redeemScript, err := opcode.Assemble(input.InnerRedeemScriptAsm)
+		if err != nil {
+			return fmt.Errorf("inner redeem script: %w", err)
+		}
 
-			if (len(input.Witness)) != 2 {
-				err = ierrors.ErrInvalidWitnessLength
-				break
-			}
+		// OP_HASH160 OP_PUSHBYTES_20 <hash> OP_EQUAL
+		hash := H160(redeemScript)
+		return checkScriptHash(tr, i, fmt.Sprintf("OP_HASH160 of redeem script %x OP_EQUAL %x", hash, scriptPubKey[2:22]), bytes.Equal(hash, scriptPubKey[2:22]))
 
-			Signature := MustHexDecode(input.Witness[0])
-			pubKeyHash := MustHexDecode(input.Witness[1])
+	case transaction.P2MS, transaction.NonStandard:
+		return nil
 
-			lastSigByte := Signature[len(Signature)-1]
+	case transaction.P2WSH:
+		witnessScript, err := opcode.Assemble(input.InnerWitnessScriptAsm)
+		if err != nil {
+			return fmt.Errorf("inner witness script: %w", err)
+		}
 
-			MessageHash := generateMessageHashSegwit(tempTx, i, input, lastSigByte)
+		// OP_0 OP_PUSHBYTES_32 <hash>
+		hash := Sha256(witnessScript)
+		return checkScriptHash(tr, i, fmt.Sprintf("SHA256 of witness script %x equals %x", hash, scriptPubKey[2:34]), bytes.Equal(hash, scriptPubKey[2:34]))
 
-			err = ECVerify(MessageHash, Signature, pubKeyHash)
+	case transaction.P2WPKH:
+		if (len(input.Witness)) != 2 {
+			return ierrors.ErrInvalidWitnessLength
+		}
 
-		case transaction.P2TR:
-			err = nil
-		default:
-			err = ierrors.ErrScriptValidation
+		signature, err := decodeHex(input.Witness[0], "signature")
+		if err != nil {
+			return err
 		}
+		pubKey, err := decodeHex(input.Witness[1], "pubkey")
 		if err != nil {
-			fmt.Printf("\n encountered an error: %s for inputTxid: %s and vout number: %d", err, input.Txid, input.Vout)
 			return err
 		}
+		if len(signature) == 0 {
+			return ierrors.ErrInvalidSignature
+		}
+
+		messageHash, err := generateMessageHashSegwit(*t, i, input, signature[len(signature)-1])
+		if err != nil {
+			return err
+		}
+		tr.addInput(TraceScript, i, fmt.Sprintf("segwit sighash %#02x digest %x", signature[len(signature)-1], messageHash), [][]byte{signature, pubKey}, nil)
+		return checkSig(tr, i, messageHash, signature, pubKey)
+
+	case transaction.P2TR:
+		return nil
+	case transaction.WitnessUnknown:
+		return nil // consensus: future witness versions are anyone-can-spend
 	}
-	return nil
+	return ierrors.ErrScriptValidation
+}
+
+// checkSig is OP_CHECKSIG of input i, recorded to tr with result it leaves on stack
+func checkSig(tr *Trace, i int, digest []byte, signature []byte, pubKey []byte) error {
+	err := ECVerify(digest, signature, pubKey)
+	tr.addInput(TraceScript, i, "OP_CHECKSIG", [][]byte{stackBool(err == nil)}, err)
+	return err
+}
+
+// checkScriptHash records comparison of script hash of input i with its prevout to tr
+func checkScriptHash(tr *Trace, i int, message string, equal bool) error {
+	var err error
+	if !equal {
+		err = ierrors.ErrRedeemScriptMismatch
+	}
+	tr.addInput(TraceScript, i, message, [][]byte{stackBool(equal)}, err)
+	return err
+}
+
+// stackBool is item a check leaves on stack, false is an empty item
+func stackBool(ok bool) []byte {
+	if ok {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+// decodeHex decodes hex field of a tx, tx with a field which isn't hex is invalid
+func decodeHex(s string, field string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ierrors.ErrInvalidTx, field, err)
+	}
+	return b, nil
 }
 
 // verifies ecdsa signature from der encoding
@@ -113,33 +220,6 @@ func ECVerify(digest []byte, sig []byte, pubkey []byte) error {
 	return nil
 }
 
-func MustHexDecode(s string) []byte {
-	b, err := hex.DecodeString(s)
-	if err != nil {
-		panic(err)
-	}
-	return b
-}
-
-func MustDecodeAsmScript(asmScript []string) []byte {
-	decoded_script := []byte{}
-	for _, item := range asmScript {
-		if len(item) > 3 && item[:3] == "OP_" {
-			byteCode, ok := opcode.OpCodeMap[item]
-			if !ok {
-				panic("invalid opcode: " + item)
-			}
-			decoded_script = append(decoded_script, byteCode)
-			continue
-		}
-
-		byteItem, _ := hex.DecodeString(item)
-
-		decoded_script = append(decoded_script, byteItem...)
-	}
-	return decoded_script
-}
-
 func H160(b []byte) []byte {
 	h := sha256.New()
 	h.Write(b)
@@ -157,54 +237,53 @@ func Sha256(b []byte) []byte {
 	return h.Sum(nil)
 }
 
-func generateMessageHashLegacy(tempTx Transaction, i int, input TxIn, sigHash byte) []byte {
+func generateMessageHashLegacy(tempTx Transaction, i int, input TxIn, sigHash byte) ([]byte, error) {
+	// scriptSigs are replaced on a copy, tx itself is left as it is
+	tempTx.Vin = append([]TxIn{}, tempTx.Vin...)
+
 	var serializedTx []byte
+	var err error
 
 	switch sigHash {
-	case 0x01: // sighashAll
-		for j := 0; j < len(tempTx.Vin); j++ {
-			tempTx.Vin[j].ScriptSig = ""
-		}
-		tempTx.Vin[i].ScriptSig = input.Prevout.ScriptPubKey
-		serializedTx = tempTx.MustSerializeWithSigHashAll()
-	case 0x81:
+	case 0x81: // sighashAll | anyoneCanPay
 		tempTx.Vin = []TxIn{input}
 		tempTx.Vin[0].ScriptSig = input.Prevout.ScriptPubKey
-		serializedTx = tempTx.MustSerializeWithSigHashAnyOneCanPaySigHashAll()
-	default:
+		serializedTx, err = tempTx.serializeWithSigHash(0x81)
+	default: // sighashAll
 		for j := 0; j < len(tempTx.Vin); j++ {
 			tempTx.Vin[j].ScriptSig = ""
 		}
 		tempTx.Vin[i].ScriptSig = input.Prevout.ScriptPubKey
-		serializedTx = tempTx.MustSerializeWithSigHashAll()
+		serializedTx, err = tempTx.serializeWithSigHash(0x01)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return chainhash.DoubleHashB(serializedTx)
+	return chainhash.DoubleHashB(serializedTx), nil
 }
 
-func generateMessageHashSegwit(tempTx Transaction, pos int, input TxIn, sigHash byte) []byte {
+func generateMessageHashSegwit(tempTx Transaction, pos int, input TxIn, sigHash byte) ([]byte, error) {
 	var serializedTx []byte
+	var err error
+
 	switch sigHash {
-	case 0x01:
-		serializedTx = SegwitSerializeAll(tempTx, input, []byte{0x01, 0x00, 0x00, 0x00})
 	case 0x81:
-		// tempTx.Vin = []TxIn{input}
-		// tempTx.Vin[0].ScriptSig = input.Prevout.ScriptPubKey
-		serializedTx = SegwitSerializeAllAnyOne(tempTx, input, []byte{0x81, 0x00, 0x00, 0x00})
+		serializedTx, err = SegwitSerializeAllAnyOne(tempTx, input, []byte{0x81, 0x00, 0x00, 0x00})
 	case 0x83:
-		serializedTx = SegwitSerializeSingleAnyOne(tempTx, pos, input, []byte{0x83, 0x00, 0x00, 0x00})
+		serializedTx, err = SegwitSerializeSingleAnyOne(tempTx, pos, input, []byte{0x83, 0x00, 0x00, 0x00})
 	default:
-		serializedTx = SegwitSerializeAll(tempTx, input, []byte{0x01, 0x00, 0x00, 0x00})
+		serializedTx, err = SegwitSerializeAll(tempTx, input, []byte{0x01, 0x00, 0x00, 0x00})
 	}
-	if tempTx.Vin[0].Txid == "f3898029a8699bd8b71dc6f20e7ec2762a945a30d6a9f18034ce92a9d6cdd26c" {
-		fmt.Println("serializedTx", serializedTx)
+	if err != nil {
+		return nil, err
 	}
-	return chainhash.DoubleHashB(serializedTx)
+	return chainhash.DoubleHashB(serializedTx), nil
 }
 
 // returns pre image
 // preimage = version ✅ + hash256(inputs) ✅ + hash256(sequences) ✅ + input ✅ + scriptcode ✅ + amount ✅ + sequence ✅ + hash256(outputs) + locktime ✅ + SIGHASH ✅
-func SegwitSerializeAll(tempTx Transaction, inp TxIn, sigHash []byte) []byte {
+func SegwitSerializeAll(tempTx Transaction, inp TxIn, sigHash []byte) ([]byte, error) {
 	preImage := encoding.NewLEBuffer()
 
 	preImage.Set(tempTx.Version)
@@ -213,7 +292,11 @@ func SegwitSerializeAll(tempTx Transaction, inp TxIn, sigHash []byte) []byte {
 	SequencesBytes := encoding.NewLEBuffer()
 
 	for _, input := range tempTx.Vin {
-		InputsBytes.SetBytes(MustHexDecode(input.Txid), true)
+		txid, err := decodeHex(input.Txid, "txid")
+		if err != nil {
+			return nil, err
+		}
+		InputsBytes.SetBytes(txid, true)
 		InputsBytes.Set(input.Vout)
 
 		SequencesBytes.Set(input.Sequence)
@@ -222,70 +305,43 @@ func SegwitSerializeAll(tempTx Transaction, inp TxIn, sigHash []byte) []byte {
 	preImage.SetBytes(chainhash.DoubleHashB(InputsBytes.GetBuffer()), false)
 	preImage.SetBytes(chainhash.DoubleHashB(SequencesBytes.GetBuffer()), false)
 
-	preImage.SetBytes(MustHexDecode(inp.Txid), true)
-	preImage.Set(inp.Vout)
-
-	pubKeyHash := MustHexDecode(inp.Prevout.ScriptPubKey[4:])
-
-	scriptCode := make([]byte, 0)
-	scriptCode = append(scriptCode, []byte{
-		0x19, 0x76, 0xa9, 0x14,
-	}...)
-	scriptCode = append(scriptCode, pubKeyHash...)
-	scriptCode = append(scriptCode, 0x88, 0xac)
-
-	preImage.SetBytes(scriptCode, false)
-	preImage.Set(inp.Prevout.Value)
-	preImage.Set(inp.Sequence)
+	if err := setInputScriptCode(preImage, inp); err != nil {
+		return nil, err
+	}
 
 	outputBytes := encoding.NewLEBuffer()
 
 	for _, output := range tempTx.Vout {
-		outputBytes.Set(output.Value)
-		scriptPubKey := MustHexDecode(output.ScriptPubKey)
-		outputBytes.Set(encoding.CompactSize(uint64(len(scriptPubKey))))
-		outputBytes.SetBytes(scriptPubKey, false)
+		if err := setOutput(outputBytes, output); err != nil {
+			return nil, err
+		}
 	}
 
 	preImage.SetBytes(chainhash.DoubleHashB(outputBytes.GetBuffer()), false)
 	preImage.Set(tempTx.Locktime)
 
 	preImage.SetBytes(sigHash, false) // sighash
-	return preImage.GetBuffer()
+	return preImage.GetBuffer(), nil
 }
 
-func SegwitSerializeAllAnyOne(tempTx Transaction, inp TxIn, sigHash []byte) []byte {
+func SegwitSerializeAllAnyOne(tempTx Transaction, inp TxIn, sigHash []byte) ([]byte, error) {
 	preImage := encoding.NewLEBuffer()
 
 	preImage.Set(tempTx.Version)
 
-	preImage.SetBytes(MustHexDecode("0000000000000000000000000000000000000000000000000000000000000000"), false) // hashPrevouts
-	preImage.SetBytes(MustHexDecode("0000000000000000000000000000000000000000000000000000000000000000"), false) // hashSequence
-
-	preImage.SetBytes(MustHexDecode(inp.Txid), true)
-	preImage.Set(inp.Vout)
-
-	pubKeyHash := MustHexDecode(inp.Prevout.ScriptPubKey[4:])
+	preImage.SetBytes(make([]byte, 32), false) // hashPrevouts
+	preImage.SetBytes(make([]byte, 32), false) // hashSequence
 
-	scriptCode := make([]byte, 0)
-	scriptCode = append(scriptCode, []byte{
-		0x19, 0x76, 0xa9, 0x14,
-	}...)
-
-	scriptCode = append(scriptCode, pubKeyHash...)
-	scriptCode = append(scriptCode, 0x88, 0xac)
-
-	preImage.SetBytes(scriptCode, false)
-	preImage.Set(inp.Prevout.Value)
-	preImage.Set(inp.Sequence)
+	if err := setInputScriptCode(preImage, inp); err != nil {
+		return nil, err
+	}
 
 	outputBytes := encoding.NewLEBuffer()
 
 	for _, output := range tempTx.Vout {
-		outputBytes.Set(output.Value)
-		scriptPubKey := MustHexDecode(output.ScriptPubKey)
-		outputBytes.Set(encoding.CompactSize(uint64(len(scriptPubKey))))
-		outputBytes.SetBytes(scriptPubKey, false)
+		if err := setOutput(outputBytes, output); err != nil {
+			return nil, err
+		}
 	}
 
 	preImage.SetBytes(chainhash.DoubleHashB(outputBytes.GetBuffer()), false)
@@ -293,49 +349,80 @@ func SegwitSerializeAllAnyOne(tempTx Transaction, inp TxIn, sigHash []byte) []by
 
 	preImage.SetBytes(sigHash, false) // sighash
 
-	return preImage.GetBuffer()
+	return preImage.GetBuffer(), nil
 }
 
-func SegwitSerializeSingleAnyOne(tempTx Transaction, pos int, inp TxIn, sigHash []byte) []byte {
+func SegwitSerializeSingleAnyOne(tempTx Transaction, pos int, inp TxIn, sigHash []byte) ([]byte, error) {
 	preImage := encoding.NewLEBuffer()
 
 	preImage.Set(tempTx.Version)
 
-	preImage.SetBytes(MustHexDecode("0000000000000000000000000000000000000000000000000000000000000000"), false) // hashPrevouts
-	preImage.SetBytes(MustHexDecode("0000000000000000000000000000000000000000000000000000000000000000"), false) // hashSequence
+	preImage.SetBytes(make([]byte, 32), false) // hashPrevouts
+	preImage.SetBytes(make([]byte, 32), false) // hashSequence
+
+	if err := setInputScriptCode(preImage, inp); err != nil {
+		return nil, err
+	}
+
+	// bip143: hashOutputs is zero when there is no output at position of input
+	if pos >= len(tempTx.Vout) {
+		preImage.SetBytes(make([]byte, 32), false)
+	} else {
+		outputBytes := encoding.NewLEBuffer()
+		if err := setOutput(outputBytes, tempTx.Vout[pos]); err != nil {
+			return nil, err
+		}
+		preImage.SetBytes(chainhash.DoubleHashB(outputBytes.GetBuffer()), false)
+	}
+	preImage.Set(tempTx.Locktime)
+
+	preImage.SetBytes(sigHash, false) // sighash
+
+	return preImage.GetBuffer(), nil
+}
 
-	preImage.SetBytes(MustHexDecode(inp.Txid), true)
+// setInputScriptCode sets outpoint of p2wpkh input, its p2pkh script code, amount and sequence
+func setInputScriptCode(preImage *encoding.LittleEndianBuffer, inp TxIn) error {
+	txid, err := decodeHex(inp.Txid, "txid")
+	if err != nil {
+		return err
+	}
+	preImage.SetBytes(txid, true)
 	preImage.Set(inp.Vout)
 
-	pubKeyHash := MustHexDecode(inp.Prevout.ScriptPubKey[4:])
+	// OP_0 OP_PUSHBYTES_20 <pubkey hash>
+	scriptPubKey, err := decodeHex(inp.Prevout.ScriptPubKey, "prevout scriptpubkey")
+	if err != nil {
+		return err
+	}
+	if len(scriptPubKey) != 22 {
+		return fmt.Errorf("%w: prevout isn't p2wpkh", ierrors.ErrScriptTypeMismatch)
+	}
+	pubKeyHash := scriptPubKey[2:]
 
 	scriptCode := make([]byte, 0)
 	scriptCode = append(scriptCode, []byte{
 		0x19, 0x76, 0xa9, 0x14,
 	}...)
-
 	scriptCode = append(scriptCode, pubKeyHash...)
 	scriptCode = append(scriptCode, 0x88, 0xac)
 
 	preImage.SetBytes(scriptCode, false)
 	preImage.Set(inp.Prevout.Value)
 	preImage.Set(inp.Sequence)
+	return nil
+}
 
-	outputBytes := encoding.NewLEBuffer()
-
-	output := tempTx.Vout[pos]
+func setOutput(outputBytes *encoding.LittleEndianBuffer, output TxOut) error {
+	scriptPubKey, err := decodeHex(output.ScriptPubKey, "scriptpubkey")
+	if err != nil {
+		return err
+	}
 
 	outputBytes.Set(output.Value)
-	scriptPubKey := MustHexDecode(output.ScriptPubKey)
 	outputBytes.Set(encoding.CompactSize(uint64(len(scriptPubKey))))
 	outputBytes.SetBytes(scriptPubKey, false)
-
-	preImage.SetBytes(chainhash.DoubleHashB(outputBytes.GetBuffer()), false)
-	preImage.Set(tempTx.Locktime)
-
-	preImage.SetBytes(sigHash, false) // sighash
-
-	return preImage.GetBuffer()
+	return nil
 }
 
 // 2102b2fb48ce4536bc0218d0d72d84d791f07649b0650cecb46d9b1ee94afc1785d4ac7364000068
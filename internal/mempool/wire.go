@@ -0,0 +1,275 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/address"
+	"sob-miner/pkg/chaincfg"
+	en "sob-miner/pkg/encoding"
+	"sob-miner/pkg/opcode"
+	"sob-miner/pkg/script"
+	"sob-miner/pkg/transaction"
+)
+
+// counts and lengths above it can't fit in a block, guards allocations against corrupt input
+const maxWireSize = 4_000_000
+
+// DecodeTx decodes a tx in wire format, see ReadTx
+func DecodeTx(raw []byte) (Transaction, error) {
+	r := bytes.NewReader(raw)
+
+	tx, err := ReadTx(r)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	if r.Len() != 0 {
+		return Transaction{}, ierrors.ErrTrailingBytes
+	}
+	return tx, nil
+}
+
+// ReadTx reads a tx in wire format [BIP144 serialization when it has witnesses] from r.
+// only fields carried on the wire are set, prevouts and everything derived
+// from scripts are left empty, see Describe
+func ReadTx(r io.Reader) (Transaction, error) {
+	var tx Transaction
+
+	if err := binary.Read(r, binary.LittleEndian, &tx.Version); err != nil {
+		return Transaction{}, err
+	}
+
+	inputCount, err := readCount(r)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	// zero inputs is marker of witness serialization, flag must follow
+	segwit := false
+	if inputCount == 0 {
+		var flag [1]byte
+		if _, err := io.ReadFull(r, flag[:]); err != nil {
+			return Transaction{}, err
+		}
+		if flag[0] != 1 {
+			return Transaction{}, ierrors.ErrInvalidTx
+		}
+
+		segwit = true
+		if inputCount, err = readCount(r); err != nil {
+			return Transaction{}, err
+		}
+	}
+
+	for i := uint64(0); i < inputCount; i++ {
+		var in TxIn
+
+		txid := make([]byte, 32)
+		if _, err := io.ReadFull(r, txid); err != nil {
+			return Transaction{}, err
+		}
+		in.Txid = hex.EncodeToString(reverse(txid))
+
+		if err := binary.Read(r, binary.LittleEndian, &in.Vout); err != nil {
+			return Transaction{}, err
+		}
+
+		scriptSig, err := readBytes(r)
+		if err != nil {
+			return Transaction{}, err
+		}
+		in.ScriptSig = hex.EncodeToString(scriptSig)
+
+		if err := binary.Read(r, binary.LittleEndian, &in.Sequence); err != nil {
+			return Transaction{}, err
+		}
+
+		tx.Vin = append(tx.Vin, in)
+	}
+
+	outputCount, err := readCount(r)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	for i := uint64(0); i < outputCount; i++ {
+		var out TxOut
+
+		if err := binary.Read(r, binary.LittleEndian, &out.Value); err != nil {
+			return Transaction{}, err
+		}
+
+		scriptPubKey, err := readBytes(r)
+		if err != nil {
+			return Transaction{}, err
+		}
+		out.ScriptPubKey = hex.EncodeToString(scriptPubKey)
+
+		tx.Vout = append(tx.Vout, out)
+	}
+
+	if segwit {
+		hasWitness := false
+		for i := range tx.Vin {
+			items, err := readCount(r)
+			if err != nil {
+				return Transaction{}, err
+			}
+
+			for j := uint64(0); j < items; j++ {
+				item, err := readBytes(r)
+				if err != nil {
+					return Transaction{}, err
+				}
+				tx.Vin[i].Witness = append(tx.Vin[i].Witness, hex.EncodeToString(item))
+			}
+			hasWitness = hasWitness || items > 0
+		}
+
+		// witness serialization without witnesses is non canonical
+		if !hasWitness {
+			return Transaction{}, ierrors.ErrInvalidTx
+		}
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &tx.Locktime); err != nil {
+		return Transaction{}, err
+	}
+
+	return tx, nil
+}
+
+func readCount(r io.Reader) (uint64, error) {
+	count, err := en.ReadCompactSize(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if count > maxWireSize {
+		return 0, ierrors.ErrInvalidTx
+	}
+	return count, nil
+}
+
+// reads CompactSize length prefixed bytes
+func readBytes(r io.Reader) ([]byte, error) {
+	size, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+// Describe fills what mempool json derives from raw scripts [ASM, script types, addresses
+// and inner redeem / witness scripts], prevouts must already carry value and scriptpubkey
+func Describe(tx *Transaction, params *chaincfg.Params) error {
+	for i := range tx.Vout {
+		if err := describeOutput(&tx.Vout[i], params); err != nil {
+			return err
+		}
+	}
+
+	for i := range tx.Vin {
+		in := &tx.Vin[i]
+		if err := describeOutput(&in.Prevout, params); err != nil {
+			return err
+		}
+
+		scriptSig, err := hex.DecodeString(in.ScriptSig)
+		if err != nil {
+			return ierrors.ErrInvalidScript
+		}
+
+		if in.ScriptSigAsm, err = opcode.Disassemble(scriptSig); err != nil {
+			return err
+		}
+
+		if err := describeInnerScripts(in, scriptSig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func describeOutput(out *TxOut, params *chaincfg.Params) error {
+	scriptPubKey, err := hex.DecodeString(out.ScriptPubKey)
+	if err != nil {
+		return ierrors.ErrInvalidScript
+	}
+
+	if out.ScriptPubKeyAsm, err = opcode.Disassemble(scriptPubKey); err != nil {
+		return err
+	}
+
+	scriptType := script.Classify(scriptPubKey).Type
+	out.ScriptPubKeyType = string(scriptType)
+
+	if out.ScriptPubKeyAddress, err = address.EncodeAddress(out.ScriptPubKeyAsm, scriptType, params); err != nil {
+		return err
+	}
+	return nil
+}
+
+// inner scripts are set like esplora does
+//
+// - p2sh: redeem script, last push of scriptsig
+// - p2wsh and p2sh-p2wsh: witness script, last witness item
+//
+// tapscripts are not set, given dataset doesn't set them either
+func describeInnerScripts(in *TxIn, scriptSig []byte) error {
+	witness := make([][]byte, len(in.Witness))
+	for i, item := range in.Witness {
+		data, err := hex.DecodeString(item)
+		if err != nil {
+			return ierrors.ErrInvalidTx
+		}
+		witness[i] = data
+	}
+
+	var redeemScript, witnessScript []byte
+	switch transaction.Type(in.Prevout.ScriptPubKeyType) {
+	case transaction.P2SH:
+		var ok bool
+		if redeemScript, ok = script.LastPush(scriptSig); !ok {
+			break
+		}
+
+		if version, program, ok := script.ExtractWitnessProgram(redeemScript); ok && version == 0 && len(program) == 32 && len(witness) > 0 {
+			witnessScript = witness[len(witness)-1]
+		}
+	case transaction.P2WSH:
+		if len(witness) > 0 {
+			witnessScript = witness[len(witness)-1]
+		}
+	}
+
+	var err error
+	if redeemScript != nil {
+		if in.InnerRedeemScriptAsm, err = opcode.Disassemble(redeemScript); err != nil {
+			return err
+		}
+	}
+
+	if witnessScript != nil {
+		if in.InnerWitnessScriptAsm, err = opcode.Disassemble(witnessScript); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package miner_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	config "sob-miner"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
+	"sob-miner/internal/miner"
+	"sob-miner/internal/path"
+	"sob-miner/internal/report"
+	"sob-miner/pkg/encoding"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// child spends output of parent, both in mempool dataset
+const (
+	parentTxid = "2d4d450afe9432440531531c06b407a65fac6378bc68bf9b7bdf5f610cf9a352"
+	childTxid  = "1cbd72230995f87cf262ce4a85a69c737e863b3d67cb69c666a0295bec5d07f5"
+)
+
+// p2pkh and p2sh-p2wsh spends
+const (
+	p2pkhTxid     = "004947e806c5afa74ea4b64de0bfe63bb7488c2c3e4e5d4d5d6c8403d16de46a"
+	p2shP2wshTxid = "0116cb33d4af228a15d3f2951370c24b3da23274e9835307707067ec7422640c"
+)
+
+func silentLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return logger
+}
+
+// sql mempool holding txs of mempool dataset
+func newSQLPool(txids ...string) mempool.Mempool {
+	pool, err := mempool.New(sqlite.Open(filepath.Join(GinkgoT().TempDir(), "mempool.db")), mempool.Opts{Logger: silentLogger(), Dust: 546},
+		&gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)})
+	Expect(err).To(BeNil())
+
+	for _, txid := range txids {
+		data, err := os.ReadFile(filepath.Join(path.MempoolDataPath, txid+".json"))
+		Expect(err).To(BeNil())
+
+		var tx mempool.Transaction
+		Expect(json.Unmarshal(data, &tx)).To(Succeed())
+		Expect(pool.PutTx(tx)).To(Succeed())
+	}
+	return pool
+}
+
+// weight of tx in mempool by RPC txid
+func weightOf(pool mempool.Mempool, txid string) uint64 {
+	txs, err := pool.Txs()
+	Expect(err).To(BeNil())
+	for _, tx := range txs {
+		if mempool.HashToTxid(tx.Hash) == txid {
+			return tx.Weight
+		}
+	}
+	Fail("tx not in mempool " + txid)
+	return 0
+}
+
+// mines to a temporary output file, returns coinbase and txids of block
+func mine(pool mempool.Mempool, opts miner.Opts) (string, []string, error) {
+	out := filepath.Join(GinkgoT().TempDir(), "output.txt")
+	outFilePath := path.OutFilePath
+	path.OutFilePath = out
+	DeferCleanup(func() { path.OutFilePath = outFilePath })
+
+	opts.Logger = silentLogger()
+	m, err := miner.New(pool, opts)
+	Expect(err).To(BeNil())
+	if err := m.Mine(); err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.Open(out)
+	Expect(err).To(BeNil())
+	defer file.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	Expect(scanner.Err()).To(BeNil())
+	Expect(len(lines)).To(BeNumerically(">=", 3))
+
+	// header, coinbase, coinbase txid, txids
+	return lines[1], lines[3:], nil
+}
+
+func decodeTx(hexTx string) mempool.Transaction {
+	raw, err := hex.DecodeString(hexTx)
+	Expect(err).To(BeNil())
+	tx, err := mempool.DecodeTx(raw)
+	Expect(err).To(BeNil())
+	return tx
+}
+
+// weight and sigop cost of a mined tx
+func costOf(tx mempool.Transaction) (uint64, uint64) {
+	_, _, weight, err := tx.Hash()
+	Expect(err).To(BeNil())
+	sigOpCost, err := tx.SigOpCost()
+	Expect(err).To(BeNil())
+	return uint64(weight), sigOpCost
+}
+
+var _ = Describe("Mine", func() {
+	// reservation leaving budget txs may fill
+	reserveAllBut := func(budget uint64) uint64 {
+		return uint64(config.MAX_BLOCK_SIZE) - budget
+	}
+
+	Context("Budget", func() {
+		// header and largest tx count compact size of a block
+		const headerWeight, maxTxCountWeight = 80 * 4, 3 * 4
+
+		// weight of empty block coinbase, same for any block as values and commitment are fixed width
+		coinbaseWeight := func(opts miner.Opts) uint64 {
+			coinbase, _, err := mine(newSQLPool(), opts)
+			Expect(err).To(BeNil())
+			weight, _ := costOf(decodeTx(coinbase))
+			return weight
+		}
+
+		It("should reject reservation too small for header and coinbase", func() {
+			reserved := headerWeight + maxTxCountWeight + coinbaseWeight(miner.Opts{})
+
+			_, _, err := mine(newSQLPool(), miner.Opts{CoinbaseReservedWeight: reserved - 1})
+			Expect(err).To(MatchError(miner.ErrCoinbaseExceedsBudget))
+
+			_, _, err = mine(newSQLPool(), miner.Opts{CoinbaseReservedWeight: reserved})
+			Expect(err).To(BeNil())
+
+			// p2pkh payout costs 4 legacy sigops
+			_, _, err = mine(newSQLPool(), miner.Opts{CoinbaseReservedSigOps: 3})
+			Expect(err).To(MatchError(miner.ErrCoinbaseExceedsBudget))
+		})
+
+		It("should keep block filled to the limit within max block weight", func() {
+			txids := []string{parentTxid, childTxid, p2pkhTxid, p2shP2wshTxid}
+			pool := newSQLPool(txids...)
+			txsWeight := uint64(0)
+			for _, txid := range txids {
+				txsWeight += weightOf(pool, txid)
+			}
+
+			// txs fill exactly what reservation leaves free
+			reserved := headerWeight + maxTxCountWeight + coinbaseWeight(miner.Opts{})
+			maxBlockSize := config.MAX_BLOCK_SIZE
+			config.MAX_BLOCK_SIZE = int(reserved + txsWeight)
+			DeferCleanup(func() { config.MAX_BLOCK_SIZE = maxBlockSize })
+
+			coinbase, mined, err := mine(pool, miner.Opts{CoinbaseReservedWeight: reserved})
+			Expect(err).To(BeNil())
+			Expect(mined).To(HaveLen(len(txids)))
+
+			cbWeight, _ := costOf(decodeTx(coinbase))
+			txCountWeight := uint64(len(encoding.CompactSize(uint64(len(mined)+1)))) * 4
+			blockWeight := headerWeight + txCountWeight + cbWeight + txsWeight
+			Expect(blockWeight).To(BeNumerically("<=", config.MAX_BLOCK_SIZE))
+			// reservation assumes largest tx count
+			Expect(blockWeight).To(Equal(uint64(config.MAX_BLOCK_SIZE) - maxTxCountWeight + txCountWeight))
+		})
+
+		It("should cover a segwit coinbase with many payouts by default reservation", func() {
+			addrs := []string{
+				"18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PX",                             // p2pkh
+				"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy",                             // p2sh
+				"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq",                     // p2wpkh
+				"bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", // p2wsh
+				"bc1p5d7rjq7g6rdk2yhzks9smlaqtedr4dekq08ge8ztwac72sfr9rusxg3297", // p2tr
+			}
+			payouts := []config.Payout{}
+			for i := 0; i < 3; i++ {
+				for _, addr := range addrs {
+					payouts = append(payouts, config.Payout{Address: addr, Weight: 1})
+				}
+			}
+
+			coinbase, _, err := mine(newSQLPool(), miner.Opts{Payouts: payouts})
+			Expect(err).To(BeNil())
+
+			tx := decodeTx(coinbase)
+			// witness commitment and payouts
+			Expect(tx.Vout).To(HaveLen(1 + len(payouts)))
+			Expect(tx.Vin[0].Witness).To(HaveLen(1))
+
+			weight, sigOpCost := costOf(tx)
+			Expect(headerWeight + maxTxCountWeight + weight).To(BeNumerically("<=", miner.DefaultCoinbaseReservedWeight))
+			Expect(sigOpCost).To(BeNumerically("<=", miner.DefaultCoinbaseReservedSigOps))
+		})
+	})
+
+	Context("Optimize with sql mempool", func() {
+		It("should not mine child without its parent", func() {
+			pool := newSQLPool(parentTxid, childTxid)
+			child := weightOf(pool, childTxid)
+			Expect(weightOf(pool, parentTxid)).To(BeNumerically(">", child))
+
+			// only child fits, greedy stops at parent and optimizer fills the rest
+			_, txids, err := mine(pool, miner.Opts{Optimize: true, CoinbaseReservedWeight: reserveAllBut(child)})
+			Expect(err).To(BeNil())
+			Expect(txids).To(BeEmpty())
+		})
+
+		It("should mine child after its parent", func() {
+			pool := newSQLPool(parentTxid, childTxid)
+			parent, child := weightOf(pool, parentTxid), weightOf(pool, childTxid)
+
+			// no room for greedy, both txs are mined by optimizer
+			_, txids, err := mine(pool, miner.Opts{Optimize: true, CoinbaseReservedWeight: reserveAllBut(parent + child)})
+			Expect(err).To(BeNil())
+			Expect(txids).To(Equal([]string{parentTxid, childTxid}))
+		})
+	})
+
+	Context("Rejections", func() {
+		It("should evict children of a rejected parent", func() {
+			pool := newSQLPool(parentTxid, childTxid, p2pkhTxid)
+
+			// parent's input is spent by a tx mined before
+			txs, err := pool.Txs()
+			Expect(err).To(BeNil())
+			parent := txs[0]
+			Expect(mempool.HashToTxid(parent.Hash)).To(Equal(parentTxid))
+			inputs, err := pool.GetInputs(parent.Hash)
+			Expect(err).To(BeNil())
+			Expect(pool.MarkOutPointSpent(inputs[0].FundingTxHash, inputs[0].FundingIndex)).To(Succeed())
+
+			var buf bytes.Buffer
+			_, txids, err := mine(pool, miner.Opts{Rejections: report.NewRejections(&buf)})
+			Expect(err).To(BeNil())
+			Expect(txids).To(Equal([]string{p2pkhTxid}))
+
+			rejections, err := report.ReadRejections(&buf)
+			Expect(err).To(BeNil())
+			Expect(rejections).To(HaveLen(2))
+			Expect(rejections[0].Txid).To(Equal(parentTxid))
+			Expect(rejections[0].Code).To(Equal(ierrors.CodeOf(ierrors.ErrAlreadySpent)))
+			Expect(rejections[1].Txid).To(Equal(childTxid))
+			Expect(rejections[1].Code).To(Equal(ierrors.CodeMissingInputs))
+			Expect(rejections[1].Stage).To(Equal(report.StageMine))
+
+			left, err := pool.Txs()
+			Expect(err).To(BeNil())
+			Expect(left).To(BeEmpty())
+		})
+	})
+})
@@ -1,38 +1,140 @@
 package miner
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	config "sob-miner"
 	"sob-miner/internal/ierrors"
 	"sob-miner/internal/mempool"
 	"sob-miner/internal/path"
+	"sob-miner/internal/report"
+	"sob-miner/pkg/address"
 	"sob-miner/pkg/block"
+	"sob-miner/pkg/chaincfg"
+	"sob-miner/pkg/encoding"
+	"sob-miner/pkg/script"
+	"sob-miner/pkg/transaction"
 	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	"gorm.io/gorm"
 )
 
 type miner struct {
 	block   *block.Block
 	mempool mempool.Mempool
 
-	logger         *logrus.Logger
-	rejectedTxFile *os.File
+	logger     *logrus.Logger
+	rejections *report.Rejections
 
 	maxBlockSize uint
+	params       *chaincfg.Params
+
+	// locally maintained chain, if nil block is mined on top of bytes32(0x0) with DefaultBits
+	chain  *block.Chain
+	height uint32
+
+	payouts []payout
+
+	// budget kept free for block header and coinbase while selecting txs
+	coinbaseReservedWeight uint64
+	coinbaseReservedSigOps uint64
+
+	// fill tail of block with knapsack optimizer instead of greedy picking
+	optimize           bool
+	optimizeTimeout    time.Duration
+	optimizeCandidates int
+}
+
+// coinbase output decoded from config.Payout
+type payout struct {
+	script []byte
+	weight uint64
 }
 
+var (
+	ErrNoPayouts             = errors.New("at least one coinbase payout with non zero weight is required")
+	ErrPayoutWeightsOverflow = errors.New("sum of coinbase payout weights overflows")
+	ErrCoinbaseExceedsBudget = errors.New("coinbase does not fit in reserved weight or sigops")
+	ErrBlockTooLarge         = errors.New("block exceeds max weight or sigop cost")
+)
+
+// same as bitcoin core's DEFAULT_BLOCK_RESERVED_WEIGHT and coinbase sigops reservation
+const (
+	DefaultCoinbaseReservedWeight uint64 = 4_000
+	DefaultCoinbaseReservedSigOps uint64 = 400
+)
+
+const (
+	DefaultOptimizeTimeout    = 2 * time.Second
+	DefaultOptimizeCandidates = 256
+)
+
+// serialized header is 80 non witness bytes
+const blockHeaderWeight = 80 * 4
+
+// nBits used when no chain is configured
+// encodes target 0x0000ffff00000000000000000000000000000000000000000000000000000000
+const DefaultBits uint32 = 0x1f00ffff
+
+// block height used when no chain is configured
+const DefaultHeight uint32 = 835_944
+
 func New(mempool mempool.Mempool, opts Opts) (*miner, error) {
-	file, err := os.OpenFile("../rejected_txs.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
+	if opts.Params == nil {
+		opts.Params = &chaincfg.MainNetParams
+	}
+
+	if opts.Height == 0 {
+		opts.Height = DefaultHeight
+	}
+
+	if len(opts.Payouts) == 0 {
+		opts.Payouts = config.CoinbasePayouts[opts.Params.Name]
+	}
+
+	if opts.CoinbaseReservedWeight == 0 {
+		opts.CoinbaseReservedWeight = DefaultCoinbaseReservedWeight
+	}
+
+	if opts.CoinbaseReservedSigOps == 0 {
+		opts.CoinbaseReservedSigOps = DefaultCoinbaseReservedSigOps
+	}
+
+	if opts.OptimizeTimeout == 0 {
+		opts.OptimizeTimeout = DefaultOptimizeTimeout
+	}
+
+	if opts.OptimizeCandidates == 0 {
+		opts.OptimizeCandidates = DefaultOptimizeCandidates
+	}
+
+	payouts := []payout{}
+	totalWeight := uint64(0)
+	for _, p := range opts.Payouts {
+		if p.Weight == 0 {
+			continue
+		}
+
+		// payoutOutputs divides by sum of weights
+		if totalWeight+p.Weight < totalWeight {
+			return nil, ErrPayoutWeightsOverflow
+		}
+		totalWeight += p.Weight
+
+		script, err := address.PayToAddrScript(p.Address, opts.Params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payout address %s: %w", p.Address, err)
+		}
+		payouts = append(payouts, payout{script: script, weight: p.Weight})
+	}
+
+	if len(payouts) == 0 {
+		return nil, ErrNoPayouts
 	}
 
 	return &miner{
@@ -41,8 +143,19 @@ func New(mempool mempool.Mempool, opts Opts) (*miner, error) {
 		logger:       opts.Logger,
 		maxBlockSize: opts.MaxBlockSize,
 		mempool:      mempool,
+		chain:        opts.Chain,
+		params:       opts.Params,
+		height:       opts.Height,
+		payouts:      payouts,
+
+		coinbaseReservedWeight: opts.CoinbaseReservedWeight,
+		coinbaseReservedSigOps: opts.CoinbaseReservedSigOps,
 
-		rejectedTxFile: file,
+		optimize:           opts.Optimize,
+		optimizeTimeout:    opts.OptimizeTimeout,
+		optimizeCandidates: opts.OptimizeCandidates,
+
+		rejections: opts.Rejections,
 	}, nil
 }
 
@@ -63,29 +176,63 @@ func New(mempool mempool.Mempool, opts Opts) (*miner, error) {
 // save block to output.txt
 func (m *miner) Mine() error {
 	weight := 0
+	sigOpCost := 0
 	feeCollected := 0
 	wTxids := []string{
 		"0000000000000000000000000000000000000000000000000000000000000000",
 	}
 
-	GivenDifficulty := HexMustDecode("0000ffff00000000000000000000000000000000000000000000000000000000")
+	height := m.height
+	if m.chain != nil {
+		height = m.chain.Height() + 1
+	}
+	segwitActive := m.params.IsSegwitActive(height)
+
+	// coinbase size is known upfront, make sure reservation covers it with header
+	// so txs can fill remaining budget
+	cbWeight, cbSigOpCost, err := coinbaseCost(m.buildCoinbase(height, 0, wTxids, segwitActive))
+	if err != nil {
+		return err
+	}
+
+	if blockHeaderWeight+maxTxCountWeight+cbWeight > m.coinbaseReservedWeight || cbSigOpCost > m.coinbaseReservedSigOps {
+		m.logger.Infof("coinbase weight %d sigops %d reserved weight %d sigops %d", cbWeight, cbSigOpCost, m.coinbaseReservedWeight, m.coinbaseReservedSigOps)
+		return ErrCoinbaseExceedsBudget
+	}
+
+	maxWeight := config.MAX_BLOCK_SIZE - int(m.coinbaseReservedWeight)
+	maxSigOpCost := script.MaxBlockSigOpsCost - int(m.coinbaseReservedSigOps)
+
+	include := func(tx transaction.Tx) {
+		weight += int(tx.Weight)
+		sigOpCost += int(tx.SigOpCost)
+		feeCollected += int(tx.FeeCollected)
+
+		m.block.Txs = append(m.block.Txs, tx.Hash) // hash is in LittleEndian
+		wTxids = append(wTxids, tx.WTXID)          // wTxid is in LittleEndian
+	}
 
-	//TODO: use logrus file than file writing
 PICK_TX:
-	for weight < config.MAX_BLOCK_SIZE {
+	for weight < maxWeight && sigOpCost < maxSigOpCost {
 		tx, err := m.mempool.PickBestTx()
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
+			if errors.Is(err, ierrors.ErrTxNotFound) {
 				m.logger.Info("mempool is empty")
 				break PICK_TX
 			}
 			return err
 		}
 
-		if weight+int(tx.Weight) > config.MAX_BLOCK_SIZE {
-			tx, err = m.mempool.PickBestTxWithinWeight(uint64(config.MAX_BLOCK_SIZE - weight))
+		// block is a knapsack with two dimensions, weight and sigop cost
+		if weight+int(tx.Weight) > maxWeight || sigOpCost+int(tx.SigOpCost) > maxSigOpCost {
+			// rest of block is filled by optimizer below
+			if m.optimize {
+				break PICK_TX
+			}
+
+			tx, err = m.mempool.PickBestTxWithinBudget(uint64(maxWeight-weight), uint64(maxSigOpCost-sigOpCost))
 			if err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
+				if errors.Is(err, ierrors.ErrTxNotFound) {
 					m.logger.Info("mempool is empty")
 					break PICK_TX
 				}
@@ -93,94 +240,76 @@ PICK_TX:
 			}
 		}
 
-		fmt.Printf("\rProcessing... tx: %s collected: %d with weight: %d", tx.Hash, feeCollected, weight)
+		fmt.Printf("\rProcessing... tx: %s collected: %d with weight: %d sigops: %d", tx.Hash, feeCollected, weight, sigOpCost)
 
-		inputs, err := m.mempool.GetInputs(tx.Hash)
+		ok, err := m.checkTx(tx, segwitActive)
 		if err != nil {
-			m.logger.Info("unable to get inputs", err)
 			return err
 		}
 
-		if err := m.mempool.ValidateWholeTx(tx, inputs); err != nil {
-			m.logger.Infof("tx is invalid %s", err)
-			m.rejectedTxFile.WriteString(tx.Hash + " Reason: Invalid tx" + "\n")
-			continue PICK_TX
+		if ok {
+			include(tx)
 		}
+	}
 
-		// signature checks and stack execution
-		for _, input := range inputs {
-			if err := m.mempool.MarkOutPointSpent(input.FundingTxHash, input.FundingIndex); err != nil {
-				if errors.Is(err, ierrors.ErrAlreadySpent) {
-					m.logger.Info("already spent")
-					m.rejectedTxFile.WriteString(tx.Hash + " Reason: Already spent" + "\n")
-					continue PICK_TX
-				}
-			}
+	// greedy picking leaves gaps once best tx stops fitting,
+	// fill remaining budget with best fee set of candidates instead
+	for m.optimize {
+		candidates, err := m.mempool.PickBestTxsWithinBudget(uint64(maxWeight-weight), uint64(maxSigOpCost-sigOpCost), m.optimizeCandidates)
+		if err != nil {
+			return err
 		}
 
-		if err := m.mempool.DeleteTx(tx.ID); err != nil {
-			m.logger.Info("unable to delete tx", err)
-			return err
+		// ranked by prioritised fee, coinbase is still paid FeeCollected
+		items := make([]Item, len(candidates))
+		for i, tx := range candidates {
+			items[i] = Item{Fee: tx.ModifiedFee(), Weight: tx.Weight, SigOpCost: tx.SigOpCost}
 		}
 
-		// include tx in block
-		weight += int(tx.Weight)
-		// if weight > config.MAX_BLOCK_SIZE {
-		// 	m.logger.Info("tx weight is too big")
-		// 	weight -= int(tx.Weight)
-		// 	break PICK_TX
-		// }
-		feeCollected += int(tx.FeeCollected)
+		chosen, report := Optimize(items, uint64(maxWeight-weight), uint64(maxSigOpCost-sigOpCost), m.optimizeTimeout)
+		m.logger.Infof("tail of %d candidates: greedy fee %d optimized fee %d (+%d) nodes %d optimal %v",
+			report.Candidates, report.GreedyFee, report.OptimizedFee, report.OptimizedFee-report.GreedyFee, report.Nodes, report.Optimal)
 
-		m.block.Txs = append(m.block.Txs, tx.Hash) // hash is in LittleEndian
-		wTxids = append(wTxids, tx.WTXID)          // wTxid is in LittleEndian
+		if len(chosen) == 0 {
+			break
+		}
+
+		rejected := false
+		for _, i := range chosen {
+			ok, err := m.checkTx(candidates[i], segwitActive)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				rejected = true
+				continue
+			}
+			include(candidates[i])
+		}
+
+		// budget freed by rejected txs is optimized again with new candidates
+		if !rejected {
+			break
+		}
 	}
 
 	// build CoinBase Tx
 	// - has one input ✅
 	// - - in hash  and witness  = bytes32(0x0) ✅
 	// - - in vout max ✅
-	// - - include block height in sig script ✅
+	// - - include block height in sig script [BIP34] ✅
 	// - has two outputs
 	// - - compute wtxids and witnessCommitement = sha(merkle(wtxids) + bytes32(0x0))
 	// - - out scriptputkey == op_return + PushBytes + witnessCommitement
-	// - - other output has fee collection
+	// - - other outputs split block subsidy + fee collection across payouts
 	// serialize Coinbase with Witness
 	// append beginning of tx list
 
-	coinbaseVin := mempool.TxIn{
-		Txid:       "0000000000000000000000000000000000000000000000000000000000000000",
-		Vout:       0xffffffff,
-		ScriptSig:  "0368c10c",
-		Sequence:   0xffffffff,
-		Witness:    []string{"0000000000000000000000000000000000000000000000000000000000000000"},
-		IsCoinbase: true,
-	}
-
-	coinbaseVouts := []mempool.TxOut{
-		{
-			Value:        0,
-			ScriptPubKey: "6a24aa21a9ed" + Hash256(GenerateMerkleRoot(wTxids)+"0000000000000000000000000000000000000000000000000000000000000000"),
-		},
-		{
-			Value:        uint64(feeCollected),
-			ScriptPubKey: "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac",
-		},
-	}
-
-	// fmt.Print("wtxid root merkle hash ", GenerateMerkleRoot(wTxids), "\n"+"witness commitment ", Hash256(GenerateMerkleRoot(wTxids)+"0000000000000000000000000000000000000000000000000000000000000000"), "\n", GenerateMerkleRoot(wTxids)+"0000000000000000000000000000000000000000000000000000000000000000")
-	// fmt.Println("")
-
-	// //debug print first and last 5 wtxids
-	// fmt.Println("first 5 wtxids ", wTxids[:5])
-	// fmt.Println("last 5 wtxids ", wTxids[len(wTxids)-5:])
-	// fmt.Println("")
+	coinbaseTx := m.buildCoinbase(height, uint64(feeCollected), wTxids, segwitActive)
 
-	coinbaseTx := mempool.Transaction{
-		Version:  2,
-		Locktime: 0,
-		Vin:      []mempool.TxIn{coinbaseVin},
-		Vout:     coinbaseVouts,
+	if err := mempool.ValidateCoinbase(coinbaseTx, height, uint64(feeCollected), m.params); err != nil {
+		return err
 	}
 
 	cbTxId, _, _, err := coinbaseTx.Hash()
@@ -190,31 +319,54 @@ PICK_TX:
 
 	m.block.Txs = append([]string{cbTxId}, m.block.Txs...)
 
+	// exact block weight: header + tx count + coinbase + txs
+	cbWeight, cbSigOpCost, err = coinbaseCost(coinbaseTx)
+	if err != nil {
+		return err
+	}
+
+	blockWeight := blockHeaderWeight + uint64(len(encoding.CompactSize(uint64(len(m.block.Txs)))))*4 + cbWeight + uint64(weight)
+	blockSigOpCost := cbSigOpCost + uint64(sigOpCost)
+	if blockWeight > uint64(config.MAX_BLOCK_SIZE) || blockSigOpCost > script.MaxBlockSigOpsCost {
+		m.logger.Infof("block weight %d sigop cost %d", blockWeight, blockSigOpCost)
+		return ErrBlockTooLarge
+	}
+
 	// build block header
 	// add block version 2
 	// prev block bytes32(0x0)
 	// add merklee root
 	// add time
-	// add nbits 0x1f00ffff
+	// add nbits 0x1f00ffff [or chain's next required work]
 	// mine with nonce 0
 
 	blockHeader := block.BlocKHeader{
 		Version:           4,
 		TimeStamp:         uint32(time.Now().Unix()),
-		NBits:             0x1f00ffff,
+		NBits:             DefaultBits,
 		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000000",
 		Nonce:             0,
 		MerkleRoot:        reverseStringByteOrder(GenerateMerkleRoot(m.block.Txs)),
 	}
 
-	respChan := make(chan uint32)
+	if m.chain != nil {
+		tip := m.chain.Tip()
+		blockHeader.PreviousBlockHash = tip.Hash()
+		blockHeader.NBits = m.chain.NextWorkRequired(blockHeader.TimeStamp)
+	}
+
+	respChan := make(chan block.BlocKHeader)
 	doneChan := make(chan struct{})
 
-	// spin 10 go routines which listen for nonces
-	// after receiving nonces they build block header and send as response
-	nextNonce := uint32(0)
+	// spin 10 go routines which roll nonces
+	// and send header back once its hash is below target.
+	// after 2^32 nonces timestamp is bumped and nonces start over, nBits follow it
+	// as chains with min difficulty blocks may require other work at a later time
+	nextNonce := uint64(0)
 	for i := 0; i < 10; i++ {
 		go func(blockHeader block.BlocKHeader) {
+			baseTime := blockHeader.TimeStamp
+			target := blockHeader.Target()
 			for {
 				select {
 				case <-doneChan:
@@ -223,11 +375,23 @@ PICK_TX:
 					// generate blockHash sha(sha(header_serialized))
 					// mine it until less than difficulty (tune nonce)
 
-					blockHeader.Nonce = atomic.AddUint32(&nextNonce, 1)
+					n := atomic.AddUint64(&nextNonce, 1)
+					blockHeader.Nonce = uint32(n)
+					if ts := baseTime + uint32(n>>32); ts != blockHeader.TimeStamp {
+						blockHeader.TimeStamp = ts
+						if m.chain != nil {
+							blockHeader.NBits = m.chain.NextWorkRequired(ts)
+							target = blockHeader.Target()
+						}
+					}
+
 					blockHash := doubleHash(blockHeader.Serialize())
-					if bytes.Compare(reverseByteOrder(blockHash), GivenDifficulty) < 0 {
+					if block.HashToBig(blockHash).Cmp(target) < 0 {
 						m.logger.Infof("\nNonce :- %d hash is %s", blockHeader.Nonce, hex.EncodeToString(blockHash))
-						respChan <- blockHeader.Nonce
+						select {
+						case respChan <- blockHeader:
+						case <-doneChan:
+						}
 						return
 					}
 				}
@@ -235,12 +399,17 @@ PICK_TX:
 		}(blockHeader)
 	}
 
-	// wait for nonces
-	nonce := <-respChan
+	// wait for a header
+	blockHeader = <-respChan
 	close(doneChan)
-	blockHeader.Nonce = nonce
 	m.logger.Infof("Nonce: %d hash is %s", blockHeader.Nonce, hex.EncodeToString(doubleHash(blockHeader.Serialize())))
 
+	if m.chain != nil {
+		if err := m.chain.Append(blockHeader); err != nil {
+			return err
+		}
+	}
+
 	os.Remove(path.OutFilePath)
 
 	// open output.txt file and write blockHeader serialized , coinbase serialized , txids
@@ -263,13 +432,156 @@ PICK_TX:
 
 	m.logger.Infof("\n mined block %d ", blockHeader.Nonce)
 	m.logger.Infof("Total Fee Collected %d \n", feeCollected)
-	m.logger.Infof("Total weight %d", weight)
+	m.logger.Infof("Total weight %d", blockWeight)
+	m.logger.Infof("Total sigop cost %d", blockSigOpCost)
 
 	// Hash must be Le
 
 	return nil
 }
 
+// builds coinbase paying subsidy + fees to payouts, with witness commitment of wTxids when segwit is active.
+// coinbase size does not depend on fees or wTxids, values and commitment are fixed width
+func (m *miner) buildCoinbase(height uint32, fees uint64, wTxids []string, segwitActive bool) mempool.Transaction {
+	coinbaseVin := mempool.TxIn{
+		Txid:       "0000000000000000000000000000000000000000000000000000000000000000",
+		Vout:       0xffffffff,
+		ScriptSig:  hex.EncodeToString(mempool.CoinbaseHeightScript(height)),
+		Sequence:   0xffffffff,
+		Witness:    []string{"0000000000000000000000000000000000000000000000000000000000000000"},
+		IsCoinbase: true,
+	}
+
+	coinbaseVouts := []mempool.TxOut{
+		{
+			Value:        0,
+			ScriptPubKey: "6a24aa21a9ed" + Hash256(GenerateMerkleRoot(wTxids)+"0000000000000000000000000000000000000000000000000000000000000000"),
+		},
+	}
+	coinbaseVouts = append(coinbaseVouts, m.payoutOutputs(m.params.BlockSubsidy(height)+fees)...)
+
+	if !segwitActive {
+		coinbaseVin.Witness = nil
+		coinbaseVouts = coinbaseVouts[1:]
+	}
+
+	return mempool.Transaction{
+		Version:  2,
+		Locktime: 0,
+		Vin:      []mempool.TxIn{coinbaseVin},
+		Vout:     coinbaseVouts,
+	}
+}
+
+// checkTx validates tx against its inputs and marks them spent, a valid tx is removed from mempool
+// as mined and a rejected one is evicted with its descendants. returns false when tx is rejected
+func (m *miner) checkTx(tx transaction.Tx, segwitActive bool) (bool, error) {
+	// witness txs are invalid before segwit activation
+	if !segwitActive && tx.WTXID != tx.Hash {
+		return false, m.evict(tx, ierrors.ErrUnexpectedWitness)
+	}
+
+	inputs, err := m.mempool.GetInputs(tx.Hash)
+	if err != nil {
+		m.logger.Info("unable to get inputs", err)
+		return false, err
+	}
+
+	if err := m.mempool.ValidateWholeTx(tx, inputs); err != nil {
+		m.logger.Infof("tx is invalid %s", err)
+		return false, m.evict(tx, err)
+	}
+
+	// signature checks and stack execution
+	for _, input := range inputs {
+		if err := m.mempool.MarkOutPointSpent(input.FundingTxHash, input.FundingIndex); err != nil {
+			if errors.Is(err, ierrors.ErrAlreadySpent) {
+				m.logger.Info("already spent")
+				return false, m.evict(tx, err)
+			}
+
+			m.logger.Info("unable to mark outpoint spent", err)
+			return false, err
+		}
+	}
+
+	if err := m.mempool.DeleteTx(tx.ID); err != nil {
+		m.logger.Info("unable to delete tx", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// evict rejects tx and every tx spending from it, which can't be mined without it
+func (m *miner) evict(tx transaction.Tx, err error) error {
+	m.reject(tx, err)
+
+	descendants, err := m.mempool.EvictTx(tx.ID)
+	if err != nil {
+		m.logger.Info("unable to evict tx", err)
+		return err
+	}
+
+	for _, d := range descendants {
+		m.reject(d, fmt.Errorf("%w: %s", ierrors.ErrMissingInputs, mempool.HashToTxid(tx.Hash)))
+	}
+	return nil
+}
+
+func (m *miner) reject(tx transaction.Tx, err error) {
+	if m.rejections == nil {
+		return
+	}
+
+	if err := m.rejections.Add(report.NewRejection(report.StageMine, "", mempool.HashToTxid(tx.Hash), err)); err != nil {
+		m.logger.Warn("unable to report rejection ", err)
+	}
+}
+
+// tx count compact size takes at most 3 bytes in a block [< 65536 txs]
+const maxTxCountWeight = 3 * 4
+
+// weight and sigop cost of a coinbase
+func coinbaseCost(coinbaseTx mempool.Transaction) (uint64, uint64, error) {
+	_, _, weight, err := coinbaseTx.Hash()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sigOpCost, err := coinbaseTx.SigOpCost()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return uint64(weight), sigOpCost, nil
+}
+
+// splits reward across payouts by weight, rounding remainder goes to first payout
+func (m *miner) payoutOutputs(reward uint64) []mempool.TxOut {
+	totalWeight := uint64(0)
+	for _, p := range m.payouts {
+		totalWeight += p.weight
+	}
+
+	outs := make([]mempool.TxOut, len(m.payouts))
+	distributed := uint64(0)
+	for i, p := range m.payouts {
+		// reward * weight / totalWeight without overflowing u64
+		share := new(big.Int).Mul(new(big.Int).SetUint64(reward), new(big.Int).SetUint64(p.weight))
+		share.Div(share, new(big.Int).SetUint64(totalWeight))
+
+		outs[i] = mempool.TxOut{
+			Value:        share.Uint64(),
+			ScriptPubKey: hex.EncodeToString(p.script),
+		}
+		distributed += outs[i].Value
+	}
+	outs[0].Value += reward - distributed
+
+	return outs
+}
+
 func doubleHash(header []byte) []byte {
 	h := sha256.New()
 	h.Write(header)
@@ -5,7 +5,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	config "sob-miner"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/mempool"
 	"sob-miner/internal/miner"
+	"sob-miner/pkg/address"
+	"sob-miner/pkg/chaincfg"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -44,6 +49,93 @@ var _ = Describe("Miner", func() {
 			fmt.Println("merkleRoot", merkleRoot)
 		})
 	})
+
+	Context("Payouts", func() {
+		DescribeTable("should default to payouts of network",
+			func(params *chaincfg.Params) {
+				_, err := miner.New(nil, miner.Opts{Logger: silentLogger(), Params: params})
+				Expect(err).To(BeNil())
+			},
+			Entry("mainnet", &chaincfg.MainNetParams),
+			Entry("testnet3", &chaincfg.TestNet3Params),
+			Entry("signet", &chaincfg.SigNetParams),
+			Entry("regtest", &chaincfg.RegressionNetParams),
+		)
+
+		const (
+			p2pkh  = "18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PX"
+			p2wpkh = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+			p2sh   = "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"
+		)
+
+		// subsidy at miner.DefaultHeight, block has no txs so it is whole reward
+		reward := chaincfg.MainNetParams.BlockSubsidy(miner.DefaultHeight)
+
+		// values and scripts of payout outputs of a mined empty block, witness commitment left out
+		payoutsOf := func(payouts []config.Payout) []mempool.TxOut {
+			coinbase, txids, err := mine(newSQLPool(), miner.Opts{Payouts: payouts})
+			Expect(err).To(BeNil())
+			Expect(txids).To(BeEmpty())
+			return decodeTx(coinbase).Vout[1:]
+		}
+
+		scriptOf := func(addr string) string {
+			script, err := address.PayToAddrScript(addr, &chaincfg.MainNetParams)
+			Expect(err).To(BeNil())
+			return hex.EncodeToString(script)
+		}
+
+		It("should give rounding remainder to first payout", func() {
+			outs := payoutsOf([]config.Payout{{Address: p2pkh, Weight: 1}, {Address: p2wpkh, Weight: 1}, {Address: p2sh, Weight: 1}})
+			Expect(outs).To(HaveLen(3))
+
+			share := reward / 3
+			Expect(reward % 3).NotTo(BeZero())
+			Expect(outs[0].Value).To(Equal(share + reward%3))
+			Expect(outs[1].Value).To(Equal(share))
+			Expect(outs[2].Value).To(Equal(share))
+
+			Expect(outs[0].ScriptPubKey).To(Equal(scriptOf(p2pkh)))
+			Expect(outs[1].ScriptPubKey).To(Equal(scriptOf(p2wpkh)))
+			Expect(outs[2].ScriptPubKey).To(Equal(scriptOf(p2sh)))
+		})
+
+		It("should skip zero weight payouts", func() {
+			outs := payoutsOf([]config.Payout{{Address: p2pkh, Weight: 0}, {Address: p2wpkh, Weight: 3}, {Address: p2sh, Weight: 1}})
+			Expect(outs).To(HaveLen(2))
+			Expect(outs[0].ScriptPubKey).To(Equal(scriptOf(p2wpkh)))
+			Expect(outs[0].Value).To(Equal(reward - reward/4))
+			Expect(outs[1].Value).To(Equal(reward / 4))
+		})
+
+		It("should split reward when reward times weight overflows 64 bits", func() {
+			outs := payoutsOf([]config.Payout{{Address: p2pkh, Weight: 1 << 62}, {Address: p2wpkh, Weight: 1<<62 + 1<<61}})
+			Expect(outs).To(HaveLen(2))
+			Expect(outs[0].Value).To(Equal(reward * 2 / 5))
+			Expect(outs[1].Value).To(Equal(reward * 3 / 5))
+		})
+
+		It("should reject weights summing past 64 bits", func() {
+			_, err := miner.New(nil, miner.Opts{Payouts: []config.Payout{{Address: p2pkh, Weight: 1 << 63}, {Address: p2wpkh, Weight: 1 << 63}}})
+			Expect(err).To(MatchError(miner.ErrPayoutWeightsOverflow))
+		})
+
+		It("should require a payout with non zero weight", func() {
+			_, err := miner.New(nil, miner.Opts{Payouts: []config.Payout{{Address: p2pkh, Weight: 0}}})
+			Expect(err).To(MatchError(miner.ErrNoPayouts))
+		})
+
+		It("should reject address of another network", func() {
+			_, err := miner.New(nil, miner.Opts{Params: &chaincfg.TestNet3Params, Payouts: []config.Payout{{Address: p2pkh, Weight: 1}}})
+			Expect(err).To(MatchError(ierrors.ErrWrongNetwork))
+		})
+
+		It("should reject invalid address", func() {
+			_, err := miner.New(nil, miner.Opts{Payouts: []config.Payout{{Address: "18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PY", Weight: 1}}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid payout address"))
+		})
+	})
 })
 
 func reverseByteOrder(hash string) string {
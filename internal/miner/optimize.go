@@ -0,0 +1,146 @@
+package miner
+
+import (
+	"sort"
+	"time"
+)
+
+// Item is a candidate tx for the tail of a block
+type Item struct {
+	Fee       uint64
+	Weight    uint64
+	SigOpCost uint64
+}
+
+// TailReport compares greedy and optimized selection over same candidates and budget
+type TailReport struct {
+	Candidates int
+
+	GreedyFee    uint64
+	OptimizedFee uint64
+
+	// nodes explored by branch and bound, Optimal is false if search hit its deadline
+	Nodes   int
+	Optimal bool
+}
+
+// Greedy picks items by fee rate, skipping those which do not fit in remaining budget
+func Greedy(items []Item, weight, sigOpCost uint64) ([]int, uint64) {
+	chosen := []int{}
+	fee := uint64(0)
+
+	for _, i := range byFeeRate(items) {
+		if items[i].Weight > weight || items[i].SigOpCost > sigOpCost {
+			continue
+		}
+		weight -= items[i].Weight
+		sigOpCost -= items[i].SigOpCost
+		fee += items[i].Fee
+		chosen = append(chosen, i)
+	}
+
+	return chosen, fee
+}
+
+// Optimize solves the two dimensional 0/1 knapsack [weight, sigop cost] maximizing fee
+// with depth first branch and bound, starting from greedy solution.
+// bound is fractional knapsack over weight only, which never underestimates.
+// search stops at timeout and returns best selection found so far.
+func Optimize(items []Item, weight, sigOpCost uint64, timeout time.Duration) ([]int, TailReport) {
+	greedy, greedyFee := Greedy(items, weight, sigOpCost)
+
+	b := &branchAndBound{
+		items:    items,
+		order:    byFeeRate(items),
+		deadline: time.Now().Add(timeout),
+
+		best:    greedy,
+		bestFee: greedyFee,
+	}
+	b.search(0, weight, sigOpCost, 0)
+
+	return b.best, TailReport{
+		Candidates:   len(items),
+		GreedyFee:    greedyFee,
+		OptimizedFee: b.bestFee,
+		Nodes:        b.nodes,
+		Optimal:      !b.timedOut,
+	}
+}
+
+type branchAndBound struct {
+	items    []Item
+	order    []int // indexes of items sorted by fee rate desc
+	deadline time.Time
+
+	taken   []int
+	best    []int
+	bestFee uint64
+
+	nodes    int
+	timedOut bool
+}
+
+func (b *branchAndBound) search(depth int, weight, sigOpCost, fee uint64) {
+	if b.timedOut {
+		return
+	}
+
+	b.nodes++
+	// checking clock on every node is expensive
+	if b.nodes%1024 == 0 && time.Now().After(b.deadline) {
+		b.timedOut = true
+		return
+	}
+
+	if fee > b.bestFee {
+		b.bestFee = fee
+		b.best = append([]int{}, b.taken...)
+	}
+
+	if depth == len(b.order) || b.bound(depth, weight, fee) <= b.bestFee {
+		return
+	}
+
+	i := b.order[depth]
+	if b.items[i].Weight <= weight && b.items[i].SigOpCost <= sigOpCost {
+		b.taken = append(b.taken, i)
+		b.search(depth+1, weight-b.items[i].Weight, sigOpCost-b.items[i].SigOpCost, fee+b.items[i].Fee)
+		b.taken = b.taken[:len(b.taken)-1]
+	}
+
+	b.search(depth+1, weight, sigOpCost, fee)
+}
+
+// upper bound on fee reachable from depth: fill remaining weight by fee rate, last item fractionally
+func (b *branchAndBound) bound(depth int, weight, fee uint64) uint64 {
+	for _, i := range b.order[depth:] {
+		item := b.items[i]
+		if item.Weight <= weight {
+			weight -= item.Weight
+			fee += item.Fee
+			continue
+		}
+
+		if item.Weight > 0 {
+			// round up so bound stays an upper bound
+			fee += (item.Fee*weight + item.Weight - 1) / item.Weight
+		}
+		break
+	}
+	return fee
+}
+
+// indexes of items sorted by fee / weight desc, compared by cross multiplication to avoid float rounding
+func byFeeRate(items []Item) []int {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := items[order[a]], items[order[b]]
+		return x.Fee*y.Weight > y.Fee*x.Weight
+	})
+	return order
+}
@@ -0,0 +1,84 @@
+package miner_test
+
+import (
+	"math/rand"
+	"sob-miner/internal/miner"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Optimize", func() {
+	It("should beat greedy when best fee rate tx leaves a gap", func() {
+		// greedy takes 0 [rate 11] and can't fit 1 or 2, optimum is 1 + 2
+		items := []miner.Item{
+			{Fee: 660, Weight: 60},
+			{Fee: 500, Weight: 50},
+			{Fee: 500, Weight: 50},
+		}
+
+		_, greedyFee := miner.Greedy(items, 100, 100)
+		Expect(greedyFee).To(Equal(uint64(660)))
+
+		chosen, report := miner.Optimize(items, 100, 100, time.Second)
+		Expect(chosen).To(ConsistOf(1, 2))
+		Expect(report.GreedyFee).To(Equal(uint64(660)))
+		Expect(report.OptimizedFee).To(Equal(uint64(1000)))
+		Expect(report.Optimal).To(BeTrue())
+	})
+
+	It("should respect sigop budget", func() {
+		items := []miner.Item{
+			{Fee: 1000, Weight: 10, SigOpCost: 80},
+			{Fee: 600, Weight: 10, SigOpCost: 40},
+			{Fee: 600, Weight: 10, SigOpCost: 40},
+		}
+
+		chosen, report := miner.Optimize(items, 100, 80, time.Second)
+		Expect(chosen).To(ConsistOf(1, 2))
+		Expect(report.OptimizedFee).To(Equal(uint64(1200)))
+	})
+
+	It("should match exhaustive search on small random sets", func() {
+		r := rand.New(rand.NewSource(1))
+
+		for round := 0; round < 50; round++ {
+			items := make([]miner.Item, 12)
+			for i := range items {
+				items[i] = miner.Item{
+					Fee:       uint64(r.Intn(10_000)),
+					Weight:    uint64(1 + r.Intn(1_000)),
+					SigOpCost: uint64(r.Intn(20)),
+				}
+			}
+
+			weight, sigOps := uint64(2_500), uint64(60)
+
+			best := uint64(0)
+			for mask := 0; mask < 1<<len(items); mask++ {
+				w, s, f := uint64(0), uint64(0), uint64(0)
+				for i, item := range items {
+					if mask&(1<<i) != 0 {
+						w, s, f = w+item.Weight, s+item.SigOpCost, f+item.Fee
+					}
+				}
+				if w <= weight && s <= sigOps && f > best {
+					best = f
+				}
+			}
+
+			chosen, report := miner.Optimize(items, weight, sigOps, time.Second)
+			Expect(report.OptimizedFee).To(Equal(best))
+			Expect(report.OptimizedFee).To(BeNumerically(">=", report.GreedyFee))
+
+			w, s, f := uint64(0), uint64(0), uint64(0)
+			for _, i := range chosen {
+				w, s, f = w+items[i].Weight, s+items[i].SigOpCost, f+items[i].Fee
+			}
+			Expect(w).To(BeNumerically("<=", weight))
+			Expect(s).To(BeNumerically("<=", sigOps))
+			Expect(f).To(Equal(report.OptimizedFee))
+		}
+	})
+})
@@ -1,9 +1,43 @@
 package miner
 
-import "github.com/sirupsen/logrus"
+import (
+	config "sob-miner"
+	"sob-miner/internal/report"
+	"sob-miner/pkg/block"
+	"sob-miner/pkg/chaincfg"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
 
 type Opts struct {
 	Logger *logrus.Logger
 
 	MaxBlockSize uint
+
+	// network params, defaults to mainnet
+	Params *chaincfg.Params
+
+	// optional, when set block extends chain tip and uses its required difficulty
+	Chain *block.Chain
+
+	// coinbase payout addresses and weights, defaults to config.CoinbasePayouts of Params
+	Payouts []config.Payout
+
+	// height of mined block when Chain is not set, defaults to DefaultHeight
+	Height uint32
+
+	// weight and sigop cost kept free for header and coinbase while selecting txs,
+	// default to DefaultCoinbaseReservedWeight and DefaultCoinbaseReservedSigOps
+	CoinbaseReservedWeight uint64
+	CoinbaseReservedSigOps uint64
+
+	// when set, once best tx stops fitting rest of block is filled by branch and bound
+	// over OptimizeCandidates best txs within budget, bounded by OptimizeTimeout per round
+	Optimize           bool
+	OptimizeTimeout    time.Duration
+	OptimizeCandidates int
+
+	// optional, txs dropped while mining are reported to it
+	Rejections *report.Rejections
 }
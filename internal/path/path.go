@@ -9,8 +9,11 @@ var (
 	_, b, _, _      = runtime.Caller(0)
 	Root            = filepath.Join(filepath.Dir(b), "../../")
 	DBPath          = filepath.Join(Root, "test.db")
+	BoltDBPath      = filepath.Join(Root, "mempool.bolt")
+	MempoolDatPath  = filepath.Join(Root, "mempool.dat")
 	MempoolDataPath = filepath.Join(Root, "mempool")
 	OutFilePath     = filepath.Join(Root, "output.txt")
+	RejectionsPath  = filepath.Join(Root, "rejected.jsonl")
 
 	LocalRoot            = filepath.Join(Root, "../")
 	LocalDBPath          = filepath.Join(Root, "test.db")
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sob-miner/internal/ierrors"
+	"sync"
+)
+
+// Stage is where a tx was rejected
+type Stage string
+
+const (
+	StageRead     Stage = "read"
+	StageParse    Stage = "parse"
+	StageValidate Stage = "validate"
+	StageAdmit    Stage = "admit"
+	StageOrphan   Stage = "orphan"
+	StageMine     Stage = "mine"
+)
+
+// Rejection is a line of rejections report
+type Rejection struct {
+	Txid    string        `json:"txid,omitempty"`
+	File    string        `json:"file,omitempty"`
+	Stage   Stage         `json:"stage"`
+	Code    ierrors.Code  `json:"code"`
+	Class   ierrors.Class `json:"class,omitempty"`
+	Input   *int          `json:"input,omitempty"`
+	Message string        `json:"message"`
+}
+
+// NewRejection classifies err of tx txid read from file, either may be empty
+func NewRejection(stage Stage, file, txid string, err error) Rejection {
+	code := ierrors.CodeOf(err)
+	r := Rejection{
+		Txid:    txid,
+		File:    file,
+		Stage:   stage,
+		Code:    code,
+		Class:   code.Class(),
+		Message: err.Error(),
+	}
+
+	if input, ok := ierrors.InputOf(err); ok {
+		r.Input = &input
+	}
+	return r
+}
+
+// Rejections writes rejections as json lines, safe for concurrent use
+type Rejections struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func NewRejections(w io.Writer) *Rejections {
+	return &Rejections{w: w, enc: json.NewEncoder(w)}
+}
+
+// CreateRejections truncates report at path
+func CreateRejections(path string) (*Rejections, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRejections(f), nil
+}
+
+// AppendRejections adds to report at path, e.g. txs dropped by miner to what loader rejected
+func AppendRejections(path string) (*Rejections, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewRejections(f), nil
+}
+
+func (r *Rejections) Add(rejection Rejection) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.enc.Encode(rejection)
+}
+
+// Close closes underlying writer when it is a closer
+func (r *Rejections) Close() error {
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadRejections parses a report
+func ReadRejections(rd io.Reader) ([]Rejection, error) {
+	rejections := []Rejection{}
+
+	s := bufio.NewScanner(rd)
+	for s.Scan() {
+		var r Rejection
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		rejections = append(rejections, r)
+	}
+	return rejections, s.Err()
+}
@@ -0,0 +1,65 @@
+package report_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sob-miner/internal/ierrors"
+	"sob-miner/internal/report"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rejections", func() {
+	It("should classify rejections through wraps", func() {
+		err := fmt.Errorf("checking tx: %w", ierrors.RejectInput("", 2, ierrors.ErrInvalidSignature, "spending aa:1"))
+		Expect(err).To(MatchError(ierrors.ErrInvalidSignature))
+
+		var rejectErr *ierrors.RejectError
+		Expect(errors.As(err, &rejectErr)).To(BeTrue())
+		Expect(rejectErr.Code).To(Equal(ierrors.CodeScript))
+
+		r := report.NewRejection(report.StageValidate, "aa.json", "aa", err)
+		Expect(r.Code).To(Equal(ierrors.CodeScript))
+		Expect(r.Class).To(Equal(ierrors.Consensus))
+		Expect(*r.Input).To(Equal(2))
+		Expect(r.Message).To(Equal("checking tx: input 2: invalid signature: spending aa:1"))
+
+		r = report.NewRejection(report.StageAdmit, "", "", fmt.Errorf("%w: 26 txs", ierrors.ErrDescendantLimit))
+		Expect(r.Code).To(Equal(ierrors.CodeChainLimit))
+		Expect(r.Class).To(Equal(ierrors.Policy))
+		Expect(r.Input).To(BeNil())
+
+		// an explicit code wins over code of wrapped sentinel
+		r = report.NewRejection(report.StageValidate, "", "", ierrors.Reject(ierrors.CodeInBelowOut, ierrors.ErrFeeTooLow, ""))
+		Expect(r.Code).To(Equal(ierrors.CodeInBelowOut))
+		Expect(r.Class).To(Equal(ierrors.Consensus))
+
+		r = report.NewRejection(report.StageRead, "missing.json", "", os.ErrNotExist)
+		Expect(r.Code).To(Equal(ierrors.CodeUnknown))
+		Expect(r.Class).To(BeEmpty())
+	})
+
+	It("should write and read json lines", func() {
+		var buf bytes.Buffer
+		rejections := report.NewRejections(&buf)
+
+		written := []report.Rejection{
+			report.NewRejection(report.StageValidate, "a.json", "aa", ierrors.RejectInput("", 0, ierrors.ErrNonStandard, "")),
+			report.NewRejection(report.StageMine, "", "bb", ierrors.ErrUnexpectedWitness),
+		}
+		for _, r := range written {
+			Expect(rejections.Add(r)).To(Succeed())
+		}
+		Expect(rejections.Close()).To(Succeed())
+
+		Expect(bytes.Count(buf.Bytes(), []byte("\n"))).To(Equal(2))
+		Expect(buf.String()).To(HavePrefix(`{"txid":"aa","file":"a.json","stage":"validate","code":"non-standard","class":"policy","input":0,`))
+
+		read, err := report.ReadRejections(&buf)
+		Expect(err).To(BeNil())
+		Expect(read).To(Equal(written))
+	})
+})
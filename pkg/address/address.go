@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/chaincfg"
 	"sob-miner/pkg/transaction"
+	"strconv"
 	"strings"
 
 	"github.com/btcsuite/btcd/btcutil/bech32"
@@ -15,8 +17,8 @@ import (
 	"golang.org/x/crypto/ripemd160"
 )
 
-// encodes given script into address
-func EncodeAddress(scriptAsm string, script_type transaction.Type) (string, error) {
+// encodes given script into address for given network
+func EncodeAddress(scriptAsm string, script_type transaction.Type, params *chaincfg.Params) (string, error) {
 
 	script := strings.Split(scriptAsm, " ")
 	if len(script) == 0 {
@@ -43,7 +45,7 @@ func EncodeAddress(scriptAsm string, script_type transaction.Type) (string, erro
 			return "", err
 		}
 
-		return NewPayToPubKeyHash(pubkeyHash)
+		return NewPayToPubKeyHash(pubkeyHash, params)
 	case transaction.P2SH:
 		if len(script) != 4 {
 			return "", ierrors.ErrInvalidScript
@@ -54,7 +56,7 @@ func EncodeAddress(scriptAsm string, script_type transaction.Type) (string, erro
 			return "", err
 		}
 
-		return NewPayToScriptHashFromScriptHash(pubkeyHash)
+		return NewPayToScriptHashFromScriptHash(pubkeyHash, params)
 	case transaction.P2WPKH:
 		if len(script) != 3 {
 			return "", ierrors.ErrInvalidScript
@@ -65,7 +67,7 @@ func EncodeAddress(scriptAsm string, script_type transaction.Type) (string, erro
 			return "", err
 		}
 
-		return NewPayToWitnessPubKeyHash(pubkeyHash)
+		return NewPayToWitnessPubKeyHash(pubkeyHash, params)
 	case transaction.P2WSH:
 		if len(script) != 3 {
 			return "", ierrors.ErrInvalidScript
@@ -76,7 +78,7 @@ func EncodeAddress(scriptAsm string, script_type transaction.Type) (string, erro
 			return "", err
 		}
 
-		return NewPayToWitnessScriptHash(scriptHash)
+		return NewPayToWitnessScriptHash(scriptHash, params)
 	case transaction.P2TR:
 		if len(script) != 3 {
 			return "", ierrors.ErrInvalidScript
@@ -87,11 +89,27 @@ func EncodeAddress(scriptAsm string, script_type transaction.Type) (string, erro
 			return "", err
 		}
 
-		return NewPayToTaproot(witnessProg)
+		return NewPayToTaproot(witnessProg, params)
+	case transaction.WitnessUnknown:
+		if len(script) != 3 || !strings.HasPrefix(script[0], "OP_PUSHNUM_") {
+			return "", ierrors.ErrInvalidScript
+		}
+
+		version, err := strconv.Atoi(strings.TrimPrefix(script[0], "OP_PUSHNUM_"))
+		if err != nil {
+			return "", ierrors.ErrInvalidScript
+		}
+
+		witnessProg, err := hex.DecodeString(script[2])
+		if err != nil {
+			return "", err
+		}
+
+		return NewPayToWitnessUnknown(byte(version), witnessProg, params)
 	case transaction.OP_RETURN_TYPE:
 		return "", nil
 
-	case transaction.P2MS:
+	case transaction.P2MS, transaction.NonStandard:
 		return "", nil
 
 	default:
@@ -103,42 +121,54 @@ func NewPayToPubKey(pubkey []byte, compressed bool) (string, error) {
 	return hex.EncodeToString(pubkey), nil
 }
 
-func NewPayToPubKeyHash(pubkeyHash []byte) (string, error) {
+func NewPayToPubKeyHash(pubkeyHash []byte, params *chaincfg.Params) (string, error) {
 	if len(pubkeyHash) != ripemd160.Size {
 		return "", errors.New("pkHash must be 20 bytes")
 	}
-	return base58.CheckEncode(pubkeyHash[:ripemd160.Size], 0x00), nil
+	return base58.CheckEncode(pubkeyHash[:ripemd160.Size], params.PubKeyHashAddrID), nil
 }
 
-func NewPayToScriptHashFromScriptHash(scriptHash []byte) (string, error) {
+func NewPayToScriptHashFromScriptHash(scriptHash []byte, params *chaincfg.Params) (string, error) {
 	if len(scriptHash) != ripemd160.Size {
 		return "", errors.New("scriptHash must be 20 bytes")
 	}
-	return base58.CheckEncode(scriptHash[:ripemd160.Size], 0x05), nil
+	return base58.CheckEncode(scriptHash[:ripemd160.Size], params.ScriptHashAddrID), nil
 }
 
-func NewPayToWitnessPubKeyHash(witnessProg []byte) (string, error) {
+func NewPayToWitnessPubKeyHash(witnessProg []byte, params *chaincfg.Params) (string, error) {
 	if len(witnessProg) != 20 {
 		return "", errors.New("witness program must be 20 " +
 			"bytes for p2wpkh")
 	}
-	return encodeSegWitAddress("bc", 0x00, witnessProg)
+	return encodeSegWitAddress(params.Bech32HRPSegwit, 0x00, witnessProg)
 }
 
-func NewPayToWitnessScriptHash(witnessProg []byte) (string, error) {
+func NewPayToWitnessScriptHash(witnessProg []byte, params *chaincfg.Params) (string, error) {
 	if len(witnessProg) != 32 {
 		return "", errors.New("witness program must be 32 " +
 			"bytes for p2wsh")
 	}
-	return encodeSegWitAddress("bc", 0x00, witnessProg)
+	return encodeSegWitAddress(params.Bech32HRPSegwit, 0x00, witnessProg)
 }
 
-func NewPayToTaproot(tapscript []byte) (string, error) {
+func NewPayToTaproot(tapscript []byte, params *chaincfg.Params) (string, error) {
 	if len(tapscript) != 32 {
 		return "", errors.New("witness program must be 32 bytes for " +
 			"p2tr")
 	}
-	return encodeSegWitAddress("bc", 0x01, tapscript)
+	return encodeSegWitAddress(params.Bech32HRPSegwit, 0x01, tapscript)
+}
+
+// NewPayToWitnessUnknown encodes witness program of a future segwit version [1..16]
+// these are bech32m encoded like taproot (BIP350)
+func NewPayToWitnessUnknown(version byte, witnessProg []byte, params *chaincfg.Params) (string, error) {
+	if version < 1 || version > 16 {
+		return "", fmt.Errorf("witness version %d is not an upgradable version", version)
+	}
+	if len(witnessProg) < 2 || len(witnessProg) > 40 {
+		return "", errors.New("witness program must be 2 to 40 bytes")
+	}
+	return encodeSegWitAddress(params.Bech32HRPSegwit, version, witnessProg)
 }
 
 // TODO
@@ -169,46 +199,47 @@ func checksum(input []byte) (cksum [4]byte) {
 	return
 }
 
-func decodeSegWitAddress(address string) (byte, []byte, error) {
-	_, data, bech32version, err := bech32.DecodeGeneric(address)
+func decodeSegWitAddress(address string) (string, byte, []byte, error) {
+	hrp, data, bech32version, err := bech32.DecodeGeneric(address)
 	if err != nil {
-		return 0, nil, err
+		return "", 0, nil, err
 	}
 
 	if len(data) < 1 {
-		return 0, nil, fmt.Errorf("no witness version")
+		return "", 0, nil, fmt.Errorf("no witness version")
 	}
 
 	version := data[0]
 	if version > 16 {
-		return 0, nil, fmt.Errorf("invalid witness version: %v", version)
+		return "", 0, nil, fmt.Errorf("invalid witness version: %v", version)
 	}
 
 	regrouped, err := bech32.ConvertBits(data[1:], 5, 8, false)
 	if err != nil {
-		return 0, nil, err
+		return "", 0, nil, err
 	}
 
 	if len(regrouped) < 2 || len(regrouped) > 40 {
-		return 0, nil, fmt.Errorf("invalid data length")
+		return "", 0, nil, fmt.Errorf("invalid data length")
 	}
 
 	if version == 0 && len(regrouped) != 20 && len(regrouped) != 32 {
-		return 0, nil, fmt.Errorf("invalid data length for witness "+
+		return "", 0, nil, fmt.Errorf("invalid data length for witness "+
 			"version 0: %v", len(regrouped))
 	}
 
+	// BIP350: v0 uses bech32, v1+ uses bech32m
 	if version == 0 && bech32version != bech32.Version0 {
-		return 0, nil, fmt.Errorf("invalid checksum expected bech32 " +
+		return "", 0, nil, fmt.Errorf("invalid checksum expected bech32 " +
 			"encoding for address with witness version 0")
 	}
 
-	if version == 1 && bech32version != bech32.VersionM {
-		return 0, nil, fmt.Errorf("invalid checksum expected bech32m " +
-			"encoding for address with witness version 1")
+	if version >= 1 && bech32version != bech32.VersionM {
+		return "", 0, nil, fmt.Errorf("invalid checksum expected bech32m "+
+			"encoding for address with witness version %d", version)
 	}
 
-	return version, regrouped, nil
+	return hrp, version, regrouped, nil
 }
 
 func encodeSegWitAddress(hrp string, witnessVersion byte, witnessProgram []byte) (string, error) {
@@ -226,7 +257,7 @@ func encodeSegWitAddress(hrp string, witnessVersion byte, witnessProgram []byte)
 	case 0:
 		bech, err = bech32.Encode(hrp, combined)
 
-	case 1:
+	case 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16:
 		bech, err = bech32.EncodeM(hrp, combined)
 
 	default:
@@ -237,7 +268,7 @@ func encodeSegWitAddress(hrp string, witnessVersion byte, witnessProgram []byte)
 		return "", err
 	}
 
-	version, program, err := decodeSegWitAddress(bech)
+	_, version, program, err := decodeSegWitAddress(bech)
 	if err != nil {
 		return "", fmt.Errorf("invalid segwit address: %v", err)
 	}
@@ -248,3 +279,90 @@ func encodeSegWitAddress(hrp string, witnessVersion byte, witnessProgram []byte)
 
 	return bech, nil
 }
+
+// DecodeAddress decodes a base58 (p2pkh, p2sh) or bech32/bech32m (segwit) address
+// into its script type and the scriptPubKey paying to it.
+// address must belong to given network.
+func DecodeAddress(addr string, params *chaincfg.Params) (transaction.Type, []byte, error) {
+	// bech32 separator is last '1', base58 alphabet has no 'l' but has '1' so check hrp first
+	if sep := strings.LastIndexByte(addr, '1'); sep > 0 && isBech32Hrp(addr[:sep]) {
+		hrp, version, program, err := decodeSegWitAddress(addr)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %v", ierrors.ErrInvalidAddress, err)
+		}
+
+		if hrp != params.Bech32HRPSegwit {
+			return "", nil, ierrors.ErrWrongNetwork
+		}
+
+		var scriptType transaction.Type
+		switch {
+		case version == 0 && len(program) == 20:
+			scriptType = transaction.P2WPKH
+		case version == 0 && len(program) == 32:
+			scriptType = transaction.P2WSH
+		case version == 1 && len(program) == 32:
+			scriptType = transaction.P2TR
+		default:
+			// future segwit versions are valid destinations, spent as anyone-can-spend until soft forked
+			scriptType = transaction.WitnessUnknown
+		}
+
+		return scriptType, witnessScript(version, program), nil
+	}
+
+	payload, version, err := CheckDecode(addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ierrors.ErrInvalidAddress, err)
+	}
+
+	if len(payload) != ripemd160.Size {
+		return "", nil, ierrors.ErrInvalidAddress
+	}
+
+	switch version {
+	case params.PubKeyHashAddrID:
+		// OP_DUP OP_HASH160 OP_PUSHBYTES_20 <hash> OP_EQUALVERIFY OP_CHECKSIG
+		script := append([]byte{0x76, 0xa9, 0x14}, payload...)
+		return transaction.P2PKH, append(script, 0x88, 0xac), nil
+	case params.ScriptHashAddrID:
+		// OP_HASH160 OP_PUSHBYTES_20 <hash> OP_EQUAL
+		script := append([]byte{0xa9, 0x14}, payload...)
+		return transaction.P2SH, append(script, 0x87), nil
+	}
+
+	// valid base58 address of some other network
+	for _, other := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params} {
+		if version == other.PubKeyHashAddrID || version == other.ScriptHashAddrID {
+			return "", nil, ierrors.ErrWrongNetwork
+		}
+	}
+
+	return "", nil, ierrors.ErrInvalidAddress
+}
+
+// PayToAddrScript returns scriptPubKey paying to given address
+func PayToAddrScript(addr string, params *chaincfg.Params) ([]byte, error) {
+	_, script, err := DecodeAddress(addr, params)
+	return script, err
+}
+
+// known segwit hrps across networks, lowercased
+func isBech32Hrp(hrp string) bool {
+	switch strings.ToLower(hrp) {
+	case chaincfg.MainNetParams.Bech32HRPSegwit,
+		chaincfg.TestNet3Params.Bech32HRPSegwit,
+		chaincfg.RegressionNetParams.Bech32HRPSegwit:
+		return true
+	}
+	return false
+}
+
+// witness program script: OP_0 or OP_1..OP_16 followed by program push
+func witnessScript(version byte, program []byte) []byte {
+	versionOp := byte(0x00)
+	if version > 0 {
+		versionOp = 0x50 + version
+	}
+	return append([]byte{versionOp, byte(len(program))}, program...)
+}
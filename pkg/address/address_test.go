@@ -0,0 +1,59 @@
+package address_test
+
+import (
+	"encoding/hex"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/address"
+	"sob-miner/pkg/chaincfg"
+	"sob-miner/pkg/transaction"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Address", func() {
+	DescribeTable("DecodeAddress should return script type and scriptPubKey",
+		func(addr string, params *chaincfg.Params, scriptType transaction.Type, script string) {
+			decodedType, decodedScript, err := address.DecodeAddress(addr, params)
+			Expect(err).To(BeNil())
+			Expect(decodedType).To(Equal(scriptType))
+			Expect(hex.EncodeToString(decodedScript)).To(Equal(script))
+		},
+		Entry("p2pkh", "18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PX", &chaincfg.MainNetParams, transaction.P2PKH, "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac"),
+		Entry("p2sh", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", &chaincfg.MainNetParams, transaction.P2SH, "a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87"),
+		Entry("p2wpkh uppercase", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", &chaincfg.MainNetParams, transaction.P2WPKH, "0014751e76e8199196d454941c45d1b3a323f1433bd6"),
+		Entry("p2wsh testnet", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", &chaincfg.TestNet3Params, transaction.P2WSH, "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"),
+		Entry("p2tr", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", &chaincfg.MainNetParams, transaction.P2TR, "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+		Entry("witness v2", "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", &chaincfg.MainNetParams, transaction.WitnessUnknown, "5210751e76e8199196d454941c45d1b3a323"),
+		Entry("witness v16", "BC1SW50QGDZ25J", &chaincfg.MainNetParams, transaction.WitnessUnknown, "6002751e"),
+	)
+
+	DescribeTable("DecodeAddress should reject",
+		func(addr string, params *chaincfg.Params, expected error) {
+			_, _, err := address.DecodeAddress(addr, params)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("bech32m checksum for v0", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", &chaincfg.MainNetParams, ierrors.ErrInvalidAddress),
+		Entry("bech32 checksum for v1", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", &chaincfg.MainNetParams, ierrors.ErrInvalidAddress),
+		Entry("bad base58 checksum", "18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PY", &chaincfg.MainNetParams, ierrors.ErrInvalidAddress),
+		Entry("testnet segwit on mainnet", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", &chaincfg.MainNetParams, ierrors.ErrWrongNetwork),
+		Entry("mainnet p2pkh on regtest", "18cBEMRxXHqzWWCxZNtU91F5sbUNKhL5PX", &chaincfg.RegressionNetParams, ierrors.ErrWrongNetwork),
+	)
+
+	It("should encode future witness versions with bech32m", func() {
+		asm := "OP_PUSHNUM_2 OP_PUSHBYTES_16 751e76e8199196d454941c45d1b3a323"
+		addr, err := address.EncodeAddress(asm, transaction.WitnessUnknown, &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(addr).To(Equal("bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"))
+	})
+
+	It("should round trip EncodeAddress", func() {
+		asm := "OP_0 OP_PUSHBYTES_20 751e76e8199196d454941c45d1b3a323f1433bd6"
+		addr, err := address.EncodeAddress(asm, transaction.P2WPKH, &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+
+		_, script, err := address.DecodeAddress(addr, &chaincfg.MainNetParams)
+		Expect(err).To(BeNil())
+		Expect(hex.EncodeToString(script)).To(Equal("0014751e76e8199196d454941c45d1b3a323f1433bd6"))
+	})
+})
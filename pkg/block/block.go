@@ -1,7 +1,9 @@
 package block
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
+	"math/big"
 	"sob-miner/pkg/encoding"
 )
 
@@ -32,6 +34,37 @@ func (bh *BlocKHeader) Serialize() []byte {
 	return serializedHeader.GetBuffer()
 }
 
+// Target returns the 256 bit target encoded in NBits
+func (bh *BlocKHeader) Target() *big.Int {
+	return CompactToBig(bh.NBits)
+}
+
+// BlockHash returns HASH256 of serialized header in natural byte order
+func (bh *BlocKHeader) BlockHash() []byte {
+	first := sha256.Sum256(bh.Serialize())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// Hash returns block hash hex in RPC (reversed) byte order,
+// same order used by PreviousBlockHash
+func (bh *BlocKHeader) Hash() string {
+	hash := bh.BlockHash()
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash)
+}
+
+// CheckProofOfWork reports whether header hash is below the target derived from NBits
+func (bh *BlocKHeader) CheckProofOfWork() bool {
+	target := bh.Target()
+	if target.Sign() <= 0 {
+		return false
+	}
+	return HashToBig(bh.BlockHash()).Cmp(target) < 0
+}
+
 func HexMustDecode(hexStr string) []byte {
 	b, err := hex.DecodeString(hexStr)
 	if err != nil {
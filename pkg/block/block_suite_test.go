@@ -0,0 +1,13 @@
+package block_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBlock(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Block Suite")
+}
@@ -0,0 +1,108 @@
+package block_test
+
+import (
+	"fmt"
+	"math/big"
+	"sob-miner/pkg/block"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Block", func() {
+	Context("Test Compact Target", func() {
+		It("should decode assignment difficulty", func() {
+			Expect(fmt.Sprintf("%064x", block.CompactToBig(0x1f00ffff))).To(Equal("0000ffff00000000000000000000000000000000000000000000000000000000"))
+			Expect(fmt.Sprintf("%064x", block.CompactToBig(0x1d00ffff))).To(Equal("00000000ffff0000000000000000000000000000000000000000000000000000"))
+		})
+
+		It("should round trip compact encoding", func() {
+			for _, bits := range []uint32{0x1d00ffff, 0x1f00ffff, 0x207fffff, 0x1b0404cb, 0x17034219, 0x03123456} {
+				Expect(block.BigToCompact(block.CompactToBig(bits))).To(Equal(bits))
+			}
+		})
+
+		It("should move sign bit into exponent", func() {
+			Expect(block.BigToCompact(big.NewInt(0x80))).To(Equal(uint32(0x02008000)))
+		})
+	})
+
+	Context("Test Retarget", func() {
+		rules := block.RetargetRules{
+			PowLimit:                 block.CompactToBig(0x207fffff),
+			PowLimitBits:             0x207fffff,
+			TargetTimespan:           40 * time.Minute,
+			TargetTimePerBlock:       10 * time.Minute,
+			RetargetAdjustmentFactor: 4,
+		}
+
+		mineOn := func(chain *block.Chain, spacing uint32) block.BlocKHeader {
+			tip := chain.Tip()
+			header := block.BlocKHeader{
+				Version:           4,
+				PreviousBlockHash: tip.Hash(),
+				MerkleRoot:        "0000000000000000000000000000000000000000000000000000000000000000",
+				TimeStamp:         tip.TimeStamp + spacing,
+			}
+			header.NBits = chain.NextWorkRequired(header.TimeStamp)
+			for !header.CheckProofOfWork() {
+				header.Nonce++
+			}
+			Expect(chain.Append(header)).To(Succeed())
+			return header
+		}
+
+		It("should make difficulty harder when blocks come fast", func() {
+			genesis := block.BlocKHeader{
+				Version:           1,
+				PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000000",
+				MerkleRoot:        "0000000000000000000000000000000000000000000000000000000000000000",
+				TimeStamp:         1296688602,
+				NBits:             0x1f7fffff,
+			}
+			chain := block.NewChain(genesis, rules)
+
+			// 3 blocks 100s apart complete first interval of 4
+			for i := 0; i < 3; i++ {
+				Expect(mineOn(chain, 100).NBits).To(Equal(uint32(0x1f7fffff)))
+			}
+
+			// timespan 300s clamped to 600s => target / 4
+			next := chain.NextWorkRequired(chain.Tip().TimeStamp + 100)
+			expected := new(big.Int).Div(block.CompactToBig(0x1f7fffff), big.NewInt(4))
+			Expect(next).To(Equal(block.BigToCompact(expected)))
+		})
+
+		It("should allow min difficulty after long gap when enabled", func() {
+			minDiffRules := rules
+			minDiffRules.ReduceMinDifficulty = true
+			minDiffRules.MinDiffReductionTime = 20 * time.Minute
+
+			genesis := block.BlocKHeader{
+				PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000000",
+				MerkleRoot:        "0000000000000000000000000000000000000000000000000000000000000000",
+				NBits:             0x1f7fffff,
+			}
+			chain := block.NewChain(genesis, minDiffRules)
+
+			Expect(chain.NextWorkRequired(genesis.TimeStamp + 600)).To(Equal(uint32(0x1f7fffff)))
+			Expect(chain.NextWorkRequired(genesis.TimeStamp + 1201)).To(Equal(uint32(0x207fffff)))
+		})
+
+		It("should reject header with wrong bits", func() {
+			genesis := block.BlocKHeader{
+				PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000000",
+				MerkleRoot:        "0000000000000000000000000000000000000000000000000000000000000000",
+				NBits:             0x207fffff,
+			}
+			chain := block.NewChain(genesis, rules)
+			header := block.BlocKHeader{
+				PreviousBlockHash: genesis.Hash(),
+				MerkleRoot:        "0000000000000000000000000000000000000000000000000000000000000000",
+				NBits:             0x1f00ffff,
+			}
+			Expect(chain.Append(header)).To(MatchError(block.ErrBadDifficultyBits))
+		})
+	})
+})
@@ -0,0 +1,169 @@
+package block
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPrevBlockMismatch = errors.New("previous block hash does not match chain tip")
+	ErrBadDifficultyBits = errors.New("block nbits does not match required difficulty")
+	ErrHighHash          = errors.New("block hash is higher than target")
+)
+
+// RetargetRules holds consensus parameters for difficulty adjustment
+type RetargetRules struct {
+	// highest allowed target and its compact form
+	PowLimit     *big.Int
+	PowLimitBits uint32
+
+	TargetTimespan     time.Duration // 14 days on mainnet
+	TargetTimePerBlock time.Duration // 10 minutes on mainnet
+
+	// actual timespan is clamped to [TargetTimespan / factor, TargetTimespan * factor]
+	RetargetAdjustmentFactor int64
+
+	// testnet/regtest: allow min difficulty block if no block was found for 2*TargetTimePerBlock
+	ReduceMinDifficulty  bool
+	MinDiffReductionTime time.Duration
+
+	// regtest: difficulty never changes
+	NoRetargeting bool
+}
+
+// BlocksPerRetarget returns number of blocks between difficulty adjustments (2016 on mainnet)
+func (r RetargetRules) BlocksPerRetarget() uint32 {
+	return uint32(r.TargetTimespan / r.TargetTimePerBlock)
+}
+
+// Chain is a locally maintained header chain, height 0 is its first header.
+// it is used to derive next block's previous hash, height and required nBits.
+type Chain struct {
+	rules   RetargetRules
+	headers []BlocKHeader
+
+	mu sync.RWMutex
+}
+
+func NewChain(genesis BlocKHeader, rules RetargetRules) *Chain {
+	return &Chain{
+		rules:   rules,
+		headers: []BlocKHeader{genesis},
+	}
+}
+
+func (c *Chain) Rules() RetargetRules {
+	return c.rules
+}
+
+// Height returns height of chain tip
+func (c *Chain) Height() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return uint32(len(c.headers) - 1)
+}
+
+func (c *Chain) Tip() BlocKHeader {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.headers[len(c.headers)-1]
+}
+
+func (c *Chain) HeaderAt(height uint32) (BlocKHeader, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if int(height) >= len(c.headers) {
+		return BlocKHeader{}, false
+	}
+	return c.headers[height], true
+}
+
+// Append connects header to chain tip after checking its link, nBits and proof of work
+func (c *Chain) Append(header BlocKHeader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tip := c.headers[len(c.headers)-1]
+	if header.PreviousBlockHash != tip.Hash() {
+		return ErrPrevBlockMismatch
+	}
+
+	if header.NBits != c.nextWorkRequired(header.TimeStamp) {
+		return ErrBadDifficultyBits
+	}
+
+	if !header.CheckProofOfWork() {
+		return ErrHighHash
+	}
+
+	c.headers = append(c.headers, header)
+	return nil
+}
+
+// NextWorkRequired returns nBits for a block extending current tip with given timestamp
+func (c *Chain) NextWorkRequired(timestamp uint32) uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.nextWorkRequired(timestamp)
+}
+
+// port of bitcoin core's GetNextWorkRequired + CalculateNextWorkRequired
+func (c *Chain) nextWorkRequired(timestamp uint32) uint32 {
+	tipHeight := uint32(len(c.headers) - 1)
+	tip := c.headers[tipHeight]
+	interval := c.rules.BlocksPerRetarget()
+
+	// not a retarget block
+	if (tipHeight+1)%interval != 0 {
+		if !c.rules.ReduceMinDifficulty {
+			return tip.NBits
+		}
+
+		// special testnet rule, block is allowed min difficulty if it took more than 2x spacing
+		minDiffTime := uint32(c.rules.MinDiffReductionTime / time.Second)
+		if timestamp > tip.TimeStamp+minDiffTime {
+			return c.rules.PowLimitBits
+		}
+
+		// else return last non min difficulty nBits
+		height := tipHeight
+		for height > 0 && height%interval != 0 && c.headers[height].NBits == c.rules.PowLimitBits {
+			height--
+		}
+		return c.headers[height].NBits
+	}
+
+	if c.rules.NoRetargeting {
+		return tip.NBits
+	}
+
+	first := c.headers[tipHeight-(interval-1)]
+
+	targetTimespan := int64(c.rules.TargetTimespan / time.Second)
+	actualTimespan := int64(tip.TimeStamp) - int64(first.TimeStamp)
+
+	minTimespan := targetTimespan / c.rules.RetargetAdjustmentFactor
+	maxTimespan := targetTimespan * c.rules.RetargetAdjustmentFactor
+	if actualTimespan < minTimespan {
+		actualTimespan = minTimespan
+	} else if actualTimespan > maxTimespan {
+		actualTimespan = maxTimespan
+	}
+
+	// newTarget = oldTarget * actualTimespan / targetTimespan
+	newTarget := CompactToBig(tip.NBits)
+	newTarget.Mul(newTarget, big.NewInt(actualTimespan))
+	newTarget.Div(newTarget, big.NewInt(targetTimespan))
+
+	if newTarget.Cmp(c.rules.PowLimit) > 0 {
+		newTarget.Set(c.rules.PowLimit)
+	}
+
+	return BigToCompact(newTarget)
+}
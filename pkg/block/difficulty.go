@@ -0,0 +1,92 @@
+package block
+
+import (
+	"math/big"
+)
+
+var (
+	bigOne = big.NewInt(1)
+
+	// 2^256, used to compute work from a target
+	oneLsh256 = new(big.Int).Lsh(bigOne, 256)
+)
+
+// CompactToBig converts compact nBits representation into a target.
+//
+// compact format is a 256 bit number encoded as
+//   - 1 byte exponent (number of bytes of the mantissa)
+//   - 3 bytes mantissa, where 0x00800000 is the sign bit
+//
+// N = (-1^sign) * mantissa * 256^(exponent-3)
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+
+	return bn
+}
+
+// BigToCompact converts a target into its compact nBits representation.
+// it is the inverse of CompactToBig, precision beyond 3 bytes of mantissa is lost.
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(n.Bits()[0])
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tn := new(big.Int).Set(n)
+		mantissa = uint32(tn.Rsh(tn, 8*(exponent-3)).Bits()[0])
+	}
+
+	// mantissa sign bit is set, shift it into exponent so number stays positive
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// CalcWork returns expected number of hashes required to find a block with given nBits.
+// work = 2^256 / (target+1)
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	denominator := new(big.Int).Add(target, bigOne)
+	return new(big.Int).Div(oneLsh256, denominator)
+}
+
+// HashToBig interprets a block hash (natural byte order, as produced by double sha256)
+// as a little endian 256 bit number so it can be compared with a target.
+func HashToBig(hash []byte) *big.Int {
+	buf := make([]byte, len(hash))
+	for i := range hash {
+		buf[len(hash)-1-i] = hash[i]
+	}
+	return new(big.Int).SetBytes(buf)
+}
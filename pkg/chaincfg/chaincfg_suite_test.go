@@ -0,0 +1,13 @@
+package chaincfg_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChaincfg(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Chaincfg Suite")
+}
@@ -0,0 +1,200 @@
+package chaincfg
+
+import (
+	"errors"
+	"math/big"
+	"sob-miner/pkg/block"
+	"time"
+)
+
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// Params defines a bitcoin network by its consensus rules and encodings
+type Params struct {
+	Name string
+
+	// genesis block header and its hash in RPC byte order
+	GenesisBlock block.BlocKHeader
+	GenesisHash  string
+
+	// proof of work limit and difficulty adjustment rules
+	Retarget block.RetargetRules
+
+	// blocks between subsidy halvings
+	SubsidyReductionInterval uint32
+
+	// height at which segwit rules are enforced
+	SegwitHeight uint32
+
+	// address encoding
+	Bech32HRPSegwit  string
+	PubKeyHashAddrID byte
+	ScriptHashAddrID byte
+}
+
+// IsSegwitActive reports whether block at given height enforces segwit
+func (p *Params) IsSegwitActive(height uint32) bool {
+	return height >= p.SegwitHeight
+}
+
+// base block subsidy before any halving, 50 BTC
+const BaseSubsidy uint64 = 50 * 100_000_000
+
+// BlockSubsidy returns block reward in satoshis for block at given height,
+// subsidy halves every SubsidyReductionInterval blocks and is 0 after 64 halvings
+func (p *Params) BlockSubsidy(height uint32) uint64 {
+	halvings := height / p.SubsidyReductionInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return BaseSubsidy >> halvings
+}
+
+const genesisMerkleRoot = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+const zeroHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func powLimit(bits uint32) *big.Int {
+	return block.CompactToBig(bits)
+}
+
+var MainNetParams = Params{
+	Name: "mainnet",
+
+	GenesisBlock: block.BlocKHeader{
+		Version:           1,
+		PreviousBlockHash: zeroHash,
+		MerkleRoot:        genesisMerkleRoot,
+		TimeStamp:         1231006505,
+		NBits:             0x1d00ffff,
+		Nonce:             2083236893,
+	},
+	GenesisHash: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+
+	Retarget: block.RetargetRules{
+		PowLimit:                 powLimit(0x1d00ffff),
+		PowLimitBits:             0x1d00ffff,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetTimePerBlock:       10 * time.Minute,
+		RetargetAdjustmentFactor: 4,
+	},
+
+	SubsidyReductionInterval: 210_000,
+	SegwitHeight:             481_824,
+
+	Bech32HRPSegwit:  "bc",
+	PubKeyHashAddrID: 0x00,
+	ScriptHashAddrID: 0x05,
+}
+
+var TestNet3Params = Params{
+	Name: "testnet3",
+
+	GenesisBlock: block.BlocKHeader{
+		Version:           1,
+		PreviousBlockHash: zeroHash,
+		MerkleRoot:        genesisMerkleRoot,
+		TimeStamp:         1296688602,
+		NBits:             0x1d00ffff,
+		Nonce:             414098458,
+	},
+	GenesisHash: "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+
+	Retarget: block.RetargetRules{
+		PowLimit:                 powLimit(0x1d00ffff),
+		PowLimitBits:             0x1d00ffff,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetTimePerBlock:       10 * time.Minute,
+		RetargetAdjustmentFactor: 4,
+		ReduceMinDifficulty:      true,
+		MinDiffReductionTime:     20 * time.Minute,
+	},
+
+	SubsidyReductionInterval: 210_000,
+	SegwitHeight:             834_624,
+
+	Bech32HRPSegwit:  "tb",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+}
+
+var SigNetParams = Params{
+	Name: "signet",
+
+	GenesisBlock: block.BlocKHeader{
+		Version:           1,
+		PreviousBlockHash: zeroHash,
+		MerkleRoot:        genesisMerkleRoot,
+		TimeStamp:         1598918400,
+		NBits:             0x1e0377ae,
+		Nonce:             52613770,
+	},
+	GenesisHash: "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6",
+
+	Retarget: block.RetargetRules{
+		PowLimit:                 powLimit(0x1e0377ae),
+		PowLimitBits:             0x1e0377ae,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetTimePerBlock:       10 * time.Minute,
+		RetargetAdjustmentFactor: 4,
+	},
+
+	SubsidyReductionInterval: 210_000,
+	SegwitHeight:             1,
+
+	Bech32HRPSegwit:  "tb",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+}
+
+var RegressionNetParams = Params{
+	Name: "regtest",
+
+	GenesisBlock: block.BlocKHeader{
+		Version:           1,
+		PreviousBlockHash: zeroHash,
+		MerkleRoot:        genesisMerkleRoot,
+		TimeStamp:         1296688602,
+		NBits:             0x207fffff,
+		Nonce:             2,
+	},
+	GenesisHash: "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+
+	Retarget: block.RetargetRules{
+		PowLimit:                 powLimit(0x207fffff),
+		PowLimitBits:             0x207fffff,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetTimePerBlock:       10 * time.Minute,
+		RetargetAdjustmentFactor: 4,
+		ReduceMinDifficulty:      true,
+		MinDiffReductionTime:     20 * time.Minute,
+		NoRetargeting:            true,
+	},
+
+	SubsidyReductionInterval: 150,
+	SegwitHeight:             0,
+
+	Bech32HRPSegwit:  "bcrt",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+}
+
+// ParamsByName returns network params for mainnet, testnet3, signet or regtest
+func ParamsByName(name string) (*Params, error) {
+	switch name {
+	case MainNetParams.Name, "main":
+		return &MainNetParams, nil
+	case TestNet3Params.Name, "testnet", "test":
+		return &TestNet3Params, nil
+	case SigNetParams.Name:
+		return &SigNetParams, nil
+	case RegressionNetParams.Name:
+		return &RegressionNetParams, nil
+	default:
+		return nil, ErrUnknownNetwork
+	}
+}
+
+// NewChain returns a local chain which starts at network's genesis block
+func (p *Params) NewChain() *block.Chain {
+	return block.NewChain(p.GenesisBlock, p.Retarget)
+}
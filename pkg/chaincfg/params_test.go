@@ -0,0 +1,43 @@
+package chaincfg_test
+
+import (
+	"sob-miner/pkg/chaincfg"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Params", func() {
+	DescribeTable("genesis block should hash to GenesisHash",
+		func(name string) {
+			params, err := chaincfg.ParamsByName(name)
+			Expect(err).To(BeNil())
+
+			genesis := params.GenesisBlock
+			Expect(genesis.Hash()).To(Equal(params.GenesisHash))
+			Expect(genesis.CheckProofOfWork()).To(BeTrue())
+		},
+		Entry("mainnet", "mainnet"),
+		Entry("testnet3", "testnet3"),
+		Entry("signet", "signet"),
+		Entry("regtest", "regtest"),
+	)
+
+	It("should reject unknown network", func() {
+		_, err := chaincfg.ParamsByName("litecoin")
+		Expect(err).To(MatchError(chaincfg.ErrUnknownNetwork))
+	})
+
+	DescribeTable("block subsidy should follow halving schedule",
+		func(params *chaincfg.Params, height uint32, subsidy uint64) {
+			Expect(params.BlockSubsidy(height)).To(Equal(subsidy))
+		},
+		Entry("genesis", &chaincfg.MainNetParams, uint32(0), uint64(50_0000_0000)),
+		Entry("before first halving", &chaincfg.MainNetParams, uint32(209_999), uint64(50_0000_0000)),
+		Entry("first halving", &chaincfg.MainNetParams, uint32(210_000), uint64(25_0000_0000)),
+		Entry("assignment height", &chaincfg.MainNetParams, uint32(835_944), uint64(6_2500_0000)),
+		Entry("fourth halving", &chaincfg.MainNetParams, uint32(840_000), uint64(3_1250_0000)),
+		Entry("after 64 halvings", &chaincfg.MainNetParams, uint32(64*210_000), uint64(0)),
+		Entry("regtest halving", &chaincfg.RegressionNetParams, uint32(150), uint64(25_0000_0000)),
+	)
+})
@@ -2,7 +2,9 @@ package encoding
 
 import (
 	"encoding/binary"
+	"io"
 	"math"
+	"sob-miner/internal/ierrors"
 )
 
 func CompactSize(val uint64) []byte {
@@ -30,3 +32,37 @@ func CompactSize(val uint64) []byte {
 
 	return buf
 }
+
+// ReadCompactSize reads a CompactSize from r, rejecting encodings longer than needed
+func ReadCompactSize(r io.Reader) (uint64, error) {
+	var prefix [1]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return 0, err
+	}
+
+	// size of value and smallest value which needs this encoding
+	var size int
+	var min uint64
+	switch prefix[0] {
+	case 0xfd:
+		size, min = 2, 0xfd
+	case 0xfe:
+		size, min = 4, math.MaxUint16+1
+	case 0xff:
+		size, min = 8, math.MaxUint32+1
+	default:
+		return uint64(prefix[0]), nil
+	}
+
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf[:size]); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	val := binary.LittleEndian.Uint64(buf)
+
+	if val < min {
+		return 0, ierrors.ErrNonCanonicalCompactSize
+	}
+
+	return val, nil
+}
@@ -0,0 +1,64 @@
+package encoding_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"sob-miner/internal/corpus"
+	"sob-miner/pkg/encoding"
+	"testing"
+)
+
+// FuzzCompactSize encodes a value and reads it back
+func FuzzCompactSize(f *testing.F) {
+	for _, val := range []uint64{0, 0xfc, 0xfd, math.MaxUint16, math.MaxUint16 + 1, math.MaxUint32, math.MaxUint32 + 1, math.MaxUint64} {
+		f.Add(val)
+	}
+
+	// sizes of dataset scripts
+	scripts, err := corpus.Scripts()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, script := range scripts {
+		f.Add(uint64(len(script)))
+	}
+
+	f.Fuzz(func(t *testing.T, val uint64) {
+		raw := encoding.CompactSize(val)
+
+		r := bytes.NewReader(raw)
+		read, err := encoding.ReadCompactSize(r)
+		if err != nil {
+			t.Fatalf("%x: %v", raw, err)
+		}
+		if read != val || r.Len() != 0 {
+			t.Fatalf("%d encoded as %x reads %d with %d bytes left", val, raw, read, r.Len())
+		}
+	})
+}
+
+// FuzzReadCompactSize reads arbitrary bytes, a value read encodes back to bytes it was read from
+func FuzzReadCompactSize(f *testing.F) {
+	// canonical, non canonical and truncated encodings
+	for _, s := range []string{"", "00", "fc", "fdfd00", "fdfc00", "fe00000100", "feffff0000", "ff0000000001000000", "ff", "fe0100"} {
+		raw, err := hex.DecodeString(s)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(raw)
+	}
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		r := bytes.NewReader(raw)
+		val, err := encoding.ReadCompactSize(r)
+		if err != nil {
+			return
+		}
+
+		read := raw[:len(raw)-r.Len()]
+		if encoded := encoding.CompactSize(val); !bytes.Equal(encoded, read) {
+			t.Fatalf("%x reads %d which encodes as %x", read, val, encoded)
+		}
+	})
+}
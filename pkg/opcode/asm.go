@@ -0,0 +1,151 @@
+package opcode
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sob-miner/internal/ierrors"
+	"strings"
+)
+
+// canonical ASM name of every opcode, same dialect as mempool json
+// (OP_0, OP_PUSHBYTES_n, OP_PUSHNUM_n, OP_CLTV, OP_CSV, OP_RETURN_n for unassigned)
+var opCodeNames [256]string
+
+// ASM names accepted by Assemble, OpCodeMap plus dialect specific aliases
+var asmOpCodes = map[string]byte{}
+
+func init() {
+	for name, code := range OpCodeMap {
+		asmOpCodes[name] = code
+		if opCodeNames[code] == "" || len(name) < len(opCodeNames[code]) {
+			opCodeNames[code] = name
+		}
+	}
+
+	opCodeNames[OP_0] = "OP_0"
+	opCodeNames[OP_1NEGATE] = "OP_PUSHNUM_NEG1"
+	for n := 1; n <= 16; n++ {
+		opCodeNames[OP_1+n-1] = fmt.Sprintf("OP_PUSHNUM_%d", n)
+	}
+	opCodeNames[OP_CHECKLOCKTIMEVERIFY] = "OP_CLTV"
+	opCodeNames[OP_CHECKSEQUENCEVERIFY] = "OP_CSV"
+
+	// unassigned opcodes after OP_CHECKSIGADD make script fail like OP_RETURN
+	for code := OP_CHECKSIGADD + 1; code < OP_INVALIDOPCODE; code++ {
+		opCodeNames[code] = fmt.Sprintf("OP_RETURN_%d", code)
+	}
+
+	for code, name := range opCodeNames {
+		asmOpCodes[name] = byte(code)
+	}
+}
+
+// Name returns canonical ASM name of opcode
+func Name(op byte) string {
+	return opCodeNames[op]
+}
+
+// Disassemble converts raw script into ASM.
+// pushes are printed as push opcode followed by pushed data in hex,
+// OP_PUSHDATA length prefixes are implied by the data and not printed.
+func Disassemble(script []byte) (string, error) {
+	tokens := []string{}
+
+	for pc := 0; pc < len(script); {
+		op := script[pc]
+		pc++
+
+		dataLen := 0
+		switch {
+		case op >= OP_PUSHBYTES_1 && op <= OP_PUSHBYTES_75:
+			dataLen = int(op)
+		case op == OP_PUSHDATA1:
+			if pc+1 > len(script) {
+				return "", ierrors.ErrMalformedPush
+			}
+			dataLen = int(script[pc])
+			pc++
+		case op == OP_PUSHDATA2:
+			if pc+2 > len(script) {
+				return "", ierrors.ErrMalformedPush
+			}
+			dataLen = int(binary.LittleEndian.Uint16(script[pc:]))
+			pc += 2
+		case op == OP_PUSHDATA4:
+			if pc+4 > len(script) {
+				return "", ierrors.ErrMalformedPush
+			}
+			dataLen = int(binary.LittleEndian.Uint32(script[pc:]))
+			pc += 4
+		}
+
+		tokens = append(tokens, Name(op))
+
+		if op >= OP_PUSHBYTES_1 && op <= OP_PUSHDATA4 {
+			if dataLen < 0 || pc+dataLen > len(script) {
+				return "", ierrors.ErrMalformedPush
+			}
+			tokens = append(tokens, hex.EncodeToString(script[pc:pc+dataLen]))
+			pc += dataLen
+		}
+	}
+
+	return strings.Join(tokens, " "), nil
+}
+
+// Assemble converts ASM back into raw script, writing push lengths
+// for OP_PUSHDATA1/2/4 from the size of data that follows them.
+func Assemble(asm string) ([]byte, error) {
+	tokens := strings.Fields(asm)
+	script := []byte{}
+
+	for i := 0; i < len(tokens); i++ {
+		op, ok := asmOpCodes[tokens[i]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ierrors.ErrInvalidOpCode, tokens[i])
+		}
+		script = append(script, op)
+
+		if op < OP_PUSHBYTES_1 || op > OP_PUSHDATA4 {
+			continue
+		}
+
+		// push opcodes must be followed by data, empty data of OP_PUSHDATA1/2/4 has no token
+		var data []byte
+		if op < OP_PUSHDATA1 || i+1 < len(tokens) && !strings.HasPrefix(tokens[i+1], "OP_") {
+			i++
+			if i >= len(tokens) {
+				return nil, ierrors.ErrMalformedPush
+			}
+
+			var err error
+			if data, err = hex.DecodeString(tokens[i]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ierrors.ErrMalformedPush, err)
+			}
+		}
+
+		switch op {
+		case OP_PUSHDATA1:
+			if len(data) > 0xff {
+				return nil, ierrors.ErrMalformedPush
+			}
+			script = append(script, byte(len(data)))
+		case OP_PUSHDATA2:
+			if len(data) > 0xffff {
+				return nil, ierrors.ErrMalformedPush
+			}
+			script = binary.LittleEndian.AppendUint16(script, uint16(len(data)))
+		case OP_PUSHDATA4:
+			script = binary.LittleEndian.AppendUint32(script, uint32(len(data)))
+		default:
+			if len(data) != int(op) {
+				return nil, fmt.Errorf("%w: %s expects %d bytes got %d", ierrors.ErrMalformedPush, tokens[i-1], op, len(data))
+			}
+		}
+
+		script = append(script, data...)
+	}
+
+	return script, nil
+}
@@ -0,0 +1,70 @@
+package opcode_test
+
+import (
+	"encoding/hex"
+	"sob-miner/internal/ierrors"
+	"sob-miner/pkg/opcode"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Asm", func() {
+	DescribeTable("should disassemble and assemble back",
+		func(scriptHex string, asm string) {
+			script, err := hex.DecodeString(scriptHex)
+			Expect(err).To(BeNil())
+
+			disassembled, err := opcode.Disassemble(script)
+			Expect(err).To(BeNil())
+			Expect(disassembled).To(Equal(asm))
+
+			assembled, err := opcode.Assemble(asm)
+			Expect(err).To(BeNil())
+			Expect(hex.EncodeToString(assembled)).To(Equal(scriptHex))
+		},
+		Entry("p2pkh", "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac",
+			"OP_DUP OP_HASH160 OP_PUSHBYTES_20 536ffa992491508dca0354e52f32a3a7a679a53a OP_EQUALVERIFY OP_CHECKSIG"),
+		Entry("p2tr", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			"OP_PUSHNUM_1 OP_PUSHBYTES_32 79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+		Entry("op_return pushdata1", "6a4c03aabbcc", "OP_RETURN OP_PUSHDATA1 aabbcc"),
+		Entry("pushdata2", "4d0300aabbcc", "OP_PUSHDATA2 aabbcc"),
+		Entry("timelock", "0350c30cb175", "OP_PUSHBYTES_3 50c30c OP_CLTV OP_DROP"),
+		Entry("negative one and unassigned", "4fbb", "OP_PUSHNUM_NEG1 OP_RETURN_187"),
+		Entry("empty", "", ""),
+	)
+
+	It("should assemble empty OP_PUSHDATA pushes back", func() {
+		for _, scriptHex := range []string{"4c00", "4d0000", "4e00000000", "4c0075"} {
+			script, err := hex.DecodeString(scriptHex)
+			Expect(err).To(BeNil())
+
+			// empty data has no token
+			asm, err := opcode.Disassemble(script)
+			Expect(err).To(BeNil())
+
+			assembled, err := opcode.Assemble(asm)
+			Expect(err).To(BeNil())
+			Expect(assembled).To(Equal(script))
+		}
+
+		// OP_PUSHBYTES_n still needs its data
+		_, err := opcode.Assemble("OP_PUSHBYTES_1 OP_DROP")
+		Expect(err).To(MatchError(ierrors.ErrMalformedPush))
+	})
+
+	It("should reject truncated push", func() {
+		_, err := opcode.Disassemble([]byte{0x4c, 0x05, 0x01})
+		Expect(err).To(MatchError(ierrors.ErrMalformedPush))
+	})
+
+	It("should reject push length mismatch", func() {
+		_, err := opcode.Assemble("OP_PUSHBYTES_3 aabb")
+		Expect(err).To(MatchError(ierrors.ErrMalformedPush))
+	})
+
+	It("should reject unknown opcode", func() {
+		_, err := opcode.Assemble("OP_FOO")
+		Expect(err).To(MatchError(ierrors.ErrInvalidOpCode))
+	})
+})
@@ -0,0 +1,62 @@
+package opcode_test
+
+import (
+	"bytes"
+	"sob-miner/internal/corpus"
+	"sob-miner/pkg/opcode"
+	"testing"
+)
+
+// FuzzDisassemble disassembles arbitrary scripts, ASM of a script assembles back to it
+func FuzzDisassemble(f *testing.F) {
+	scripts, err := corpus.Scripts()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, script := range scripts {
+		f.Add(script)
+	}
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		asm, err := opcode.Disassemble(script)
+		if err != nil {
+			return
+		}
+
+		assembled, err := opcode.Assemble(asm)
+		if err != nil {
+			t.Fatalf("%x disassembles to %q which doesn't assemble: %v", script, asm, err)
+		}
+		if !bytes.Equal(assembled, script) {
+			t.Fatalf("%x disassembles to %q which assembles to %x", script, asm, assembled)
+		}
+	})
+}
+
+// FuzzAssemble assembles arbitrary ASM, a script assembled from it keeps its ASM
+func FuzzAssemble(f *testing.F) {
+	asms, err := corpus.Asm()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, asm := range asms {
+		f.Add(asm)
+	}
+
+	f.Fuzz(func(t *testing.T, asm string) {
+		script, err := opcode.Assemble(asm)
+		if err != nil {
+			return
+		}
+
+		disassembled, err := opcode.Disassemble(script)
+		if err != nil {
+			t.Fatalf("%q assembles to %x which doesn't disassemble: %v", asm, script, err)
+		}
+
+		reassembled, err := opcode.Assemble(disassembled)
+		if err != nil || !bytes.Equal(reassembled, script) {
+			t.Fatalf("%q assembles to %x, its ASM %q to %x: %v", asm, script, disassembled, reassembled, err)
+		}
+	})
+}
@@ -0,0 +1,13 @@
+package opcode_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestOpcode(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Opcode Suite")
+}
@@ -0,0 +1,173 @@
+package script
+
+import (
+	"sob-miner/pkg/opcode"
+	"sob-miner/pkg/transaction"
+)
+
+// Class is result of classifying a raw scriptPubKey
+type Class struct {
+	Type transaction.Type
+
+	// P2MS only: m-of-n
+	RequiredSigs int
+	NumPubKeys   int
+
+	// segwit only: witness version and program
+	WitnessVersion int
+	WitnessProgram []byte
+}
+
+// Classify inspects raw scriptPubKey bytes and returns its standard template
+func Classify(script []byte) Class {
+	switch {
+	case isNullData(script):
+		return Class{Type: transaction.OP_RETURN_TYPE}
+	case isPubKeyHash(script):
+		return Class{Type: transaction.P2PKH}
+	case isScriptHash(script):
+		return Class{Type: transaction.P2SH}
+	case isPubKey(script):
+		return Class{Type: transaction.P2PK}
+	}
+
+	if version, program, ok := ExtractWitnessProgram(script); ok {
+		class := Class{WitnessVersion: version, WitnessProgram: program}
+		switch {
+		case version == 0 && len(program) == 20:
+			class.Type = transaction.P2WPKH
+		case version == 0 && len(program) == 32:
+			class.Type = transaction.P2WSH
+		case version == 0:
+			// v0 programs must be 20 or 32 bytes
+			class.Type = transaction.NonStandard
+		case version == 1 && len(program) == 32:
+			class.Type = transaction.P2TR
+		default:
+			class.Type = transaction.WitnessUnknown
+		}
+		return class
+	}
+
+	if m, n, ok := multiSigParams(script); ok {
+		return Class{Type: transaction.P2MS, RequiredSigs: m, NumPubKeys: n}
+	}
+
+	return Class{Type: transaction.NonStandard}
+}
+
+// TypeMatches reports whether a claimed script type label is consistent with classified type.
+// esplora style data labels bare multisig and other non standard scripts as "unknown".
+func TypeMatches(claimed, classified transaction.Type) bool {
+	if claimed == classified {
+		return true
+	}
+	return claimed == transaction.NonStandard && classified == transaction.P2MS
+}
+
+// OP_RETURN <anything>
+func isNullData(script []byte) bool {
+	return len(script) >= 1 && script[0] == opcode.OP_RETURN
+}
+
+// OP_DUP OP_HASH160 OP_PUSHBYTES_20 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
+func isPubKeyHash(script []byte) bool {
+	return len(script) == 25 &&
+		script[0] == opcode.OP_DUP &&
+		script[1] == opcode.OP_HASH160 &&
+		script[2] == opcode.OP_PUSHBYTES_20 &&
+		script[23] == opcode.OP_EQUALVERIFY &&
+		script[24] == opcode.OP_CHECKSIG
+}
+
+// OP_HASH160 OP_PUSHBYTES_20 <20 bytes> OP_EQUAL
+func isScriptHash(script []byte) bool {
+	return len(script) == 23 &&
+		script[0] == opcode.OP_HASH160 &&
+		script[1] == opcode.OP_PUSHBYTES_20 &&
+		script[22] == opcode.OP_EQUAL
+}
+
+// <33 or 65 byte pubkey> OP_CHECKSIG
+func isPubKey(script []byte) bool {
+	switch len(script) {
+	case 35:
+		return script[0] == opcode.OP_PUSHBYTES_33 && isPubKeyBytes(script[1:34]) && script[34] == opcode.OP_CHECKSIG
+	case 67:
+		return script[0] == opcode.OP_PUSHBYTES_65 && isPubKeyBytes(script[1:66]) && script[66] == opcode.OP_CHECKSIG
+	}
+	return false
+}
+
+func isPubKeyBytes(key []byte) bool {
+	switch len(key) {
+	case 33:
+		return key[0] == 0x02 || key[0] == 0x03
+	case 65:
+		return key[0] == 0x04
+	}
+	return false
+}
+
+// ExtractWitnessProgram returns version and program of a segwit scriptPubKey:
+// a version opcode OP_0 | OP_1..OP_16 followed by a single 2..40 byte push
+func ExtractWitnessProgram(script []byte) (int, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 {
+		return 0, nil, false
+	}
+
+	if int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+
+	version, ok := smallInt(script[0])
+	if !ok {
+		return 0, nil, false
+	}
+
+	return version, script[2:], true
+}
+
+// OP_m <pubkey>... OP_n OP_CHECKMULTISIG with 1 <= m <= n <= 16
+func multiSigParams(script []byte) (int, int, bool) {
+	if len(script) < 3 || script[len(script)-1] != opcode.OP_CHECKMULTISIG {
+		return 0, 0, false
+	}
+
+	m, ok := smallInt(script[0])
+	if !ok || m < 1 {
+		return 0, 0, false
+	}
+
+	n, ok := smallInt(script[len(script)-2])
+	if !ok || n < m {
+		return 0, 0, false
+	}
+
+	keys := 0
+	body := script[1 : len(script)-2]
+	for len(body) > 0 {
+		size := int(body[0])
+		if (size != 33 && size != 65) || len(body) < 1+size || !isPubKeyBytes(body[1:1+size]) {
+			return 0, 0, false
+		}
+		keys++
+		body = body[1+size:]
+	}
+
+	if keys != n {
+		return 0, 0, false
+	}
+	return m, n, true
+}
+
+// decodes OP_0, OP_1..OP_16
+func smallInt(op byte) (int, bool) {
+	if op == opcode.OP_0 {
+		return 0, true
+	}
+	if op >= opcode.OP_1 && op <= opcode.OP_16 {
+		return int(op-opcode.OP_1) + 1, true
+	}
+	return 0, false
+}
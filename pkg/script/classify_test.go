@@ -0,0 +1,47 @@
+package script_test
+
+import (
+	"encoding/hex"
+	"sob-miner/pkg/script"
+	"sob-miner/pkg/transaction"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Classify", func() {
+	DescribeTable("should derive type from scriptpubkey",
+		func(scriptHex string, expected transaction.Type) {
+			raw, err := hex.DecodeString(scriptHex)
+			Expect(err).To(BeNil())
+			Expect(script.Classify(raw).Type).To(Equal(expected))
+		},
+		Entry("p2pk compressed", "2102b2fb48ce4536bc0218d0d72d84d791f07649b0650cecb46d9b1ee94afc1785d4ac", transaction.P2PK),
+		Entry("p2pkh", "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac", transaction.P2PKH),
+		Entry("p2sh", "a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87", transaction.P2SH),
+		Entry("p2wpkh", "0014751e76e8199196d454941c45d1b3a323f1433bd6", transaction.P2WPKH),
+		Entry("p2wsh", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", transaction.P2WSH),
+		Entry("p2tr", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", transaction.P2TR),
+		Entry("op_return", "6a24aa21a9ed", transaction.OP_RETURN_TYPE),
+		Entry("witness v2", "5210751e76e8199196d454941c45d1b3a323", transaction.WitnessUnknown),
+		Entry("v1 non 32 byte program", "5114751e76e8199196d454941c45d1b3a323f1433bd6", transaction.WitnessUnknown),
+		Entry("v0 bad program length", "0010751e76e8199196d454941c45d1b3a323", transaction.NonStandard),
+		Entry("p2pkh missing checksig", "76a914536ffa992491508dca0354e52f32a3a7a679a53a88", transaction.NonStandard),
+		Entry("empty", "", transaction.NonStandard),
+	)
+
+	It("should extract m and n of bare multisig", func() {
+		raw, _ := hex.DecodeString("5121" + "02b2fb48ce4536bc0218d0d72d84d791f07649b0650cecb46d9b1ee94afc1785d4" +
+			"21" + "03eed0d937090cae6ffde917de8a80dc6156e30b13edd5e51e2e50d52428da1c87" + "52ae")
+
+		class := script.Classify(raw)
+		Expect(class.Type).To(Equal(transaction.P2MS))
+		Expect(class.RequiredSigs).To(Equal(1))
+		Expect(class.NumPubKeys).To(Equal(2))
+	})
+
+	It("should treat unknown label as bare multisig", func() {
+		Expect(script.TypeMatches(transaction.NonStandard, transaction.P2MS)).To(BeTrue())
+		Expect(script.TypeMatches(transaction.P2PKH, transaction.P2SH)).To(BeFalse())
+	})
+})
@@ -0,0 +1,55 @@
+package script_test
+
+import (
+	"sob-miner/internal/corpus"
+	"sob-miner/pkg/opcode"
+	"sob-miner/pkg/script"
+	"sob-miner/pkg/transaction"
+	"testing"
+)
+
+// FuzzScript walks arbitrary scripts the way sigop counting and classification do,
+// scripts are never executed on their own, see mempool's FuzzValidateTxScripts
+func FuzzScript(f *testing.F) {
+	scripts, err := corpus.Scripts()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, s := range scripts {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s []byte) {
+		if accurate, inaccurate := script.CountSigOps(s, true), script.CountSigOps(s, false); accurate > inaccurate {
+			t.Fatalf("%x counts %d accurate sigops over %d", s, accurate, inaccurate)
+		}
+
+		pushes, ok := script.Pushes(s)
+		if ok != script.IsPushOnly(s) {
+			t.Fatalf("%x lists pushes %v but push only is %v", s, ok, script.IsPushOnly(s))
+		}
+		if _, last := script.LastPush(s); last != (ok && len(pushes) > 0) {
+			t.Fatalf("%x has last push %v with %d pushes", s, last, len(pushes))
+		}
+
+		// a standard script is well formed, OP_RETURN is followed by anything
+		class := script.Classify(s)
+		if class.Type != transaction.NonStandard && class.Type != transaction.OP_RETURN_TYPE {
+			if _, err := opcode.Disassemble(s); err != nil {
+				t.Fatalf("%x classified %s doesn't disassemble: %v", s, class.Type, err)
+			}
+		}
+
+		// spends counting script itself as redeem or witness script must not panic
+		switch class.Type {
+		case transaction.P2MS:
+			if class.RequiredSigs < 1 || class.RequiredSigs > class.NumPubKeys || class.NumPubKeys > 16 {
+				t.Fatalf("%x is %d of %d multisig", s, class.RequiredSigs, class.NumPubKeys)
+			}
+		case transaction.P2SH:
+			script.P2SHSigOps(s, append([]byte{byte(len(s))}, s...))
+		case transaction.P2WPKH, transaction.P2WSH, transaction.P2TR, transaction.WitnessUnknown:
+			script.WitnessSigOps(nil, s, [][]byte{s})
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package script
+
+import "sob-miner/pkg/opcode"
+
+// IsPushOnly reports whether script only contains push opcodes (OP_16 and below)
+func IsPushOnly(script []byte) bool {
+	for pc := 0; pc < len(script); {
+		op, _, next, ok := nextOp(script, pc)
+		if !ok || op > opcode.OP_16 {
+			return false
+		}
+		pc = next
+	}
+	return true
+}
+
+// LastPush returns data of last push in a push only script, redeem script of a p2sh spend
+func LastPush(script []byte) ([]byte, bool) {
+	if len(script) == 0 || !IsPushOnly(script) {
+		return nil, false
+	}
+
+	var data []byte
+	for pc := 0; pc < len(script); {
+		_, pushed, next, _ := nextOp(script, pc)
+		data = pushed
+		pc = next
+	}
+	return data, true
+}
+
+// Pushes returns items a push only script puts on stack, e.g. a scriptSig.
+// OP_0 pushes an empty item and OP_1NEGATE to OP_16 their number
+func Pushes(script []byte) ([][]byte, bool) {
+	if !IsPushOnly(script) {
+		return nil, false
+	}
+
+	pushes := [][]byte{}
+	for pc := 0; pc < len(script); {
+		op, pushed, next, _ := nextOp(script, pc)
+		switch {
+		case op == opcode.OP_1NEGATE:
+			pushed = []byte{0x81}
+		case op >= opcode.OP_1 && op <= opcode.OP_16:
+			pushed = []byte{op - opcode.OP_1 + 1}
+		case pushed == nil:
+			pushed = []byte{}
+		}
+		pushes = append(pushes, pushed)
+		pc = next
+	}
+	return pushes, true
+}
@@ -0,0 +1,19 @@
+package script_test
+
+import (
+	"sob-miner/pkg/script"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pushes", func() {
+	It("should list pushes of a push only script", func() {
+		pushes, ok := script.Pushes(mustHex("00" + "4f" + "52" + "02abcd" + "4c01ef"))
+		Expect(ok).To(BeTrue())
+		Expect(pushes).To(Equal([][]byte{{}, {0x81}, {2}, mustHex("abcd"), mustHex("ef")}))
+
+		_, ok = script.Pushes(mustHex("76a9"))
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,127 @@
+package script
+
+import (
+	"encoding/binary"
+	"sob-miner/pkg/opcode"
+)
+
+const (
+	// witness data is discounted by this factor, non witness sigops are scaled by it
+	WitnessScaleFactor = 4
+
+	// consensus limit on sigop cost of a block
+	MaxBlockSigOpsCost = 80_000
+
+	// sigops counted for CHECKMULTISIG when number of keys is not known
+	maxPubKeysPerMultiSig = 20
+)
+
+// CountSigOps counts signature operations of a script, port of bitcoin core's GetSigOpCount.
+// when accurate is false every CHECKMULTISIG is counted as 20 sigops, when true
+// a preceding OP_1..OP_16 is taken as number of keys. counting stops at a malformed push.
+func CountSigOps(script []byte, accurate bool) int {
+	count := 0
+	lastOp := byte(opcode.OP_INVALIDOPCODE)
+
+	for pc := 0; pc < len(script); {
+		op, _, next, ok := nextOp(script, pc)
+		if !ok {
+			break
+		}
+		pc = next
+
+		switch op {
+		case opcode.OP_CHECKSIG, opcode.OP_CHECKSIGVERIFY:
+			count++
+		case opcode.OP_CHECKMULTISIG, opcode.OP_CHECKMULTISIGVERIFY:
+			if accurate && lastOp >= opcode.OP_1 && lastOp <= opcode.OP_16 {
+				count += int(lastOp-opcode.OP_1) + 1
+			} else {
+				count += maxPubKeysPerMultiSig
+			}
+		}
+		lastOp = op
+	}
+
+	return count
+}
+
+// P2SHSigOps counts sigops of redeem script pushed by scriptSig when scriptPubKey is P2SH
+func P2SHSigOps(scriptPubKey, scriptSig []byte) int {
+	if !isScriptHash(scriptPubKey) {
+		return 0
+	}
+
+	redeemScript, ok := LastPush(scriptSig)
+	if !ok {
+		return 0
+	}
+	return CountSigOps(redeemScript, true)
+}
+
+// WitnessSigOps counts sigops of a segwit spend, native or nested in P2SH.
+// only v0 programs have sigops, P2WPKH is 1 and P2WSH is counted from witness script.
+// witness sigops are not scaled, legacy and P2SH sigops cost WitnessScaleFactor each.
+func WitnessSigOps(scriptSig, scriptPubKey []byte, witness [][]byte) int {
+	program := scriptPubKey
+	if isScriptHash(scriptPubKey) {
+		redeemScript, ok := LastPush(scriptSig)
+		if !ok {
+			return 0
+		}
+		program = redeemScript
+	}
+
+	version, prog, ok := ExtractWitnessProgram(program)
+	if !ok || version != 0 {
+		return 0
+	}
+
+	switch len(prog) {
+	case 20:
+		return 1
+	case 32:
+		if len(witness) == 0 {
+			return 0
+		}
+		return CountSigOps(witness[len(witness)-1], true)
+	}
+	return 0
+}
+
+// reads opcode at pc, returns it with pushed data and position of next opcode
+func nextOp(script []byte, pc int) (byte, []byte, int, bool) {
+	op := script[pc]
+	pc++
+
+	dataLen := 0
+	switch {
+	case op >= opcode.OP_PUSHBYTES_1 && op <= opcode.OP_PUSHBYTES_75:
+		dataLen = int(op)
+	case op == opcode.OP_PUSHDATA1:
+		if pc+1 > len(script) {
+			return op, nil, pc, false
+		}
+		dataLen = int(script[pc])
+		pc++
+	case op == opcode.OP_PUSHDATA2:
+		if pc+2 > len(script) {
+			return op, nil, pc, false
+		}
+		dataLen = int(binary.LittleEndian.Uint16(script[pc:]))
+		pc += 2
+	case op == opcode.OP_PUSHDATA4:
+		if pc+4 > len(script) {
+			return op, nil, pc, false
+		}
+		dataLen = int(binary.LittleEndian.Uint32(script[pc:]))
+		pc += 4
+	default:
+		return op, nil, pc, true
+	}
+
+	if dataLen < 0 || pc+dataLen > len(script) {
+		return op, nil, pc, false
+	}
+	return op, script[pc : pc+dataLen], pc + dataLen, true
+}
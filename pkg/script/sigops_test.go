@@ -0,0 +1,64 @@
+package script_test
+
+import (
+	"encoding/hex"
+	"sob-miner/pkg/script"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func mustHex(s string) []byte {
+	raw, err := hex.DecodeString(s)
+	Expect(err).To(BeNil())
+	return raw
+}
+
+const (
+	pubKey1 = "02b2fb48ce4536bc0218d0d72d84d791f07649b0650cecb46d9b1ee94afc1785d4"
+	pubKey2 = "03eed0d937090cae6ffde917de8a80dc6156e30b13edd5e51e2e50d52428da1c87"
+
+	// OP_1 <pubkey> <pubkey> OP_2 OP_CHECKMULTISIG
+	multiSig = "5121" + pubKey1 + "21" + pubKey2 + "52ae"
+)
+
+var _ = Describe("SigOps", func() {
+	DescribeTable("should count sigops of a script",
+		func(scriptHex string, accurate bool, expected int) {
+			Expect(script.CountSigOps(mustHex(scriptHex), accurate)).To(Equal(expected))
+		},
+		Entry("p2pkh", "76a914536ffa992491508dca0354e52f32a3a7a679a53a88ac", false, 1),
+		Entry("checksigverify", "ad", false, 1),
+		Entry("multisig inaccurate", multiSig, false, 20),
+		Entry("multisig accurate", multiSig, true, 2),
+		Entry("multisig without small int", "00ae", true, 20),
+		Entry("checksig inside push is data", "01ac", false, 0),
+		Entry("stops at malformed push", "ac4c", false, 1),
+		Entry("empty", "", false, 0),
+	)
+
+	It("should count redeem script sigops of p2sh spend", func() {
+		scriptPubKey := mustHex("a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87")
+		scriptSig := mustHex("00" + "03304402" + "47" + multiSig)
+		Expect(script.P2SHSigOps(scriptPubKey, scriptSig)).To(Equal(2))
+
+		// redeem script is only parsed from push only scriptSig
+		Expect(script.P2SHSigOps(scriptPubKey, mustHex("ac47"+multiSig))).To(Equal(0))
+		Expect(script.P2SHSigOps(mustHex("ac"), scriptSig)).To(Equal(0))
+	})
+
+	It("should count witness sigops", func() {
+		p2wpkh := mustHex("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+		p2wsh := mustHex("00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262")
+		p2tr := mustHex("512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+
+		Expect(script.WitnessSigOps(nil, p2wpkh, nil)).To(Equal(1))
+		Expect(script.WitnessSigOps(nil, p2wsh, [][]byte{{}, mustHex(multiSig)})).To(Equal(2))
+		Expect(script.WitnessSigOps(nil, p2wsh, nil)).To(Equal(0))
+		Expect(script.WitnessSigOps(nil, p2tr, [][]byte{mustHex("ac")})).To(Equal(0))
+
+		// p2sh-p2wpkh
+		p2sh := mustHex("a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87")
+		Expect(script.WitnessSigOps(mustHex("16"+"0014751e76e8199196d454941c45d1b3a323f1433bd6"), p2sh, nil)).To(Equal(1))
+	})
+})
@@ -19,7 +19,7 @@ const (
 	P2PK  Type = "p2pk"
 	P2PKH Type = "p2pkh"
 	P2SH  Type = "p2sh"
-	P2MS  Type = "unknown" // pay to multi sig [not in given dataset]
+	P2MS  Type = "multisig" // bare m-of-n multisig, given dataset labels it "unknown"
 
 	// segwit
 	P2WPKH Type = "v0_p2wpkh"
@@ -28,8 +28,14 @@ const (
 	// taproot
 	P2TR Type = "v1_p2tr"
 
+	// witness program of a version [or length] without defined semantics
+	WitnessUnknown Type = "witness_unknown"
+
 	// uffff type
 	OP_RETURN_TYPE Type = "op_return"
+
+	// anything else
+	NonStandard Type = "unknown"
 )
 
 type Tx struct {
@@ -38,24 +44,39 @@ type Tx struct {
 	Version  uint32 `json:"version"`
 	Locktime uint32 `json:"locktime"`
 
-	Hash  string `json:"hash"`
+	Hash  string `json:"hash" gorm:"index"`
 	WTXID string `json:"wtxid"`
 
 	FeeCollected uint64 `json:"feecollected"`
+	FeeDelta     int64  `json:"feedelta"` // prioritisation, counts in tx selection only, coinbase gets FeeCollected
 	Weight       uint64 `json:"weight"`
+	SigOpCost    uint64 `json:"sigopcost"` // legacy and p2sh sigops x4 + witness sigops
 	IsRBFed      bool   `json:"isrbfed"`
 }
 
+// ModifiedFee is fee used to rank tx, FeeCollected with FeeDelta applied
+func (t Tx) ModifiedFee() uint64 {
+	if t.FeeDelta < 0 && uint64(-t.FeeDelta) > t.FeeCollected {
+		return 0
+	}
+	return uint64(int64(t.FeeCollected) + t.FeeDelta)
+}
+
+// VSize is virtual size in vbytes, weight / 4 rounded up
+func (t Tx) VSize() uint64 {
+	return (t.Weight + 3) / 4
+}
+
 type InputTx struct {
 	gorm.Model
 
 	// transaction which is spending this input
-	SpendingTxHash string `json:"spendingtxhash"`
+	SpendingTxHash string `json:"spendingtxhash" gorm:"index"`
 
 	// previous output txHash
-	FundingTxHash string `json:"fundingtxhash"`
+	FundingTxHash string `json:"fundingtxhash" gorm:"index:prevoutIndex"`
 	// previous output tx Index
-	FundingIndex uint32 `json:"fundingindex"`
+	FundingIndex uint32 `json:"fundingindex" gorm:"index:prevoutIndex"`
 
 	ScriptSig string `json:"scriptsig"`
 	ScriptAsm string `json:"scriptasm"`